@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/a1ta1r/Credit-Portfolio/internal/app"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeServices "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
+	"github.com/joho/godotenv"
+	"os"
+)
+
+// importrates loads exchange rates from a CSV file into the database:
+//
+//	importrates -file rates.csv
+//	importrates -file eurofxref-hist.csv -currency EUR
+func main() {
+	fileName := flag.String("file", "", "CSV file with exchange rates")
+	currency := flag.String("currency", "", "currency priced by a file with a column per quote currency")
+	flag.Parse()
+	if *fileName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	godotenv.Load()
+	db, err := app.GetConnection()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, codes.ConnectionError)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	file, err := os.Open(*fileName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	count, err := financeServices.NewExchangeService(db).ImportRates(file, *currency)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("imported %d exchange rates\n", count)
+}
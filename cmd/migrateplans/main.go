@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"github.com/a1ta1r/Credit-Portfolio/internal/app"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/joho/godotenv"
+	"os"
+)
+
+// migrateplans moves equal payment plans saved when their first payment fell on the start date to the
+// schedule where payments are due one period after the start, see app.MigrateLegacyAnnuityPlans:
+//
+//	migrateplans
+func main() {
+	godotenv.Load()
+	db, err := app.GetConnection()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, codes.ConnectionError)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	count, err := app.MigrateLegacyAnnuityPlans(&db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("migrated %d payment plans\n", count)
+}
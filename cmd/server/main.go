@@ -7,6 +7,7 @@ import (
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/auth"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/common"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/controllers"
+	financeServices "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
 	loanControllers "github.com/a1ta1r/Credit-Portfolio/internal/components/loans/controllers"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/roles"
@@ -20,6 +21,8 @@ import (
 	"github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
 	"net/http"
+	"os"
+	"time"
 
 	_ "github.com/a1ta1r/Credit-Portfolio/internal/docs" //swagger
 )
@@ -48,7 +51,11 @@ func main() {
 
 	//Add services to DI
 	userService := services.NewUserService(storageContainer)
-	agendaService := services.NewAgendaService(db)
+	exchangeService := financeServices.NewExchangeService(db)
+	agendaService := services.NewAgendaService(db, exchangeService)
+	penaltyService := services.NewPenaltyService(db)
+	categoryService := services.NewCategoryService(db, exchangeService)
+	importService := services.NewImportService(db, agendaService, categoryService)
 	userStatService := statServices.UserStatisticsService{storageContainer.UserStorage}
 
 	lastSeenHandler := user_handlers.NewLastSeenHandler(userService)
@@ -56,12 +63,23 @@ func main() {
 	healthController := system.NewHealthController(&db)
 	userController := loanControllers.NewUserController(userService)
 	commonController := controllers.NewCommonController(&db)
-	paymentPlanController := loanControllers.NewPaymentPlanController(&db, userService)
-	paymentController := loanControllers.NewPaymentController(&db)
-	incomeController := loanControllers.NewIncomeController(&db)
-	expenseController := loanControllers.NewExpenseController(&db)
+	exchangeRateController := controllers.NewExchangeRateController(&db, exchangeService)
+	paymentPlanController := loanControllers.NewPaymentPlanController(&db, userService, exchangeService)
+	paymentController := loanControllers.NewPaymentController(&db, penaltyService)
+	prepaymentController := loanControllers.NewPrepaymentController(&db)
+	rateController := loanControllers.NewRateController(&db)
+	refinancingController := loanControllers.NewRefinancingController(&db)
+	payoffController := loanControllers.NewPayoffController(&db, exchangeService)
+	penaltyController := loanControllers.NewPenaltyController(&db, penaltyService)
+	portfolioController := loanControllers.NewPortfolioController(&db, exchangeService)
+	incomeController := loanControllers.NewIncomeController(&db, categoryService)
+	expenseController := loanControllers.NewExpenseController(&db, categoryService)
+	categoryController := loanControllers.NewCategoryController(&db, categoryService)
+	budgetController := loanControllers.NewBudgetController(&db, categoryService)
 	agendaController := loanControllers.NewAgendaController(agendaService)
-	calculationController := loanControllers.NewCalculatorController(&db)
+	exportController := loanControllers.NewExportController(&db, agendaService)
+	importController := loanControllers.NewImportController(importService)
+	calculationController := loanControllers.NewCalculatorController(&db, exchangeService)
 	advertiserController := adsControllers.NewAdvertiserController(
 		storageContainer.AdvertiserStorage,
 		storageContainer.BannerStorage,
@@ -103,6 +121,22 @@ func main() {
 		basicAccess.DELETE("/plans/:id", paymentPlanController.DeletePaymentPlan)
 
 		basicAccess.GET("/plans/:id/payments", paymentController.GetPaymentsByPlan)
+		basicAccess.GET("/plans/:id/export", exportController.ExportPlan)
+		basicAccess.GET("/plans/:id/statement", exportController.ExportStatement)
+		basicAccess.PUT("/plans/:id/payments/:paymentId", paymentController.RecordPayment)
+		basicAccess.GET("/plans/:id/penalties", penaltyController.GetPenaltiesByPlan)
+		basicAccess.GET("/plans/:id/prepayments", prepaymentController.GetPrepaymentsByPlan)
+		basicAccess.POST("/plans/:id/prepayments", prepaymentController.AddPrepayment)
+		basicAccess.POST("/plans/:id/prepayments/simulate", prepaymentController.SimulatePrepayment)
+		basicAccess.GET("/plans/:id/rates", rateController.GetRateChanges)
+		basicAccess.POST("/plans/:id/rates", rateController.AddRateChange)
+		basicAccess.POST("/plans/:id/refinancing", refinancingController.CompareRefinancing)
+		basicAccess.POST("/payoff", payoffController.GetPayoffPlans)
+		basicAccess.GET("/portfolio/summary", portfolioController.GetPortfolioSummary)
+		basicAccess.GET("/portfolio/export", exportController.ExportPortfolio)
+
+		basicAccess.GET("/indexes", rateController.GetRateIndexes)
+		basicAccess.GET("/exchangeRates", exchangeRateController.GetExchangeRates)
 		//basicAccess.GET("/payments/:id", paymentController.GetPayment)
 		//basicAccess.POST("/payments", paymentController.AddPayment)
 		//basicAccess.DELETE("/payments/:id", paymentController.DeletePayment)
@@ -120,9 +154,26 @@ func main() {
 		basicAccess.POST("/expenses", expenseController.AddExpense)
 		basicAccess.DELETE("/expenses/:id", expenseController.DeleteExpenseByIdAndJWT)
 
+		basicAccess.POST("/import/preview", importController.PreviewImport)
+		basicAccess.POST("/import", importController.Import)
+
+		basicAccess.GET("/categories", categoryController.GetCategories)
+		basicAccess.POST("/categories", categoryController.AddCategory)
+		basicAccess.DELETE("/categories/:id", categoryController.DeleteCategory)
+		basicAccess.GET("/budgets", budgetController.GetBudgets)
+		basicAccess.PUT("/budgets", budgetController.SetBudget)
+		basicAccess.DELETE("/budgets/:id", budgetController.DeleteBudget)
+		basicAccess.GET("/budgets/report", budgetController.GetBudgetReport)
+
 		basicAccess.POST("/calculate", calculationController.CalculateCredit)
+		basicAccess.POST("/calculate/affordability", calculationController.CalculateAffordability)
 
 		basicAccess.GET("/agenda", agendaController.GetAgendaElements)
+		basicAccess.GET("/agenda/forecast", agendaController.GetForecast)
+		basicAccess.GET("/agenda/ics", agendaController.ExportAgenda)
+		basicAccess.GET("/agenda/export", exportController.ExportAgenda)
+		basicAccess.GET("/agenda/feed", agendaController.GetCalendarFeedURL)
+		basicAccess.POST("/agenda/feed", agendaController.RotateCalendarFeedURL)
 	}
 
 	//TODO убрать рекламщиков в вип доступ для админа
@@ -157,19 +208,24 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"hello": "world"})
 	}
 
-	//Не работает чет, доступ есть у всего на свете
 	adminAccess := router.Group("/administration", adminJwtMiddleware.MiddlewareFunc())
 	{
 		adminAccess.GET("/private", private)
+		adminAccess.GET("/indexes", rateController.GetRateIndexes)
+		adminAccess.POST("/indexes", rateController.AddRateIndex)
+		adminAccess.POST("/indexes/:id/values", rateController.AddRateIndexValue)
+		adminAccess.POST("/penalties/accrue", penaltyController.AccruePenalties)
+		adminAccess.POST("/exchangeRates", exchangeRateController.AddExchangeRate)
+		adminAccess.POST("/exchangeRates/import", exchangeRateController.ImportExchangeRates)
 	}
 
-	//Не работает чет, доступ есть у всего на свете
 	merchantAccess := router.Group("/banking", merchantJwtMiddleware.MiddlewareFunc())
 	{
 		merchantAccess.GET("", private)
 	}
 
 	router.GET("/health", healthController.HealthCheck)
+	router.GET("/calendar/:token", agendaController.GetCalendarFeed)
 
 	router.POST("/signin", userJwtMiddleware.LoginHandler)
 	router.POST("/signup", userController.AddUser)
@@ -186,5 +242,15 @@ func main() {
 
 	router.NoRoute(handlers.NotFound)
 
+	if err := categoryService.EnsureDefaultCategories(); err != nil {
+		println("Default categories are not created:", err.Error())
+	}
+
+	penaltyInterval, err := time.ParseDuration(os.Getenv("PENALTY_JOB_INTERVAL"))
+	if err != nil || penaltyInterval <= 0 {
+		penaltyInterval = 24 * time.Hour
+	}
+	go penaltyService.Run(penaltyInterval)
+
 	router.Run()
 }
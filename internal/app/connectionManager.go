@@ -51,11 +51,20 @@ func SyncModelsWithSchema() {
 	db.AutoMigrate(
 		&fe.Bank{},
 		&fe.Currency{},
+		&fe.RateIndex{},
+		&fe.RateIndexValue{},
+		&fe.ExchangeRate{},
 		&le.User{},
 		&le.PaymentPlan{},
 		&le.Payment{},
+		&le.Prepayment{},
+		&le.Fee{},
+		&le.RateChange{},
+		&le.Penalty{},
 		&le.Income{},
 		&le.Expense{},
+		&le.Category{},
+		&le.Budget{},
 		&ae.Advertiser{},
 		&ae.Advertisement{},
 		&ae.BannerPlace{},
@@ -71,11 +80,20 @@ func DropAllTables() {
 	db.DropTable(
 		&fe.Bank{},
 		&fe.Currency{},
+		&fe.RateIndex{},
+		&fe.RateIndexValue{},
+		&fe.ExchangeRate{},
 		&le.User{},
 		&le.PaymentPlan{},
 		&le.Payment{},
+		&le.Prepayment{},
+		&le.Fee{},
+		&le.RateChange{},
+		&le.Penalty{},
 		&le.Income{},
 		&le.Expense{},
+		&le.Category{},
+		&le.Budget{},
 		&ae.Advertiser{},
 		&ae.Advertisement{},
 		&ae.BannerPlace{},
@@ -0,0 +1,30 @@
+package app
+
+import (
+	le "github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/jinzhu/gorm"
+)
+
+// MigrateLegacyAnnuityPlans moves the start of the equal payment plans calculated before payments fell due
+// one period after the loan start. Those plans have their first payment on the start date, so the schedules
+// rebuilt for prepayments and rate changes would be shifted by a period. The start is moved back by one
+// payment period of the plan, a week, a month, a quarter or a year, and the stored payments stay where they
+// are. Plans already migrated have no payment on their start date, so running it again changes nothing.
+// It returns the number of plans moved.
+func MigrateLegacyAnnuityPlans(db *gorm.DB) (int64, error) {
+	var paymentPlans []le.PaymentPlan
+	err := db.Where("payment_type = ? AND EXISTS (SELECT 1 FROM payments WHERE payments.payment_plan_id = payment_plans.id"+
+		" AND payments.payment_date = payment_plans.start_date)", le.Even).Find(&paymentPlans).Error
+	if err != nil {
+		return 0, err
+	}
+	tx := db.Begin()
+	for _, paymentPlan := range paymentPlans {
+		start := paymentPlan.AddPeriods(paymentPlan.StartDate, -1)
+		if err := tx.Model(&le.PaymentPlan{ID: paymentPlan.ID}).UpdateColumn("start_date", start).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return int64(len(paymentPlans)), tx.Commit().Error
+}
@@ -13,3 +13,27 @@ const BadTimeFormat = "Could not recognize given time format, please use RFC3339
 const InvalidJSON = "request body must be valid JSON"
 const ConnectionError = "could not connect to database"
 const AdvertiserNotExists = "advertiserID must be valid and match existing advertiser"
+const BadPrepayment = "prepayment amount must be positive, its strategy must be known and its date must fall within the plan term"
+const PlanRepaid = "payment plan is already repaid"
+const TermNotReducible = "prepayment cannot shorten the term: the installment does not repay the remaining balance"
+const BadRateChange = "rate change date must fall within the plan term"
+const BadRateIndexValue = "rate index value must be dated"
+const BadGracePeriod = "grace period must be shorter than the plan term"
+const BadPaymentPeriod = "paymentPeriod must be a known time period and frequency must not be negative"
+const BadRefinancingOffer = "refinancing offer must have a positive term and a non-negative interest rate"
+const BadPayoffRequest = "extra payment must not be negative and custom order must list the user's plans"
+const BadPaymentRecord = "paid amount must match the payment status and paid date must not be before the plan start"
+const BadExchangeRate = "exchange rate must be positive, dated and link two different existing currencies"
+const NoExchangeRate = "no exchange rate to the base currency is known on the given date"
+const BadBaseCurrency = "base currency must be an existing currency"
+const NoBaseCurrency = "amounts in different currencies can only be combined in a base currency, set one for the user"
+const BadExchangeRateFile = "exchange rate file must be CSV with a date column and either currency, quoteCurrency and rate columns or a column per quote currency"
+const BadAffordabilityRequest = "debt-to-income cap must be between 0 and 1, term must be positive and interest rate must not be negative"
+const BadForecastRequest = "balance must be a number, horizon must be a positive number of days and interval must be day or month"
+const BadCategory = "category must exist, be available to the user and be of the same type as the record"
+const BadBudget = "budget must be set for an available expense or income category and must not be negative"
+const BadRecurrence = "recurrence must be a positive frequency of a known payment period or a valid RRULE, and exception dates must be dates separated by commas"
+const BadAgendaQuery = "agenda query must list known element types, ids, amounts, sort and grouping options, a positive limit and a cursor from a previous page"
+const BadExportFormat = "export format must be csv or xlsx"
+const BadStatementFile = "statement must be a CSV file matching the column mapping or an OFX or QFX file"
+const BadImport = "imported transactions must be incomes or expenses with a date, a positive amount, a known currency and an available category"
@@ -20,15 +20,18 @@ func NewJwtWrapper(userService services.UserService) JwtWrapper {
 
 func (w JwtWrapper) GetJwtMiddleware(role roles.Role) jwt.GinJWTMiddleware {
 	var authFunc = func(c *gin.Context) (interface{}, error) { return "", nil }
+	var authorizator func(data interface{}, c *gin.Context) bool
 	switch role {
 	case roles.Basic:
 		authFunc = w.userRoleAuthFunc
 		break
 	case roles.Admin:
 		authFunc = w.adminRoleAuthFunc
+		authorizator = roleAuthorizator(roles.Admin)
 		break
 	case roles.Ads:
 		authFunc = w.merchantRoleAuthFunc
+		authorizator = roleAuthorizator(roles.Ads)
 		break
 	}
 	jwtMiddleware := jwt.GinJWTMiddleware{
@@ -37,11 +40,21 @@ func (w JwtWrapper) GetJwtMiddleware(role roles.Role) jwt.GinJWTMiddleware {
 		Timeout:       time.Hour,
 		MaxRefresh:    time.Hour * 24,
 		Authenticator: authFunc,
+		Authorizator:  authorizator,
 		PayloadFunc:   w.Payload,
 	}
 	return jwtMiddleware
 }
 
+// roleAuthorizator lets through only the tokens issued to users of the given role,
+// whichever login handler issued them
+func roleAuthorizator(role roles.Role) func(data interface{}, c *gin.Context) bool {
+	return func(data interface{}, c *gin.Context) bool {
+		claimed, ok := jwt.ExtractClaims(c)["role"].(float64)
+		return ok && roles.Role(claimed) == role
+	}
+}
+
 func (w JwtWrapper) userRoleAuthFunc(c *gin.Context) (interface{}, error) {
 	var users []entities.User
 	var testUser entities.User
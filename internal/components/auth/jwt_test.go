@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/roles"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoleAuthorizator(t *testing.T) {
+	for _, test := range []struct {
+		claims jwt.MapClaims
+		role   roles.Role
+		want   bool
+	}{
+		{jwt.MapClaims{"role": float64(roles.Admin)}, roles.Admin, true},
+		{jwt.MapClaims{"role": float64(roles.Basic)}, roles.Admin, false},
+		{jwt.MapClaims{"role": float64(roles.Ads)}, roles.Admin, false},
+		{jwt.MapClaims{"role": float64(roles.Ads)}, roles.Ads, true},
+		{jwt.MapClaims{}, roles.Admin, false},
+		{jwt.MapClaims{"role": "admin"}, roles.Admin, false},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("JWT_PAYLOAD", test.claims)
+		if got := roleAuthorizator(test.role)(nil, c); got != test.want {
+			t.Errorf("roleAuthorizator(%v) with claims %v = %v, want %v", test.role, test.claims, got, test.want)
+		}
+	}
+}
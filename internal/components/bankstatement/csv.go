@@ -0,0 +1,214 @@
+package bankstatement
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVMapping tells which columns of a CSV statement hold what. Columns are given by their name in the header
+// row or by their number counting from 1.
+type CSVMapping struct {
+	NoHeader     bool   `json:"noHeader"`     //первая строка - уже операция, а не названия колонок
+	Delimiter    string `json:"delimiter"`    //пустой - самый частый из , ; и табуляции в первой строке
+	Date         string `json:"date"`         //пустой - колонка date
+	DateFormat   string `json:"dateFormat"`   //например DD.MM.YYYY или MM/DD/YYYY, пустой - ISO и DD.MM.YYYY
+	Amount       string `json:"amount"`       //пустой - колонка amount, если не заданы debit и credit
+	Debit        string `json:"debit"`        //списания, если суммы разнесены по двум колонкам
+	Credit       string `json:"credit"`       //поступления
+	DecimalComma bool   `json:"decimalComma"` //запятая отделяет дробную часть даже перед тремя цифрами
+	Description  string `json:"description"`  //пустой - колонка description, если она есть
+	Currency     string `json:"currency"`
+	ID           string `json:"id"`
+}
+
+// csvColumns are the resolved column indexes of a mapping, -1 for columns the statement lacks
+type csvColumns struct {
+	date, amount, debit, credit, description, currency, id int
+}
+
+var defaultDateLayouts = []string{
+	"2006-01-02",
+	"02.01.2006",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+}
+
+// ParseCSV reads the operations of a CSV statement, one per row
+func ParseCSV(data []byte, mapping CSVMapping) ([]Transaction, error) {
+	text := decodeText(data, false)
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.Comma = delimiter(text, mapping.Delimiter)
+
+	var header []string
+	if !mapping.NoHeader {
+		record, err := reader.Read()
+		if err != nil {
+			return nil, statementError(1, "no header row")
+		}
+		header = record
+	}
+	columns, err := mapping.columns(header)
+	if err != nil {
+		return nil, err
+	}
+	layouts := defaultDateLayouts
+	if mapping.DateFormat != "" {
+		layouts = []string{dateLayout(mapping.DateFormat)}
+	}
+
+	var transactions []Transaction
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, statementError(line, err.Error())
+		}
+		if isBlank(record) {
+			continue
+		}
+		transaction, err := columns.transaction(record, layouts, mapping.DecimalComma)
+		if err != nil {
+			return nil, statementError(line, err.Error())
+		}
+		if len(transactions) == MaxTransactions {
+			return nil, statementError(line, fmt.Sprintf("more than %d operations", MaxTransactions))
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// columns finds the mapped columns in the header row
+func (mapping CSVMapping) columns(header []string) (csvColumns, error) {
+	var err error
+	column := func(spec string, fallback string, required bool) int {
+		optional := spec == ""
+		if optional {
+			spec = fallback
+		}
+		if spec == "" || err != nil {
+			return -1
+		}
+		if number, convErr := strconv.Atoi(spec); convErr == nil && number > 0 {
+			return number - 1
+		}
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(spec)) {
+				return i
+			}
+		}
+		if required || !optional {
+			err = statementError(1, fmt.Sprintf("no column %q", spec))
+		}
+		return -1
+	}
+	amountFallback := "amount"
+	if mapping.Debit != "" || mapping.Credit != "" {
+		amountFallback = ""
+	}
+	columns := csvColumns{
+		date:        column(mapping.Date, "date", true),
+		amount:      column(mapping.Amount, amountFallback, amountFallback != ""),
+		debit:       column(mapping.Debit, "", false),
+		credit:      column(mapping.Credit, "", false),
+		description: column(mapping.Description, "description", false),
+		currency:    column(mapping.Currency, "", false),
+		id:          column(mapping.ID, "", false),
+	}
+	return columns, err
+}
+
+func (columns csvColumns) transaction(record []string, layouts []string, decimalComma bool) (Transaction, error) {
+	field := func(column int) string {
+		if column < 0 || column >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[column])
+	}
+	var transaction Transaction
+	date, err := parseDate(field(columns.date), layouts)
+	if err != nil {
+		return transaction, err
+	}
+	transaction.Date = date
+	if columns.amount >= 0 {
+		if transaction.Amount, err = parseAmount(field(columns.amount), decimalComma); err != nil {
+			return transaction, fmt.Errorf("bad amount %q", field(columns.amount))
+		}
+	} else {
+		for _, column := range []int{columns.credit, columns.debit} {
+			if field(column) == "" {
+				continue
+			}
+			amount, err := parseAmount(field(column), decimalComma)
+			if err != nil {
+				return transaction, fmt.Errorf("bad amount %q", field(column))
+			}
+			if amount < 0 {
+				amount = -amount
+			}
+			if column == columns.debit {
+				amount = -amount
+			}
+			transaction.Amount += amount
+		}
+	}
+	transaction.Description = field(columns.description)
+	transaction.Currency = field(columns.currency)
+	transaction.ExternalID = field(columns.id)
+	return transaction, nil
+}
+
+// parseDate reads the date of an operation in the first layout that fits, dropping its time of day
+func parseDate(text string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, text); err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad date %q", text)
+}
+
+// dateLayout turns a format like DD.MM.YYYY into a time layout
+func dateLayout(format string) string {
+	return strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02",
+		"hh", "15", "mm", "04", "ss", "05").Replace(format)
+}
+
+// delimiter returns the configured delimiter or the one the first line of the statement uses most
+func delimiter(text string, configured string) rune {
+	if configured == `\t` || strings.EqualFold(configured, "tab") {
+		return '\t'
+	}
+	if configured != "" {
+		return []rune(configured)[0]
+	}
+	firstLine := strings.SplitN(text, "\n", 2)[0]
+	best := ','
+	for _, candidate := range []rune{';', '\t'} {
+		if strings.Count(firstLine, string(candidate)) > strings.Count(firstLine, string(best)) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+func isBlank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
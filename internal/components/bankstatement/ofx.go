@@ -0,0 +1,119 @@
+package bankstatement
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// ParseOFX reads the operations of an OFX or QFX statement, both the SGML of OFX 1 where leaf elements
+// are not closed and the XML of OFX 2. Operations are in the default currency of their statement unless
+// they give their own.
+func ParseOFX(data []byte) ([]Transaction, error) {
+	header := strings.ToUpper(string(data))
+	if end := strings.Index(header, "<OFX>"); end >= 0 {
+		header = header[:end]
+	}
+	western := strings.Contains(header, "CHARSET:1252") || strings.Contains(header, "ISO-8859-1") ||
+		strings.Contains(header, "CHARSET:8859-1")
+	text := decodeText(data, western)
+	start := strings.Index(strings.ToUpper(text), "<OFX>")
+	if start < 0 {
+		return nil, statementError(1, "no OFX element")
+	}
+	line := strings.Count(text[:start], "\n") + 1
+	text = text[start:]
+
+	var transactions []Transaction
+	var transaction *Transaction
+	var currency string
+	finish := func() error {
+		if transaction == nil {
+			return nil
+		}
+		if transaction.Date.IsZero() {
+			return statementError(line, "operation without DTPOSTED")
+		}
+		if len(transactions) == MaxTransactions {
+			return statementError(line, fmt.Sprintf("more than %d operations", MaxTransactions))
+		}
+		transactions = append(transactions, *transaction)
+		transaction = nil
+		return nil
+	}
+	var name, memo, aggregate string
+	for len(text) > 0 {
+		open := strings.IndexByte(text, '<')
+		if open < 0 {
+			break
+		}
+		line += strings.Count(text[:open], "\n")
+		end := strings.IndexByte(text[open:], '>')
+		if end < 0 {
+			return nil, statementError(line, "unclosed tag")
+		}
+		tag := strings.ToUpper(strings.TrimSpace(text[open+1 : open+end]))
+		text = text[open+end+1:]
+		valueEnd := strings.IndexByte(text, '<')
+		if valueEnd < 0 {
+			valueEnd = len(text)
+		}
+		value := strings.TrimSpace(html.UnescapeString(text[:valueEnd]))
+
+		switch tag {
+		case "STMTTRN":
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			transaction = &Transaction{Currency: currency}
+			name, memo, aggregate = "", "", ""
+		case "/STMTTRN", "/BANKTRANLIST":
+			if err := finish(); err != nil {
+				return nil, err
+			}
+		case "CURDEF":
+			currency = value
+		case "CURRENCY", "ORIGCURRENCY":
+			aggregate = tag
+		}
+		if transaction == nil {
+			continue
+		}
+		switch tag {
+		case "DTPOSTED":
+			if len(value) < 8 {
+				return nil, statementError(line, fmt.Sprintf("bad date %q", value))
+			}
+			date, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return nil, statementError(line, fmt.Sprintf("bad date %q", value))
+			}
+			transaction.Date = date
+		case "TRNAMT":
+			amount, err := parseAmount(value, false)
+			if err != nil {
+				return nil, statementError(line, fmt.Sprintf("bad amount %q", value))
+			}
+			transaction.Amount = amount
+		case "FITID":
+			transaction.ExternalID = value
+		case "NAME":
+			name = value
+		case "MEMO":
+			memo = value
+		case "CURSYM":
+			if aggregate == "CURRENCY" { //суммы операции в этой валюте, а не в валюте выписки
+				transaction.Currency = value
+			}
+		}
+		transaction.Description = name
+		if memo != "" && memo != name {
+			transaction.Description = strings.TrimSpace(name + " " + memo)
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
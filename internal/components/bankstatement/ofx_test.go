@@ -0,0 +1,109 @@
+package bankstatement
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+ENCODING:USASCII
+CHARSET:1252
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKTRANLIST>
+<DTSTART>20200101
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20200115120000[-5:EST]
+<TRNAMT>-42.50
+<FITID>2020011501
+<NAME>Coffee &amp; Co
+<MEMO>Card 1234
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20200131
+<TRNAMT>1,500.00
+<FITID>2020013101
+<NAME>Salary
+<MEMO>Salary
+<CURRENCY><CURRATE>0.9<CURSYM>EUR</CURRENCY>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const xmlStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+  <BANKMSGSRSV1><STMTTRNRS><STMTRS>
+    <CURDEF>RUB</CURDEF>
+    <BANKTRANLIST>
+      <STMTTRN>
+        <TRNTYPE>DEBIT</TRNTYPE>
+        <DTPOSTED>20200203</DTPOSTED>
+        <TRNAMT>-1500.00</TRNAMT>
+        <FITID>A1</FITID>
+        <NAME>Аптека</NAME>
+        <ORIGCURRENCY><CURRATE>75</CURRATE><CURSYM>USD</CURSYM></ORIGCURRENCY>
+      </STMTTRN>
+    </BANKTRANLIST>
+  </STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+func TestParseOFX(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		statement string
+		want      []Transaction
+	}{
+		{"SGML", sgmlStatement, []Transaction{
+			{ExternalID: "2020011501", Date: time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC),
+				Amount: entities.NewMoney(-42.5), Currency: "USD", Description: "Coffee & Co Card 1234"},
+			{ExternalID: "2020013101", Date: time.Date(2020, time.January, 31, 0, 0, 0, 0, time.UTC),
+				Amount: entities.NewMoney(1500), Currency: "EUR", Description: "Salary"},
+		}},
+		// the original currency only informs, the amount stays in the currency of the statement
+		{"XML", xmlStatement, []Transaction{
+			{ExternalID: "A1", Date: time.Date(2020, time.February, 3, 0, 0, 0, 0, time.UTC),
+				Amount: entities.NewMoney(-1500), Currency: "RUB", Description: "Аптека"},
+		}},
+	} {
+		got, err := ParseOFX([]byte(test.statement))
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: ParseOFX = %+v, %v, want %+v", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestParseOFXWindowsCyrillic(t *testing.T) {
+	statement := "OFXHEADER:100\nCHARSET:1251\n<OFX><CURDEF>RUB<STMTTRN><DTPOSTED>20200115<TRNAMT>-10<NAME>\xcf\xee\xea\xf3\xef\xea\xe0</STMTTRN></OFX>"
+	got, err := ParseOFX([]byte(statement))
+	if err != nil || len(got) != 1 || got[0].Description != "Покупка" {
+		t.Errorf("ParseOFX = %+v, %v", got, err)
+	}
+}
+
+func TestParseOFXErrors(t *testing.T) {
+	for _, test := range []struct {
+		statement string
+		line      string
+	}{
+		{"no statement here", "line 1"},
+		{"<OFX>\n<STMTTRN>\n<TRNAMT>-10\n</STMTTRN>\n</OFX>", "line 4"},
+		{"<OFX>\n<STMTTRN>\n<DTPOSTED>2020\n</OFX>", "line 3"},
+		{"<OFX>\n<STMTTRN>\n<DTPOSTED>20200115\n<TRNAMT>ten\n</OFX>", "line 4"},
+		{"<OFX>\n<STMTTRN\n", "line 2"},
+	} {
+		if _, err := ParseOFX([]byte(test.statement)); err == nil || !strings.Contains(err.Error(), test.line) {
+			t.Errorf("ParseOFX(%q) error = %v, want one on %s", test.statement, err, test.line)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package bankstatement
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxTransactions limits how many operations one statement may hold
+const MaxTransactions = 10000
+
+// Transaction is an operation of a bank statement
+type Transaction struct {
+	ExternalID  string         `json:"externalId"` //идентификатор операции в выписке: FITID в OFX, колонка id в CSV
+	Date        time.Time      `json:"date"`
+	Amount      entities.Money `json:"amount"`   //поступления положительные, списания отрицательные
+	Currency    string         `json:"currency"` //код валюты, пустой - в выписке не указан
+	Description string         `json:"description"`
+}
+
+// Parse reads a statement in the given format, csv, ofx or qfx. Without a format OFX files are told
+// by their <OFX> element and everything else is read as CSV.
+func Parse(data []byte, format string, mapping CSVMapping) ([]Transaction, error) {
+	switch strings.ToLower(format) {
+	case "ofx", "qfx":
+		return ParseOFX(data)
+	case "csv":
+		return ParseCSV(data, mapping)
+	case "":
+		if bytes.Contains(bytes.ToUpper(data), []byte("<OFX>")) {
+			return ParseOFX(data)
+		}
+		return ParseCSV(data, mapping)
+	default:
+		return nil, errors.New(codes.BadStatementFile)
+	}
+}
+
+// statementError tells which line of the statement could not be read and why
+func statementError(line int, reason string) error {
+	return fmt.Errorf("%s: line %d: %s", codes.BadStatementFile, line, reason)
+}
+
+// decodeText returns the statement as UTF-8 without a byte order mark. Files that are not valid UTF-8
+// come from Windows, in the Cyrillic code page unless they say they are in the Western one.
+func decodeText(data []byte, western bool) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	var text strings.Builder
+	for _, b := range data {
+		switch {
+		case b < 0x80 || western && b >= 0xA0:
+			text.WriteRune(rune(b))
+		case b >= 0xC0:
+			text.WriteRune(rune(b) - 0xC0 + 'А')
+		case western:
+			text.WriteRune(utf8.RuneError)
+		default:
+			text.WriteRune(windows1251[b-0x80])
+		}
+	}
+	return text.String()
+}
+
+// windows1251 maps the bytes 0x80-0xBF of the Windows Cyrillic code page, the rest being ASCII and А-я
+var windows1251 = []rune("ЂЃ‚ѓ„…†‡€‰Љ‹ЊЌЋЏђ‘’“”•–—\ufffd™љ›њќћџ\u00a0ЎўЈ¤Ґ¦§Ё©Є«¬\u00ad®Ї°±Ііґµ¶·ё№є»јЅѕї")
+
+// parseAmount reads an amount written with any thousands separators, currency signs, a trailing minus or
+// in parentheses. A lone comma followed by three digits separates thousands unless decimalComma is set.
+func parseAmount(text string, decimalComma bool) (entities.Money, error) {
+	text = strings.TrimSpace(text)
+	negative := strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")")
+	var digits strings.Builder
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9' || r == '.' || r == ',':
+			digits.WriteRune(r)
+		case r == '-':
+			negative = true
+		}
+	}
+	number := digits.String()
+	if decimalComma {
+		number = strings.Replace(strings.Replace(number, ".", "", -1), ",", ".", 1)
+	} else {
+		lastComma, lastDot := strings.LastIndex(number, ","), strings.LastIndex(number, ".")
+		switch {
+		case lastComma >= 0 && lastDot >= 0 && lastComma > lastDot:
+			number = strings.Replace(strings.Replace(number, ".", "", -1), ",", ".", 1)
+		case lastComma >= 0 && lastDot >= 0:
+			number = strings.Replace(number, ",", "", -1)
+		case lastComma >= 0 && strings.Count(number, ",") == 1 && len(number)-lastComma-1 != 3:
+			number = strings.Replace(number, ",", ".", 1)
+		case lastComma >= 0:
+			number = strings.Replace(number, ",", "", -1)
+		case strings.Count(number, ".") > 1:
+			number = strings.Replace(number, ".", "", -1)
+		}
+	}
+	if number == "" {
+		return 0, errors.New("no amount")
+	}
+	amount, err := entities.ParseMoney(number)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
@@ -0,0 +1,87 @@
+package bankstatement
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"testing"
+	"time"
+)
+
+func TestParseAmount(t *testing.T) {
+	for _, test := range []struct {
+		text         string
+		decimalComma bool
+		want         entities.Money
+	}{
+		{"1234.56", false, entities.NewMoney(1234.56)},
+		{"-1234.56", false, entities.NewMoney(-1234.56)},
+		{"1,234.56", false, entities.NewMoney(1234.56)},
+		{"1.234,56", false, entities.NewMoney(1234.56)},
+		{"1,234,567.89", false, entities.NewMoney(1234567.89)},
+		{"1.234.567,89", false, entities.NewMoney(1234567.89)},
+		{"1.234.567", false, entities.NewMoney(1234567)},
+		{"1,234", false, entities.NewMoney(1234)},
+		{"1,5", false, entities.NewMoney(1.5)},
+		{"12,34", false, entities.NewMoney(12.34)},
+		{"1 234,56", false, entities.NewMoney(1234.56)},
+		{"1 234,56 ₽", false, entities.NewMoney(1234.56)},
+		{"$1,000.00", false, entities.NewMoney(1000)},
+		{"-$1,000.00", false, entities.NewMoney(-1000)},
+		{"€ 12,50", false, entities.NewMoney(12.5)},
+		{"(100.00)", false, entities.NewMoney(-100)},
+		{"100.00-", false, entities.NewMoney(-100)},
+		{" 42 ", false, entities.NewMoney(42)},
+		{"1,234", true, entities.NewMoney(1.234)},
+		{"1.234,5", true, entities.NewMoney(1234.5)},
+		{"-12,50", true, entities.NewMoney(-12.5)},
+	} {
+		got, err := parseAmount(test.text, test.decimalComma)
+		if err != nil || got != test.want {
+			t.Errorf("parseAmount(%q, %v) = %v, %v, want %v", test.text, test.decimalComma, got, err, test.want)
+		}
+	}
+	for _, text := range []string{"", "abc", "-", "()", "1.2.3,4,5"} {
+		if got, err := parseAmount(text, false); err == nil {
+			t.Errorf("parseAmount(%q) = %v, want an error", text, got)
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	for _, test := range []struct {
+		data    []byte
+		western bool
+		want    string
+	}{
+		{[]byte("\xef\xbb\xbfОплата"), false, "Оплата"},
+		{[]byte{0xCE, 0xEF, 0xEB, 0xE0, 0xF2, 0xE0, ' ', 0xB9, '1'}, false, "Оплата №1"},
+		{[]byte{0xA8, 0xEB, 0xEA, 0xE0}, false, "Ёлка"},
+		{[]byte{'C', 'a', 'f', 0xE9}, true, "Café"},
+	} {
+		if got := decodeText(test.data, test.western); got != test.want {
+			t.Errorf("decodeText(% x, %v) = %q, want %q", test.data, test.western, got, test.want)
+		}
+	}
+}
+
+func TestParseDetectsFormat(t *testing.T) {
+	ofx := []byte("OFXHEADER:100\n<OFX><STMTTRN><DTPOSTED>20200115<TRNAMT>-10.00</STMTTRN></OFX>")
+	csv := []byte("date;amount\n2020-01-15;-10,00\n")
+	for _, test := range []struct {
+		data   []byte
+		format string
+	}{
+		{ofx, ""},
+		{ofx, "QFX"},
+		{csv, ""},
+		{csv, "csv"},
+	} {
+		transactions, err := Parse(test.data, test.format, CSVMapping{})
+		if err != nil || len(transactions) != 1 || transactions[0].Amount != entities.NewMoney(-10) ||
+			!transactions[0].Date.Equal(time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Parse as %q = %+v, %v", test.format, transactions, err)
+		}
+	}
+	if _, err := Parse(csv, "xls", CSVMapping{}); err == nil {
+		t.Error("Parse of an unknown format succeeded")
+	}
+}
@@ -0,0 +1,36 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// WriteCSV writes the table as CSV with a byte order mark, so that spreadsheets detect UTF-8. Locales with
+// a decimal comma separate fields by semicolons, as spreadsheets in those locales expect.
+func WriteCSV(writer io.Writer, table Table, locale Locale) error {
+	if _, err := io.WriteString(writer, "\xef\xbb\xbf"); err != nil {
+		return err
+	}
+	csvWriter := csv.NewWriter(writer)
+	if locale.decimalComma() {
+		csvWriter.Comma = ';'
+	}
+	header := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = locale.Label(column)
+	}
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = locale.formatCell(cell)
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,210 @@
+package export
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	English Locale = "en"
+	Russian Locale = "ru"
+)
+
+// Locale decides the language of column labels and the way numbers and dates are written
+type Locale string
+
+var labels = map[Locale]map[string]string{
+	English: {
+		"number":         "No.",
+		"date":           "Date",
+		"plan":           "Plan",
+		"payment":        "Payment",
+		"principal":      "Principal",
+		"interest":       "Interest",
+		"balance":        "Remaining balance",
+		"status":         "Status",
+		"paidAmount":     "Paid",
+		"type":           "Type",
+		"title":          "Title",
+		"amount":         "Amount",
+		"baseAmount":     "Amount in base currency",
+		"Scheduled":      "Scheduled",
+		"Paid":           "Paid",
+		"PartiallyPaid":  "Partially paid",
+		"Missed":         "Missed",
+		"Income":         "Income",
+		"Expense":        "Expense",
+		"Payment":        "Loan payment",
+		"schedule":       "Schedule",
+		"portfolio":      "Portfolio",
+		"agenda":         "Agenda",
+		"statement":      "Loan statement",
+		"bank":           "Bank",
+		"currency":       "Currency",
+		"loanAmount":     "Loan amount",
+		"interestRate":   "Interest rate",
+		"paymentType":    "Payment type",
+		"Even":           "Annuity",
+		"Differentiated": "Differentiated",
+		"months":         "Term, months",
+		"startDate":      "Start date",
+		"generated":      "Generated",
+		"totalPayments":  "Total payments",
+		"totalPrincipal": "Total principal",
+		"totalInterest":  "Total interest",
+		"totalPaid":      "Total paid",
+		"page":           "Page",
+		"of":             "of",
+	},
+	Russian: {
+		"number":         "№",
+		"date":           "Дата",
+		"plan":           "Кредит",
+		"payment":        "Платеж",
+		"principal":      "Основной долг",
+		"interest":       "Проценты",
+		"balance":        "Остаток долга",
+		"status":         "Статус",
+		"paidAmount":     "Оплачено",
+		"type":           "Тип",
+		"title":          "Название",
+		"amount":         "Сумма",
+		"baseAmount":     "Сумма в базовой валюте",
+		"Scheduled":      "Запланирован",
+		"Paid":           "Оплачен",
+		"PartiallyPaid":  "Оплачен частично",
+		"Missed":         "Пропущен",
+		"Income":         "Доход",
+		"Expense":        "Расход",
+		"Payment":        "Платеж по кредиту",
+		"schedule":       "График",
+		"portfolio":      "Портфель",
+		"agenda":         "Календарь",
+		"statement":      "Выписка по кредиту",
+		"bank":           "Банк",
+		"currency":       "Валюта",
+		"loanAmount":     "Сумма кредита",
+		"interestRate":   "Процентная ставка",
+		"paymentType":    "Тип платежа",
+		"Even":           "Аннуитетный",
+		"Differentiated": "Дифференцированный",
+		"months":         "Срок, месяцев",
+		"startDate":      "Дата начала",
+		"generated":      "Сформировано",
+		"totalPayments":  "Всего платежей",
+		"totalPrincipal": "Всего основного долга",
+		"totalInterest":  "Всего процентов",
+		"totalPaid":      "Всего оплачено",
+		"page":           "Страница",
+		"of":             "из",
+	},
+}
+
+// ParseLocale picks the locale from a language tag or an Accept-Language header, English by default
+func ParseLocale(language string) Locale {
+	for _, tag := range strings.Split(language, ",") {
+		tag = strings.ToLower(strings.TrimSpace(strings.SplitN(tag, ";", 2)[0]))
+		if _, ok := labels[Locale(tag)]; ok {
+			return Locale(tag)
+		}
+		if _, ok := labels[Locale(strings.SplitN(tag, "-", 2)[0])]; ok {
+			return Locale(strings.SplitN(tag, "-", 2)[0])
+		}
+	}
+	return English
+}
+
+// Label translates a label key, leaving unknown keys as they are
+func (locale Locale) Label(key string) string {
+	if label, ok := labels[locale][key]; ok {
+		return label
+	}
+	return key
+}
+
+// decimalComma tells whether the locale writes a comma before decimals
+func (locale Locale) decimalComma() bool {
+	return locale == Russian
+}
+
+func (locale Locale) dateLayout() string {
+	if locale == Russian {
+		return "02.01.2006"
+	}
+	return "2006-01-02"
+}
+
+// formatCell writes the cell as text the way the locale writes amounts and dates
+func (locale Locale) formatCell(cell Cell) string {
+	switch cell.Kind {
+	case MoneyCell:
+		return locale.FormatMoney(cell.Amount, cell.Currency)
+	case DateCell:
+		return cell.Date.Format(locale.dateLayout())
+	default:
+		return cell.Text
+	}
+}
+
+// FormatPercent writes a rate with as many decimals as it needs and a percent sign
+func (locale Locale) FormatPercent(rate float64) string {
+	text := strconv.FormatFloat(rate, 'f', -1, 64)
+	if locale.decimalComma() {
+		text = strings.Replace(text, ".", ",", 1)
+	}
+	return text + "%"
+}
+
+// currencySymbol returns the symbol of the currency or its name if it has none
+func currencySymbol(currency entities.Currency) string {
+	if currency.Symbol != "" {
+		return currency.Symbol
+	}
+	return currency.Name
+}
+
+// FormatMoney writes the amount rounded to the currency with grouped thousands and the currency symbol,
+// like $1,234.56 or CHF 1,234.56 in English and 1 234,56 ₽ in Russian
+func (locale Locale) FormatMoney(amount entities.Money, currency entities.Currency) string {
+	decimals := currency.Decimals()
+	text := amount.Round(currency).String()
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	parts := strings.SplitN(text, ".", 2)
+	whole, fraction := parts[0], ""
+	if len(parts) == 2 {
+		fraction = parts[1]
+	}
+	fraction += strings.Repeat("0", decimals-len(fraction))
+
+	separator, point := ",", "."
+	if locale.decimalComma() {
+		separator, point = " ", ","
+	}
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(separator)
+		}
+		grouped.WriteRune(digit)
+	}
+	number := grouped.String()
+	if decimals > 0 {
+		number += point + fraction
+	}
+	symbol := currencySymbol(currency)
+	if symbol == "" {
+		return sign + number
+	}
+	if locale.decimalComma() {
+		return sign + number + " " + symbol
+	}
+	if utf8.RuneCountInString(symbol) > 1 {
+		return sign + symbol + "\u00a0" + number
+	}
+	return sign + symbol + number
+}
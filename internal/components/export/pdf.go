@@ -0,0 +1,40 @@
+package export
+
+import (
+	_ "embed"
+	"github.com/go-pdf/fpdf"
+	"time"
+)
+
+const (
+	pageWidth  = 595.28 //A4 в пунктах
+	pageHeight = 841.89
+	pageMargin = 40.0
+	fontFamily = "DejaVuSans"
+)
+
+// The fonts of statements are built into the binary. DejaVu Sans covers Latin, Cyrillic and the currency
+// signs, so statements write their text as it is; the PDF embeds the subset of the glyphs it uses.
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	regularFont []byte
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	boldFont []byte
+)
+
+// newPDF returns an empty A4 document measured in points from the top left corner that breaks pages
+// only where it is told to
+func newPDF(created time.Time) *fpdf.Fpdf {
+	document := fpdf.New("P", "pt", "A4", "")
+	document.SetMargins(pageMargin, pageMargin, pageMargin)
+	document.SetAutoPageBreak(false, 0)
+	document.SetCreationDate(created)
+	document.AddUTF8FontFromBytes(fontFamily, "", regularFont)
+	document.AddUTF8FontFromBytes(fontFamily, "B", boldFont)
+	return document
+}
+
+// textRight writes a line of text in the current font with its baseline ending at x, y
+func textRight(document *fpdf.Fpdf, x, y float64, text string) {
+	document.Text(x-document.GetStringWidth(text), y, text)
+}
@@ -0,0 +1,154 @@
+package export
+
+import (
+	"fmt"
+	"github.com/go-pdf/fpdf"
+	"io"
+	"time"
+)
+
+// Statement is a printable document: a title, labelled details, a table and labelled totals
+type Statement struct {
+	Title     string
+	Details   []Field
+	Table     Table
+	Totals    []Field
+	Generated time.Time
+}
+
+// Field is a labelled value of a statement
+type Field struct {
+	Label string //ключ подписи из labels
+	Value Cell
+}
+
+const (
+	statementFontSize = 10.0
+	statementLeading  = 15.0
+	tableMaxFontSize  = 9.0
+	tableGap          = 12.0 //пунктов между колонками таблицы при наибольшем размере шрифта
+	footerHeight      = 24.0
+)
+
+// WriteStatementPDF writes the statement as an A4 PDF. The table continues over as many pages as it needs,
+// repeating its header on each, and every page ends with the generation time and the page number.
+func WriteStatementPDF(writer io.Writer, statement Statement, locale Locale) error {
+	// The footers show the number of pages, which is known once the statement is laid out
+	pages := writeStatement(newPDF(statement.Generated), statement, locale, 0).PageCount()
+	return writeStatement(newPDF(statement.Generated), statement, locale, pages).Output(writer)
+}
+
+// writeStatement lays the statement out on the pages of the document, numbering them out of pages
+func writeStatement(document *fpdf.Fpdf, statement Statement, locale Locale, pages int) *fpdf.Fpdf {
+	document.SetTitle(statement.Title, true)
+	generated := statement.Generated.Format(locale.dateLayout() + " 15:04")
+	document.SetFooterFunc(func() {
+		footer := pageHeight - pageMargin
+		document.SetLineWidth(0.5)
+		document.Line(pageMargin, footer-statementFontSize-2, pageWidth-pageMargin, footer-statementFontSize-2)
+		document.SetFont(fontFamily, "", 8)
+		document.Text(pageMargin, footer, fmt.Sprintf("%s: %s", locale.Label("generated"), generated))
+		textRight(document, pageWidth-pageMargin, footer,
+			fmt.Sprintf("%s %d %s %d", locale.Label("page"), document.PageNo(), locale.Label("of"), pages))
+	})
+
+	document.AddPage()
+	bottom := pageHeight - pageMargin - footerHeight
+	y := pageMargin + 16
+	document.SetFont(fontFamily, "B", 16)
+	document.Text(pageMargin, y, statement.Title)
+	y += 2 * statementLeading
+	y = writeFields(document, statement.Details, locale, y)
+
+	// Columns are measured at the largest font size and the whole table is scaled down to fit the page
+	header := make([]string, len(statement.Table.Columns))
+	widths := make([]float64, len(statement.Table.Columns))
+	document.SetFont(fontFamily, "B", tableMaxFontSize)
+	for i, column := range statement.Table.Columns {
+		header[i] = locale.Label(column)
+		widths[i] = document.GetStringWidth(header[i])
+	}
+	document.SetFont(fontFamily, "", tableMaxFontSize)
+	rows := make([][]string, len(statement.Table.Rows))
+	for r, row := range statement.Table.Rows {
+		rows[r] = make([]string, len(row))
+		for i, cell := range row {
+			rows[r][i] = locale.formatCell(cell)
+			if width := document.GetStringWidth(rows[r][i]); i < len(widths) && width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+	tableWidth := 0.0
+	for _, width := range widths {
+		tableWidth += width + tableGap
+	}
+	scale := 1.0
+	if fit := (pageWidth - 2*pageMargin) / tableWidth; tableWidth > 0 && fit < scale {
+		scale = fit
+	}
+	size := tableMaxFontSize * scale
+	rowHeight := size * 1.6
+	writeRow := func(style string, texts []string, kinds []CellKind) {
+		document.SetFont(fontFamily, style, size)
+		x := pageMargin
+		for i, text := range texts {
+			if i >= len(widths) {
+				break
+			}
+			width := widths[i] * scale
+			if i < len(kinds) && kinds[i] == MoneyCell {
+				textRight(document, x+width, y, text)
+			} else {
+				document.Text(x, y, text)
+			}
+			x += width + tableGap*scale
+		}
+		y += rowHeight
+	}
+	writeRule := func() {
+		document.SetLineWidth(0.5)
+		document.Line(pageMargin, y-rowHeight+size*0.5, pageWidth-pageMargin, y-rowHeight+size*0.5)
+	}
+	writeHeader := func() {
+		y += rowHeight
+		writeRow("B", header, nil)
+		writeRule()
+	}
+
+	y += statementLeading
+	writeHeader()
+	for r, row := range rows {
+		if y > bottom {
+			document.AddPage()
+			y = pageMargin
+			writeHeader()
+		}
+		kinds := make([]CellKind, len(statement.Table.Rows[r]))
+		for i, cell := range statement.Table.Rows[r] {
+			kinds[i] = cell.Kind
+		}
+		writeRow("", row, kinds)
+	}
+	writeRule()
+
+	y += statementLeading
+	if y+float64(len(statement.Totals))*statementLeading > bottom {
+		document.AddPage()
+		y = pageMargin + statementLeading
+	}
+	writeFields(document, statement.Totals, locale, y)
+	return document
+}
+
+// writeFields writes a label and value line per field starting at y and returns the y after them
+func writeFields(document *fpdf.Fpdf, fields []Field, locale Locale, y float64) float64 {
+	for _, field := range fields {
+		document.SetFont(fontFamily, "B", statementFontSize)
+		document.Text(pageMargin, y, locale.Label(field.Label))
+		document.SetFont(fontFamily, "", statementFontSize)
+		document.Text(pageMargin+160, y, locale.formatCell(field.Value))
+		y += statementLeading
+	}
+	return y
+}
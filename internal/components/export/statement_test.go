@@ -0,0 +1,82 @@
+package export
+
+import (
+	"bytes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func testStatement(payments int) Statement {
+	rub := entities.Currency{Symbol: "₽", MinorUnits: 100}
+	statement := Statement{
+		Title:     "Выписка по кредиту",
+		Details:   []Field{{Label: "bank", Value: Text("Сбербанк")}},
+		Table:     Table{Columns: []string{"date", "payment"}},
+		Totals:    []Field{{Label: "totalPayments", Value: Amount(entities.NewMoney(1000), rub)}},
+		Generated: time.Date(2020, time.January, 15, 12, 0, 0, 0, time.UTC),
+	}
+	for i := 0; i < payments; i++ {
+		statement.Table.Rows = append(statement.Table.Rows,
+			[]Cell{Date(time.Date(2020, time.February, 15, 0, 0, 0, 0, time.UTC).AddDate(0, i, 0)), Amount(entities.NewMoney(1000), rub)})
+	}
+	return statement
+}
+
+// utf16Text is the text as the content of a page writes it in a TrueType font
+func utf16Text(text string) []byte {
+	var encoded []byte
+	for _, unit := range utf16.Encode([]rune(text)) {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
+	}
+	return encoded
+}
+
+func TestWriteStatementPDF(t *testing.T) {
+	var output bytes.Buffer
+	if err := WriteStatementPDF(&output, testStatement(1), Russian); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(output.Bytes(), []byte("%PDF-")) {
+		t.Error("statement is not a PDF")
+	}
+	for _, entry := range []string{"/Subtype /Type0", "/Encoding /Identity-H", "/FontFile2", "/ToUnicode", "/CIDToGIDMap"} {
+		if !bytes.Contains(output.Bytes(), []byte(entry)) {
+			t.Errorf("statement has no %s", entry)
+		}
+	}
+	if bytes.Contains(output.Bytes(), []byte("/Type1")) {
+		t.Error("statement uses a standard font")
+	}
+	if size := output.Len(); size > 200<<10 {
+		t.Errorf("statement takes %d bytes, the fonts are not subset", size)
+	}
+}
+
+func TestWriteStatementText(t *testing.T) {
+	for _, test := range []struct {
+		payments int
+		pages    int
+	}{
+		{1, 1},
+		{120, 3},
+	} {
+		document := newPDF(time.Time{})
+		document.SetCompression(false)
+		writeStatement(document, testStatement(test.payments), Russian, test.pages)
+		var output bytes.Buffer
+		if err := document.Output(&output); err != nil {
+			t.Fatal(err)
+		}
+		if pages := document.PageCount(); pages != test.pages {
+			t.Errorf("statement of %d payments takes %d pages, want %d", test.payments, pages, test.pages)
+		}
+		// Cyrillic and the ruble sign are written as they are rather than transliterated
+		for _, text := range []string{"Выписка по кредиту", "Сбербанк", "1\u00a0000,00\u00a0₽", "Страница 1 из " + string(rune('0'+test.pages))} {
+			if !bytes.Contains(output.Bytes(), utf16Text(text)) {
+				t.Errorf("statement of %d payments has no text %q", test.payments, text)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package export
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+const (
+	TextCell  CellKind = 0
+	MoneyCell CellKind = 1
+	DateCell  CellKind = 2
+)
+
+type CellKind int
+
+// Table is a sheet of an export: a header row of column labels and rows of cells
+type Table struct {
+	Name    string
+	Columns []string //ключи подписей из labels
+	Rows    [][]Cell
+}
+
+type Cell struct {
+	Kind     CellKind
+	Text     string
+	Amount   entities.Money
+	Currency entities.Currency
+	Date     time.Time
+}
+
+func Text(text string) Cell {
+	return Cell{Kind: TextCell, Text: text}
+}
+
+func Amount(amount entities.Money, currency entities.Currency) Cell {
+	return Cell{Kind: MoneyCell, Amount: amount, Currency: currency}
+}
+
+func Date(date time.Time) Cell {
+	return Cell{Kind: DateCell, Date: date}
+}
@@ -0,0 +1,314 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cell styles of the workbook; money styles follow, one per currency number format
+const (
+	defaultStyle = 0
+	headerStyle  = 1
+	dateStyle    = 2
+	moneyStyle   = 3
+)
+
+// firstCustomFormat is the first id of a number format not built into spreadsheets
+const firstCustomFormat = 164
+
+const spreadsheetNamespace = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+
+// excelEpoch is the day spreadsheets count date serial numbers from
+var excelEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+var xlsxStaticFiles = [][2]string{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+}
+
+type xlsxWorkbook struct {
+	XMLName       xml.Name    `xml:"workbook"`
+	Namespace     string      `xml:"xmlns,attr"`
+	Relationships string      `xml:"xmlns:r,attr"`
+	Sheets        []xlsxSheet `xml:"sheets>sheet"`
+}
+
+type xlsxSheet struct {
+	Name    string `xml:"name,attr"`
+	SheetID int    `xml:"sheetId,attr"`
+	ID      string `xml:"r:id,attr"`
+}
+
+type xlsxWorksheet struct {
+	XMLName   xml.Name     `xml:"worksheet"`
+	Namespace string       `xml:"xmlns,attr"`
+	Columns   []xlsxColumn `xml:"cols>col"`
+	Rows      []xlsxRow    `xml:"sheetData>row"`
+}
+
+type xlsxColumn struct {
+	Min         int     `xml:"min,attr"`
+	Max         int     `xml:"max,attr"`
+	Width       float64 `xml:"width,attr"`
+	CustomWidth int     `xml:"customWidth,attr"`
+}
+
+type xlsxRow struct {
+	Number int        `xml:"r,attr"`
+	Cells  []xlsxCell `xml:"c"`
+}
+
+type xlsxCell struct {
+	Reference string  `xml:"r,attr"`
+	Style     int     `xml:"s,attr,omitempty"`
+	Type      string  `xml:"t,attr,omitempty"`
+	Value     string  `xml:"v,omitempty"`
+	Text      *string `xml:"is>t"`
+}
+
+type xlsxStyleSheet struct {
+	XMLName       xml.Name             `xml:"styleSheet"`
+	Namespace     string               `xml:"xmlns,attr"`
+	NumberFormats *xlsxNumberFormats   `xml:"numFmts"`
+	Fonts         xlsxFonts            `xml:"fonts"`
+	Fills         xlsxFills            `xml:"fills"`
+	Borders       xlsxBorders          `xml:"borders"`
+	CellStyles    xlsxCellStyleFormats `xml:"cellStyleXfs"`
+	CellFormats   xlsxCellFormats      `xml:"cellXfs"`
+}
+
+type xlsxNumberFormats struct {
+	Count   int                `xml:"count,attr"`
+	Formats []xlsxNumberFormat `xml:"numFmt"`
+}
+
+type xlsxNumberFormat struct {
+	ID   int    `xml:"numFmtId,attr"`
+	Code string `xml:"formatCode,attr"`
+}
+
+type xlsxFonts struct {
+	Count int        `xml:"count,attr"`
+	Fonts []xlsxFont `xml:"font"`
+}
+
+type xlsxFont struct {
+	Bold *struct{} `xml:"b"`
+	Size xlsxValue `xml:"sz"`
+	Name xlsxValue `xml:"name"`
+}
+
+type xlsxValue struct {
+	Value string `xml:"val,attr"`
+}
+
+type xlsxFills struct {
+	Count int        `xml:"count,attr"`
+	Fills []xlsxFill `xml:"fill"`
+}
+
+type xlsxFill struct {
+	Pattern xlsxPatternFill `xml:"patternFill"`
+}
+
+type xlsxPatternFill struct {
+	Type string `xml:"patternType,attr"`
+}
+
+type xlsxBorders struct {
+	Count   int          `xml:"count,attr"`
+	Borders []xlsxBorder `xml:"border"`
+}
+
+type xlsxBorder struct {
+	Left   struct{} `xml:"left"`
+	Right  struct{} `xml:"right"`
+	Top    struct{} `xml:"top"`
+	Bottom struct{} `xml:"bottom"`
+}
+
+type xlsxCellStyleFormats struct {
+	Count   int              `xml:"count,attr"`
+	Formats []xlsxCellFormat `xml:"xf"`
+}
+
+type xlsxCellFormats struct {
+	Count   int              `xml:"count,attr"`
+	Formats []xlsxCellFormat `xml:"xf"`
+}
+
+type xlsxCellFormat struct {
+	NumberFormatID    int `xml:"numFmtId,attr"`
+	FontID            int `xml:"fontId,attr"`
+	FillID            int `xml:"fillId,attr"`
+	BorderID          int `xml:"borderId,attr"`
+	ApplyNumberFormat int `xml:"applyNumberFormat,attr,omitempty"`
+	ApplyFont         int `xml:"applyFont,attr,omitempty"`
+}
+
+// WriteXLSX writes the table as a workbook of one sheet. Amounts stay numbers formatted with the currency
+// symbol and dates stay dates, so the spreadsheet can still calculate with them.
+func WriteXLSX(writer io.Writer, table Table, locale Locale) error {
+	formats := map[string]int{}
+	var formatCodes []string
+	worksheet := xlsxWorksheet{Namespace: spreadsheetNamespace}
+	header := xlsxRow{Number: 1}
+	for i, column := range table.Columns {
+		label := locale.Label(column)
+		header.Cells = append(header.Cells, xlsxCell{Reference: cellReference(i, 1), Style: headerStyle, Type: "inlineStr", Text: &label})
+		worksheet.Columns = append(worksheet.Columns, xlsxColumn{Min: i + 1, Max: i + 1, Width: columnWidth(label), CustomWidth: 1})
+	}
+	worksheet.Rows = append(worksheet.Rows, header)
+	for r, row := range table.Rows {
+		xlsxRow := xlsxRow{Number: r + 2}
+		for i, cell := range row {
+			xlsxCell := xlsxCell{Reference: cellReference(i, r+2)}
+			switch cell.Kind {
+			case MoneyCell:
+				code := moneyFormat(cell, locale)
+				if _, ok := formats[code]; !ok {
+					formats[code] = len(formatCodes)
+					formatCodes = append(formatCodes, code)
+				}
+				xlsxCell.Style = moneyStyle + formats[code]
+				xlsxCell.Value = cell.Amount.Round(cell.Currency).String()
+			case DateCell:
+				xlsxCell.Style = dateStyle
+				xlsxCell.Value = strconv.FormatFloat(dateSerial(cell.Date), 'f', -1, 64)
+			default:
+				text := cell.Text
+				xlsxCell.Type, xlsxCell.Text = "inlineStr", &text
+			}
+			xlsxRow.Cells = append(xlsxRow.Cells, xlsxCell)
+		}
+		worksheet.Rows = append(worksheet.Rows, xlsxRow)
+	}
+
+	sheetName := locale.Label(table.Name)
+	if len([]rune(sheetName)) > 31 {
+		sheetName = string([]rune(sheetName)[:31])
+	}
+	workbook := xlsxWorkbook{
+		Namespace:     spreadsheetNamespace,
+		Relationships: "http://schemas.openxmlformats.org/officeDocument/2006/relationships",
+		Sheets:        []xlsxSheet{{Name: sheetName, SheetID: 1, ID: "rId1"}},
+	}
+
+	archive := zip.NewWriter(writer)
+	for _, file := range xlsxStaticFiles {
+		if err := writeZipFile(archive, file[0], []byte(file[1])); err != nil {
+			return err
+		}
+	}
+	parts := []struct {
+		name    string
+		content interface{}
+	}{
+		{"xl/workbook.xml", workbook},
+		{"xl/worksheets/sheet1.xml", worksheet},
+		{"xl/styles.xml", styleSheet(formatCodes)},
+	}
+	for _, part := range parts {
+		data, err := xml.Marshal(part.content)
+		if err != nil {
+			return err
+		}
+		if err := writeZipFile(archive, part.name, append([]byte(xml.Header), data...)); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// styleSheet declares the fonts and cell formats: plain, bold for the header, dates and a money format per code
+func styleSheet(formatCodes []string) xlsxStyleSheet {
+	plain := xlsxFont{Size: xlsxValue{"11"}, Name: xlsxValue{"Calibri"}}
+	bold := plain
+	bold.Bold = &struct{}{}
+	cellFormats := []xlsxCellFormat{
+		{},
+		{FontID: 1, ApplyFont: 1},
+		{NumberFormatID: 14, ApplyNumberFormat: 1},
+	}
+	var numberFormats []xlsxNumberFormat
+	for i, code := range formatCodes {
+		numberFormats = append(numberFormats, xlsxNumberFormat{ID: firstCustomFormat + i, Code: code})
+		cellFormats = append(cellFormats, xlsxCellFormat{NumberFormatID: firstCustomFormat + i, ApplyNumberFormat: 1})
+	}
+	styles := xlsxStyleSheet{
+		Namespace:   spreadsheetNamespace,
+		Fonts:       xlsxFonts{Count: 2, Fonts: []xlsxFont{plain, bold}},
+		Fills:       xlsxFills{Count: 2, Fills: []xlsxFill{{xlsxPatternFill{"none"}}, {xlsxPatternFill{"gray125"}}}},
+		Borders:     xlsxBorders{Count: 1, Borders: []xlsxBorder{{}}},
+		CellStyles:  xlsxCellStyleFormats{Count: 1, Formats: []xlsxCellFormat{{}}},
+		CellFormats: xlsxCellFormats{Count: len(cellFormats), Formats: cellFormats},
+	}
+	if len(numberFormats) > 0 {
+		styles.NumberFormats = &xlsxNumberFormats{Count: len(numberFormats), Formats: numberFormats}
+	}
+	return styles
+}
+
+// moneyFormat returns the number format of the amount, with the symbol placed as FormatMoney places it
+func moneyFormat(cell Cell, locale Locale) string {
+	code := "#,##0"
+	if decimals := cell.Currency.Decimals(); decimals > 0 {
+		code += "." + strings.Repeat("0", decimals)
+	}
+	symbol := strings.Replace(currencySymbol(cell.Currency), `"`, `""`, -1)
+	if symbol == "" {
+		return code
+	}
+	if locale.decimalComma() {
+		return code + `\ "` + symbol + `"`
+	}
+	return `"` + symbol + `"` + code
+}
+
+// cellReference returns the A1 reference of the column, counted from zero, and the row
+func cellReference(column int, row int) string {
+	name := ""
+	for column++; column > 0; column = (column - 1) / 26 {
+		name = string(rune('A'+(column-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
+
+func columnWidth(label string) float64 {
+	width := float64(len([]rune(label))) + 4
+	if width < 14 {
+		return 14
+	}
+	return width
+}
+
+// dateSerial returns the day of the date as spreadsheets count them
+func dateSerial(date time.Time) float64 {
+	year, month, day := date.Date()
+	return float64(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Sub(excelEpoch).Hours() / 24)
+}
+
+func writeZipFile(archive *zip.Writer, name string, content []byte) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	return err
+}
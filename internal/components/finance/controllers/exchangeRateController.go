@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type ExchangeRateController struct {
+	db              gorm.DB
+	exchangeService services.ExchangeService
+}
+
+func NewExchangeRateController(db *gorm.DB, exchangeService services.ExchangeService) ExchangeRateController {
+	return ExchangeRateController{db: *db, exchangeService: exchangeService}
+}
+
+// GetExchangeRates lists stored rates, optionally only those of the currencyId and quoteCurrencyId query parameters
+func (erc ExchangeRateController) GetExchangeRates(c *gin.Context) {
+	var exchangeRates []entities.ExchangeRate
+	query := erc.db.Order("rate_date")
+	if currencyId := c.Query("currencyId"); currencyId != "" {
+		query = query.Where("currency_id = ?", currencyId)
+	}
+	if quoteCurrencyId := c.Query("quoteCurrencyId"); quoteCurrencyId != "" {
+		query = query.Where("quote_currency_id = ?", quoteCurrencyId)
+	}
+	query.Find(&exchangeRates)
+	c.JSON(http.StatusOK, gin.H{"exchangeRates": exchangeRates})
+}
+
+func (erc ExchangeRateController) AddExchangeRate(c *gin.Context) {
+	var exchangeRate entities.ExchangeRate
+	if err := c.ShouldBindJSON(&exchangeRate); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	var count int
+	erc.db.Model(&entities.Currency{}).Where("id IN (?)", []uint{exchangeRate.CurrencyID, exchangeRate.QuoteCurrencyID}).Count(&count)
+	if exchangeRate.Rate <= 0 || exchangeRate.RateDate.IsZero() || exchangeRate.CurrencyID == exchangeRate.QuoteCurrencyID || count != 2 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadExchangeRate})
+		return
+	}
+	exchangeRate, err := erc.exchangeService.SaveRate(exchangeRate)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"exchangeRate": exchangeRate})
+}
+
+// ImportExchangeRates loads rates from a CSV file sent as the "file" form field or as the request body.
+// Files with a column per quote currency need the currency query parameter naming what they price.
+func (erc ExchangeRateController) ImportExchangeRates(c *gin.Context) {
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadExchangeRateFile})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadExchangeRateFile})
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	}
+	count, err := erc.exchangeService.ImportRates(reader, c.Query("currency"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"imported": count})
+}
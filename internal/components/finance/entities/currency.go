@@ -3,9 +3,30 @@ package entities
 import "time"
 
 type Currency struct {
-	ID        uint      `gorm:"primary_key" json:"id"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
-	Name      string    `json:"name" gorm:"type:varchar(100);unique_index"`
-	Symbol    string    `json:"symbol"`
+	ID         uint         `gorm:"primary_key" json:"id"`
+	CreatedAt  time.Time    `json:"-"`
+	UpdatedAt  time.Time    `json:"-"`
+	Name       string       `json:"name" gorm:"type:varchar(100);unique_index"`
+	Symbol     string       `json:"symbol"`
+	MinorUnits int          `json:"minorUnits"` //сколько минимальных единиц в основной: 100 копеек в рубле, 0 - тоже 100
+	Rounding   RoundingMode `json:"rounding"`
+}
+
+// Step returns the smallest amount of money that exists in the currency
+func (c Currency) Step() Money {
+	switch c.MinorUnits {
+	case 1, 10, 100, 1000, 10000:
+		return Money(moneyScale / c.MinorUnits)
+	default:
+		return Money(moneyScale / 100)
+	}
+}
+
+// Decimals returns how many digits after the decimal point amounts in the currency have
+func (c Currency) Decimals() int {
+	decimals := 0
+	for step := c.Step(); step < moneyScale; step *= 10 {
+		decimals++
+	}
+	return decimals
 }
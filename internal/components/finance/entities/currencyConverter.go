@@ -0,0 +1,61 @@
+package entities
+
+import "time"
+
+// CurrencyConverter converts amounts into Currency at the exchange rates known on Date
+type CurrencyConverter struct {
+	Currency Currency
+	Date     time.Time
+	Rates    []ExchangeRate
+}
+
+// Convert converts an amount in the given currency, rounding it to the converter currency. Amounts
+// without a currency and conversions without a target currency are taken as they are.
+func (cc CurrencyConverter) Convert(amount Money, currencyID uint) (Money, bool) {
+	rate, ok := cc.RateAt(currencyID, cc.Currency.ID)
+	if !ok {
+		return 0, false
+	}
+	return amount.Mul(rate).Round(cc.Currency), true
+}
+
+// RateAt returns how many units of one currency buy one unit of the other. It uses the latest rate
+// published on or before Date directly, inverted, or crossed through a third currency.
+func (cc CurrencyConverter) RateAt(from uint, to uint) (float64, bool) {
+	if from == to || from == 0 || to == 0 {
+		return 1, true
+	}
+	if rate, ok := cc.pairRate(from, to); ok {
+		return rate, true
+	}
+	for _, exchangeRate := range cc.Rates {
+		for _, pivot := range []uint{exchangeRate.CurrencyID, exchangeRate.QuoteCurrencyID} {
+			if pivot == from || pivot == to {
+				continue
+			}
+			fromRate, fromOk := cc.pairRate(from, pivot)
+			toRate, toOk := cc.pairRate(pivot, to)
+			if fromOk && toOk {
+				return fromRate * toRate, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (cc CurrencyConverter) pairRate(from uint, to uint) (float64, bool) {
+	var rate float64
+	var rateDate time.Time
+	var found bool
+	for _, exchangeRate := range cc.Rates {
+		if exchangeRate.RateDate.After(cc.Date) || exchangeRate.Rate <= 0 || found && exchangeRate.RateDate.Before(rateDate) {
+			continue
+		}
+		if exchangeRate.CurrencyID == from && exchangeRate.QuoteCurrencyID == to {
+			rate, rateDate, found = exchangeRate.Rate, exchangeRate.RateDate, true
+		} else if exchangeRate.CurrencyID == to && exchangeRate.QuoteCurrencyID == from {
+			rate, rateDate, found = 1/exchangeRate.Rate, exchangeRate.RateDate, true
+		}
+	}
+	return rate, found
+}
@@ -0,0 +1,16 @@
+package entities
+
+import "time"
+
+// ExchangeRate is the price of one unit of Currency in QuoteCurrency on RateDate
+type ExchangeRate struct {
+	ID              uint      `gorm:"primary_key" json:"id"`
+	CreatedAt       time.Time `json:"-"`
+	UpdatedAt       time.Time `json:"-"`
+	Currency        Currency  `json:"-" gorm:"save_associations:false"`
+	CurrencyID      uint      `json:"currencyId" gorm:"unique_index:idx_exchange_rate"`
+	QuoteCurrency   Currency  `json:"-" gorm:"save_associations:false"`
+	QuoteCurrencyID uint      `json:"quoteCurrencyId" gorm:"unique_index:idx_exchange_rate"`
+	RateDate        time.Time `json:"rateDate" gorm:"unique_index:idx_exchange_rate"`
+	Rate            float64   `json:"rate"` //сколько единиц QuoteCurrency стоит одна единица Currency
+}
@@ -0,0 +1,237 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Money is an exact amount of money kept with four decimal places
+type Money int64
+
+const moneyScale = 10000
+
+var errBadMoney = errors.New("money amount must be a decimal number")
+var errMoneyOverflow = errors.New("money amount is too large")
+
+// NewMoney converts a floating point amount to money, rounding it to four decimal places
+func NewMoney(amount float64) Money {
+	return Money(math.Round(amount * moneyScale))
+}
+
+// ParseMoney reads a decimal amount such as "-1234.56" without going through floating point
+func ParseMoney(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if strings.ContainsAny(value, "eE") {
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, errBadMoney
+		}
+		if math.Abs(amount*moneyScale) >= math.MaxInt64 {
+			return 0, errMoneyOverflow
+		}
+		return NewMoney(amount), nil
+	}
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+	whole, fraction := value, ""
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		whole, fraction = value[:dot], value[dot+1:]
+	}
+	if whole == "" && fraction == "" {
+		return 0, errBadMoney
+	}
+	for _, digit := range fraction {
+		if digit < '0' || digit > '9' {
+			return 0, errBadMoney
+		}
+	}
+	roundUp := len(fraction) > 4 && fraction[4] >= '5'
+	if len(fraction) > 4 {
+		fraction = fraction[:4]
+	}
+	fraction += strings.Repeat("0", 4-len(fraction))
+	var amount int64
+	for _, digit := range whole + fraction {
+		if digit < '0' || digit > '9' {
+			return 0, errBadMoney
+		}
+		if amount > (math.MaxInt64-int64(digit-'0'))/10 {
+			return 0, errMoneyOverflow
+		}
+		amount = amount*10 + int64(digit-'0')
+	}
+	if roundUp {
+		if amount == math.MaxInt64 {
+			return 0, errMoneyOverflow
+		}
+		amount++
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money(amount), nil
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / moneyScale
+}
+
+// String formats the amount as a decimal number without trailing zeros
+func (m Money) String() string {
+	sign := ""
+	amount := int64(m)
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	fraction := strings.TrimRight(fmt.Sprintf("%04d", amount%moneyScale), "0")
+	if fraction == "" {
+		return fmt.Sprintf("%s%d", sign, amount/moneyScale)
+	}
+	return fmt.Sprintf("%s%d.%s", sign, amount/moneyScale, fraction)
+}
+
+// Mul multiplies the amount by a factor such as an interest rate, keeping four decimal places. The product
+// is taken exactly and rounded half away from zero once; products beyond the range of Money are capped at
+// its limits and a factor that is not a number gives zero.
+func (m Money) Mul(factor float64) Money {
+	switch {
+	case math.IsNaN(factor):
+		return 0
+	case math.IsInf(factor, 0):
+		return saturate(m.sign() * int(math.Copysign(1, factor)))
+	}
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(m)), new(big.Rat).SetFloat64(factor))
+	quotient, remainder := new(big.Int).QuoRem(product.Num(), product.Denom(), new(big.Int))
+	if remainder.Abs(remainder).Lsh(remainder, 1).Cmp(product.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(product.Sign())))
+	}
+	if !quotient.IsInt64() {
+		return saturate(quotient.Sign())
+	}
+	return Money(quotient.Int64())
+}
+
+// Div splits the amount into n equal parts, keeping four decimal places and rounding half away from zero
+func (m Money) Div(n uint) Money {
+	if n == 0 {
+		return m
+	}
+	if n > math.MaxInt64 {
+		return 0
+	}
+	quotient, remainder := int64(m)/int64(n), int64(m)%int64(n)
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	// remainder >= n - remainder compares twice the remainder with n without overflowing
+	if remainder >= int64(n)-remainder {
+		quotient += int64(m.sign())
+	}
+	return Money(quotient)
+}
+
+func (m Money) sign() int {
+	switch {
+	case m > 0:
+		return 1
+	case m < 0:
+		return -1
+	}
+	return 0
+}
+
+// saturate returns the largest amount of the given sign
+func saturate(sign int) Money {
+	switch {
+	case sign > 0:
+		return math.MaxInt64
+	case sign < 0:
+		return -math.MaxInt64
+	}
+	return 0
+}
+
+// Round rounds the amount to the smallest unit of the currency using its rounding mode
+func (m Money) Round(currency Currency) Money {
+	step := currency.Step()
+	amount, sign := m, Money(1)
+	if amount < 0 {
+		amount, sign = -amount, -1
+	}
+	units, rest := amount/step, amount%step
+	switch {
+	case rest == 0:
+	case currency.Rounding == Down:
+	case currency.Rounding == Up:
+		units++
+	case currency.Rounding == HalfEven:
+		if 2*rest > step || 2*rest == step && units%2 == 1 {
+			units++
+		}
+	default:
+		if 2*rest >= step {
+			units++
+		}
+	}
+	return sign * units * step
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts amounts both as JSON numbers and as strings
+func (m *Money) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "null" || value == "" {
+		*m = 0
+		return nil
+	}
+	amount, err := ParseMoney(value)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(value interface{}) error {
+	var err error
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case []byte:
+		*m, err = ParseMoney(string(v))
+	case string:
+		*m, err = ParseMoney(v)
+	case float64:
+		if math.IsNaN(v) || math.Abs(v*moneyScale) >= math.MaxInt64 {
+			return errMoneyOverflow
+		}
+		*m = NewMoney(v)
+	case int64:
+		if v > math.MaxInt64/moneyScale || v < -math.MaxInt64/moneyScale {
+			return errMoneyOverflow
+		}
+		*m = Money(v * moneyScale)
+	default:
+		err = errBadMoney
+	}
+	return err
+}
+
+func (Money) GormDataType(gorm.Dialect) string {
+	return "numeric(20,4)"
+}
@@ -0,0 +1,224 @@
+package entities
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		want  Money
+		fails bool
+	}{
+		{value: "100", want: 1000000},
+		{value: "0", want: 0},
+		{value: "1234.56", want: 12345600},
+		{value: ".5", want: 5000},
+		{value: "7.", want: 70000},
+		{value: " 42.1 ", want: 421000},
+		{value: "+3.25", want: 32500},
+		{value: "-1234.56", want: -12345600},
+		{value: "-100", want: -1000000},
+		{value: "0.00005", want: 1},
+		{value: "0.00004", want: 0},
+		{value: "1.99995", want: 20000},
+		{value: "1.5e3", want: 15000000},
+		{value: "922337203685477.5807", want: 9223372036854775807},
+		{value: "-922337203685477.5807", want: -9223372036854775807},
+		{value: "922337203685477.58075", fails: true},
+		{value: "922337203685478", fails: true},
+		{value: "99999999999999999999", fails: true},
+		{value: "-99999999999999999999", fails: true},
+		{value: "1e20", fails: true},
+		{value: "", fails: true},
+		{value: "-", fails: true},
+		{value: ".", fails: true},
+		{value: "abc", fails: true},
+		{value: "12a", fails: true},
+		{value: "1.2.3", fails: true},
+		{value: "1,5", fails: true},
+		{value: "1.x", fails: true},
+		{value: "--1", fails: true},
+		{value: "1e", fails: true},
+	} {
+		got, err := ParseMoney(test.value)
+		if test.fails {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %v, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("ParseMoney(%q) = %d, %v, want %d", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	for _, test := range []struct {
+		money Money
+		want  string
+	}{
+		{0, "0"},
+		{1000000, "100"},
+		{12345600, "1234.56"},
+		{-5000, "-0.5"},
+		{1, "0.0001"},
+	} {
+		if got := test.money.String(); got != test.want {
+			t.Errorf("Money(%d).String() = %q, want %q", test.money, got, test.want)
+		}
+	}
+}
+
+func TestMoneyRound(t *testing.T) {
+	rub := Currency{MinorUnits: 100}
+	yen := Currency{MinorUnits: 1}
+	for _, test := range []struct {
+		money    Money
+		currency Currency
+		rounding RoundingMode
+		want     Money
+	}{
+		{12345, rub, HalfUp, 12300},
+		{12350, rub, HalfUp, 12400},
+		{-12350, rub, HalfUp, -12400},
+		{12350, rub, HalfEven, 12400},
+		{12250, rub, HalfEven, 12200},
+		{12251, rub, HalfEven, 12300},
+		{12399, rub, Down, 12300},
+		{-12399, rub, Down, -12300},
+		{12301, rub, Up, 12400},
+		{-12301, rub, Up, -12400},
+		{12300, rub, Up, 12300},
+		{15000, yen, HalfUp, 20000},
+		{14999, yen, HalfUp, 10000},
+		{25000, yen, HalfEven, 20000},
+		{12345, Currency{MinorUnits: 1000}, HalfUp, 12350},
+		{12345, Currency{}, HalfUp, 12300},
+	} {
+		currency := test.currency
+		currency.Rounding = test.rounding
+		if got := test.money.Round(currency); got != test.want {
+			t.Errorf("Money(%d).Round(%d minor units, %v) = %d, want %d",
+				test.money, currency.MinorUnits, test.rounding, got, test.want)
+		}
+	}
+}
+
+func TestMoneyMul(t *testing.T) {
+	const large = Money(1<<60 + 1)
+	for _, test := range []struct {
+		money  Money
+		factor float64
+		want   Money
+	}{
+		{12345600, 0.01, 123456},
+		{10000, 0.12 / 12, 100},
+		{15, 0.5, 8},
+		{-15, 0.5, -8},
+		{25, 0.1, 3},
+		{large, 1, large},
+		{large, 0.5, 1<<59 + 1},
+		{-large, 0.25, -(1 << 58)},
+		{large, 8, math.MaxInt64},
+		{-large, 8, -math.MaxInt64},
+		{large, math.Inf(-1), -math.MaxInt64},
+		{0, math.Inf(1), 0},
+		{large, math.NaN(), 0},
+	} {
+		if got := test.money.Mul(test.factor); got != test.want {
+			t.Errorf("Money(%d).Mul(%v) = %d, want %d", test.money, test.factor, got, test.want)
+		}
+	}
+}
+
+func TestMoneyDiv(t *testing.T) {
+	for _, test := range []struct {
+		money Money
+		n     uint
+		want  Money
+	}{
+		{1200000, 12, 100000},
+		{10, 3, 3},
+		{20, 3, 7},
+		{15, 2, 8},
+		{-15, 2, -8},
+		{-20, 3, -7},
+		{math.MaxInt64, 2, 1 << 62},
+		{math.MaxInt64, math.MaxInt64, 1},
+		{-math.MaxInt64, math.MaxUint64, 0},
+		{5, 0, 5},
+	} {
+		if got := test.money.Div(test.n); got != test.want {
+			t.Errorf("Money(%d).Div(%d) = %d, want %d", test.money, test.n, got, test.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	type payload struct {
+		Amount Money `json:"amount"`
+	}
+	for _, amount := range []Money{0, 1, 12345600, -5000, 9223372036854775807} {
+		data, err := json.Marshal(payload{amount})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded payload
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded.Amount != amount {
+			t.Errorf("round trip of %d through %s = %d, %v", amount, data, decoded.Amount, err)
+		}
+	}
+	for data, want := range map[string]Money{
+		`{"amount":"1234.56"}`: 12345600,
+		`{"amount":null}`:      0,
+		`{"amount":""}`:        0,
+		`{"amount":10}`:        100000,
+	} {
+		var decoded payload
+		if err := json.Unmarshal([]byte(data), &decoded); err != nil || decoded.Amount != want {
+			t.Errorf("json.Unmarshal(%s) = %d, %v, want %d", data, decoded.Amount, err, want)
+		}
+	}
+	var decoded payload
+	if err := json.Unmarshal([]byte(`{"amount":"ten"}`), &decoded); err == nil {
+		t.Error(`json.Unmarshal of "ten" succeeded, want an error`)
+	}
+}
+
+func TestMoneyScanValue(t *testing.T) {
+	for _, amount := range []Money{0, 1, 12345600, -5000} {
+		value, err := amount.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var scanned Money
+		if err := scanned.Scan([]byte(value.(string))); err != nil || scanned != amount {
+			t.Errorf("Scan(Value(%d)) = %d, %v", amount, scanned, err)
+		}
+	}
+	for _, test := range []struct {
+		value interface{}
+		want  Money
+	}{
+		{nil, 0},
+		{"12.5", 125000},
+		{[]byte("-3"), -30000},
+		{float64(1.25), 12500},
+		{int64(7), 70000},
+	} {
+		scanned := Money(99)
+		if err := scanned.Scan(test.value); err != nil || scanned != test.want {
+			t.Errorf("Scan(%#v) = %d, %v, want %d", test.value, scanned, err, test.want)
+		}
+	}
+	for _, value := range []interface{}{true, int64(922337203685478), int64(-922337203685478), float64(1e15), math.NaN()} {
+		var scanned Money
+		if err := scanned.Scan(value); err == nil {
+			t.Errorf("Scan(%#v) = %d, want an error", value, scanned)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package entities
+
+import "time"
+
+// RateIndex is a published reference rate (e.g. the central bank key rate) floating loans are linked to
+type RateIndex struct {
+	ID        uint             `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time        `json:"-"`
+	UpdatedAt time.Time        `json:"-"`
+	Name      string           `json:"name" gorm:"type:varchar(100);unique_index"`
+	Values    []RateIndexValue `json:"values"`
+}
+
+// ValueAt returns the latest index value published on or before the given date
+func (ri RateIndex) ValueAt(date time.Time) (float64, bool) {
+	var value float64
+	var valueDate time.Time
+	var found bool
+	for _, indexValue := range ri.Values {
+		if !indexValue.ValueDate.After(date) && (!found || !indexValue.ValueDate.Before(valueDate)) {
+			value = indexValue.Value
+			valueDate = indexValue.ValueDate
+			found = true
+		}
+	}
+	return value, found
+}
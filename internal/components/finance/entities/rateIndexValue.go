@@ -0,0 +1,13 @@
+package entities
+
+import "time"
+
+// RateIndexValue is the value of RateIndex published on ValueDate, one per date
+type RateIndexValue struct {
+	ID          uint      `gorm:"primary_key" json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+	RateIndexID uint      `json:"rateIndexId" gorm:"unique_index:idx_rate_index_value"`
+	ValueDate   time.Time `json:"valueDate" gorm:"unique_index:idx_rate_index_value"`
+	Value       float64   `json:"value"` //значение индекса, % годовых
+}
@@ -0,0 +1,22 @@
+package entities
+
+const (
+	HalfUp   RoundingMode = 0
+	HalfEven RoundingMode = 1
+	Down     RoundingMode = 2
+	Up       RoundingMode = 3
+)
+
+type RoundingMode int
+
+func (mode RoundingMode) String() string {
+	names := [...]string{
+		"HalfUp",
+		"HalfEven",
+		"Down",
+		"Up"}
+	if mode < HalfUp || mode > Up {
+		return "Unknown"
+	}
+	return names[mode]
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var rateDateLayouts = []string{"2006-01-02", "02.01.2006", "02/01/2006", time.RFC3339}
+
+// parseExchangeRates reads exchange rates from CSV in one of two layouts:
+//   - a row per rate with date, currency, quoteCurrency, rate and an optional nominal column,
+//     like the daily rates of the Bank of Russia;
+//   - a row per date with a column per quote currency holding the price of one unit of currency,
+//     like the reference rates of the European Central Bank.
+//
+// Columns are recognized by their header and currencies by name or symbol. Files separated by
+// semicolons may use a decimal comma.
+func parseExchangeRates(reader io.Reader, currencyName string, currencies []entities.Currency) ([]entities.ExchangeRate, error) {
+	content, err := ioutil.ReadAll(bufio.NewReader(reader))
+	if err != nil {
+		return nil, err
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	csvReader := csv.NewReader(bytes.NewReader(content))
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	decimalComma := false
+	if firstLine := strings.SplitN(string(content), "\n", 2)[0]; strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		csvReader.Comma = ';'
+		decimalComma = true
+	}
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", codes.BadExchangeRateFile, err.Error())
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%s: no rates", codes.BadExchangeRateFile)
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	lineError := func(line int, problem string) error {
+		return fmt.Errorf("%s: line %d: %s", codes.BadExchangeRateFile, line+1, problem)
+	}
+	field := func(record []string, column int) string {
+		if column < len(record) {
+			return strings.TrimSpace(record[column])
+		}
+		return ""
+	}
+
+	var exchangeRates []entities.ExchangeRate
+	dateColumn, hasDate := columns["date"]
+	if !hasDate {
+		return nil, lineError(0, "no date column")
+	}
+	currencyColumn, isLong := columns["currency"]
+	if isLong {
+		quoteColumn, hasQuote := columns["quotecurrency"]
+		rateColumn, hasRate := columns["rate"]
+		nominalColumn, hasNominal := columns["nominal"]
+		if !hasQuote || !hasRate {
+			return nil, lineError(0, "currency, quoteCurrency and rate columns are required")
+		}
+		for line, record := range records[1:] {
+			exchangeRate, problem := newExchangeRate(field(record, dateColumn), field(record, currencyColumn),
+				field(record, quoteColumn), field(record, rateColumn), decimalComma, currencies)
+			if problem == "" && hasNominal {
+				var nominal float64
+				if nominal, problem = parseRate(field(record, nominalColumn), decimalComma); problem == "" {
+					exchangeRate.Rate /= nominal
+				}
+			}
+			if problem != "" {
+				return nil, lineError(line+1, problem)
+			}
+			exchangeRates = append(exchangeRates, exchangeRate)
+		}
+		return exchangeRates, nil
+	}
+
+	if currencyName == "" {
+		return nil, lineError(0, "currency of the rates must be given for a file with a column per currency")
+	}
+	for line, record := range records[1:] {
+		for column, quoteName := range records[0] {
+			value := field(record, column)
+			// Reference rates leave days without a fixing empty or mark them N/A
+			if column == dateColumn || value == "" || strings.EqualFold(value, "N/A") {
+				continue
+			}
+			exchangeRate, problem := newExchangeRate(field(record, dateColumn), currencyName, quoteName, value, decimalComma, currencies)
+			if problem != "" {
+				return nil, lineError(line+1, problem)
+			}
+			exchangeRates = append(exchangeRates, exchangeRate)
+		}
+	}
+	return exchangeRates, nil
+}
+
+// newExchangeRate builds a rate from CSV fields, returning a description of the problem if they are invalid
+func newExchangeRate(date string, currencyName string, quoteName string, rate string, decimalComma bool, currencies []entities.Currency) (entities.ExchangeRate, string) {
+	var exchangeRate entities.ExchangeRate
+	var problem string
+	if exchangeRate.RateDate, problem = parseRateDate(date); problem != "" {
+		return exchangeRate, problem
+	}
+	if exchangeRate.CurrencyID, problem = findCurrency(currencyName, currencies); problem != "" {
+		return exchangeRate, problem
+	}
+	if exchangeRate.QuoteCurrencyID, problem = findCurrency(quoteName, currencies); problem != "" {
+		return exchangeRate, problem
+	}
+	if exchangeRate.CurrencyID == exchangeRate.QuoteCurrencyID {
+		return exchangeRate, "currency and quote currency are the same"
+	}
+	exchangeRate.Rate, problem = parseRate(rate, decimalComma)
+	return exchangeRate, problem
+}
+
+func parseRateDate(value string) (time.Time, string) {
+	for _, layout := range rateDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, ""
+		}
+	}
+	return time.Time{}, "unknown date format " + strconv.Quote(value)
+}
+
+func parseRate(value string, decimalComma bool) (float64, string) {
+	if decimalComma {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	rate, err := strconv.ParseFloat(strings.Replace(value, " ", "", -1), 64)
+	if err != nil || rate <= 0 {
+		return 0, "rate must be a positive number, got " + strconv.Quote(value)
+	}
+	return rate, ""
+}
+
+func findCurrency(name string, currencies []entities.Currency) (uint, string) {
+	name = strings.TrimSpace(name)
+	for _, currency := range currencies {
+		if strings.EqualFold(currency.Name, name) || currency.Symbol != "" && strings.EqualFold(currency.Symbol, name) {
+			return currency.ID, ""
+		}
+	}
+	return 0, "unknown currency " + strconv.Quote(name)
+}
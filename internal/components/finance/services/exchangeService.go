@@ -0,0 +1,105 @@
+package services
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/jinzhu/gorm"
+	"io"
+	"strings"
+	"time"
+)
+
+func NewExchangeService(db gorm.DB) ExchangeService {
+	return ExchangeService{db: db}
+}
+
+type ExchangeService struct {
+	db gorm.DB
+}
+
+// Converter returns a converter into the given currency at the latest rates published on or before date
+func (es ExchangeService) Converter(currencyID uint, date time.Time) (entities.CurrencyConverter, error) {
+	converter := entities.CurrencyConverter{Date: date}
+	if currencyID != 0 {
+		if err := es.db.First(&converter.Currency, currencyID).Error; err != nil {
+			return converter, err
+		}
+	}
+	err := es.db.Where("rate_date = (SELECT MAX(r.rate_date) FROM exchange_rates r"+
+		" WHERE r.currency_id = exchange_rates.currency_id AND r.quote_currency_id = exchange_rates.quote_currency_id"+
+		" AND r.rate_date <= ?)", date).Find(&converter.Rates).Error
+	return converter, err
+}
+
+// Converters returns converters into the given currency, one for each of the dates, loading in a single query
+// the rates of every date between the given currencies and the target one. Amounts in other currencies
+// cannot be converted by them.
+func (es ExchangeService) Converters(currencyID uint, currencyIDs []uint, dates []time.Time) ([]entities.CurrencyConverter, error) {
+	converters := make([]entities.CurrencyConverter, len(dates))
+	var currency entities.Currency
+	if currencyID != 0 {
+		if err := es.db.First(&currency, currencyID).Error; err != nil {
+			return converters, err
+		}
+	}
+	var rates []entities.ExchangeRate
+	if len(dates) > 0 {
+		ids := append([]uint{currencyID}, currencyIDs...)
+		latest := "rate_date = (SELECT MAX(r.rate_date) FROM exchange_rates r" +
+			" WHERE r.currency_id = exchange_rates.currency_id AND r.quote_currency_id = exchange_rates.quote_currency_id" +
+			" AND r.rate_date <= ?)"
+		conditions := make([]string, 0, len(dates))
+		values := make([]interface{}, 0, len(dates))
+		seen := map[time.Time]bool{}
+		for _, date := range dates {
+			if !seen[date] {
+				seen[date] = true
+				conditions = append(conditions, latest)
+				values = append(values, date)
+			}
+		}
+		err := es.db.Where("currency_id IN (?) OR quote_currency_id IN (?)", ids, ids).
+			Where(strings.Join(conditions, " OR "), values...).Find(&rates).Error
+		if err != nil {
+			return converters, err
+		}
+	}
+	for i, date := range dates {
+		converters[i] = entities.CurrencyConverter{Currency: currency, Date: date, Rates: rates}
+	}
+	return converters, nil
+}
+
+// SaveRate stores the exchange rate, replacing the one published for the same currencies on the same date
+func (es ExchangeService) SaveRate(exchangeRate entities.ExchangeRate) (entities.ExchangeRate, error) {
+	return saveRate(&es.db, exchangeRate)
+}
+
+// ImportRates stores the exchange rates of a CSV file all at once. The currency is only needed for files
+// with a column per quote currency. It returns the number of rates stored.
+func (es ExchangeService) ImportRates(reader io.Reader, currencyName string) (int, error) {
+	var currencies []entities.Currency
+	if err := es.db.Find(&currencies).Error; err != nil {
+		return 0, err
+	}
+	exchangeRates, err := parseExchangeRates(reader, currencyName, currencies)
+	if err != nil {
+		return 0, err
+	}
+	tx := es.db.Begin()
+	for _, exchangeRate := range exchangeRates {
+		if _, err := saveRate(tx, exchangeRate); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return len(exchangeRates), tx.Commit().Error
+}
+
+func saveRate(db *gorm.DB, exchangeRate entities.ExchangeRate) (entities.ExchangeRate, error) {
+	err := db.Where(entities.ExchangeRate{
+		CurrencyID:      exchangeRate.CurrencyID,
+		QuoteCurrencyID: exchangeRate.QuoteCurrencyID,
+		RateDate:        exchangeRate.RateDate,
+	}).Assign(entities.ExchangeRate{Rate: exchangeRate.Rate}).FirstOrCreate(&exchangeRate).Error
+	return exchangeRate, err
+}
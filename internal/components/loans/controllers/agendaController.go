@@ -1,11 +1,16 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
 	"github.com/gin-gonic/gin"
 	"gopkg.in/appleboy/gin-jwt.v2"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,7 +22,155 @@ type AgendaController struct {
 	agendaService services.AgendaService
 }
 
+// GetAgendaElements returns the agenda elements of the period selected, sorted, grouped and paged
+// by the query parameters, see agendaQuery
 func (ac AgendaController) GetAgendaElements(context *gin.Context) {
+	from, to, ok := agendaPeriod(context)
+	if !ok {
+		return
+	}
+	query, ok := agendaQuery(context, from, to)
+	if !ok {
+		return
+	}
+
+	userId := uint(jwt.ExtractClaims(context)["user_id"].(float64))
+
+	asOf, ok := asOfDate(context)
+	if !ok {
+		return
+	}
+
+	query.AsOf = asOf
+	elements := ac.agendaService.GetElements(query, userId)
+	elements, totals, err := ac.agendaService.ToBaseCurrency(elements, userId, asOf)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	page, err := services.PageAgenda(elements, query, totals)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+
+	response := gin.H{"dateFrom": from, "dateTo": to, "asOf": asOf, "count": len(page.Elements), "total": page.Total,
+		"elements": page.Elements, "totals": page.Totals, "nextCursor": page.NextCursor}
+	if query.GroupBy != nil {
+		response["groups"] = page.Groups
+	}
+	context.JSON(http.StatusOK, response)
+}
+
+// maxAgendaLimit limits the size of an agenda page
+const maxAgendaLimit = 1000
+
+// agendaQuery reads the agenda query parameters:
+//   - type: comma separated Income, Expense and Payment;
+//   - planId and categoryId: comma separated ids, categories including their subcategories;
+//   - minAmount and maxAmount: bounds of the amount in the base currency;
+//   - sort: date or amount, order: asc or desc;
+//   - groupBy: day, week or month;
+//   - overdue: true to add the payments overdue by asOf that fall before the period;
+//   - limit and cursor: the size of a page and the nextCursor of the previous one.
+func agendaQuery(context *gin.Context, from time.Time, to time.Time) (entities.AgendaQuery, bool) {
+	query := entities.AgendaQuery{From: from, To: to, Cursor: context.Query("cursor")}
+	valid := true
+	for _, elementType := range splitQuery(context.Query("type")) {
+		switch strings.ToLower(elementType) {
+		case "income":
+			query.ElementTypes = append(query.ElementTypes, "Income")
+		case "expense":
+			query.ElementTypes = append(query.ElementTypes, "Expense")
+		case "payment":
+			query.ElementTypes = append(query.ElementTypes, "Payment")
+		default:
+			valid = false
+		}
+	}
+	var idsValid bool
+	query.PlanIDs, idsValid = parseIds(context.Query("planId"))
+	valid = valid && idsValid
+	query.CategoryIDs, idsValid = parseIds(context.Query("categoryId"))
+	valid = valid && idsValid
+	for name, bound := range map[string]**financeEntity.Money{"minAmount": &query.MinAmount, "maxAmount": &query.MaxAmount} {
+		if value := context.Query(name); value != "" {
+			amount, err := financeEntity.ParseMoney(value)
+			valid = valid && err == nil
+			*bound = &amount
+		}
+	}
+	switch strings.ToLower(context.DefaultQuery("sort", "date")) {
+	case "date":
+	case "amount":
+		query.SortByAmount = true
+	default:
+		valid = false
+	}
+	switch strings.ToLower(context.DefaultQuery("order", "asc")) {
+	case "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		valid = false
+	}
+	if groupBy := context.Query("groupBy"); groupBy != "" {
+		var period entities.TimePeriod
+		switch strings.ToLower(groupBy) {
+		case "day":
+			period = entities.Day
+		case "week":
+			period = entities.Week
+		case "month":
+			period = entities.Month
+		default:
+			valid = false
+		}
+		query.GroupBy = &period
+	}
+	switch strings.ToLower(context.DefaultQuery("overdue", "false")) {
+	case "false":
+	case "true":
+		query.Overdue = true
+	default:
+		valid = false
+	}
+	if limit := context.Query("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		valid = valid && err == nil && query.Limit > 0 && query.Limit <= maxAgendaLimit
+	}
+	if !valid {
+		context.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadAgendaQuery})
+	}
+	return query, valid
+}
+
+func splitQuery(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func parseIds(value string) ([]uint, bool) {
+	var ids []uint
+	for _, part := range splitQuery(value) {
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, true
+}
+
+// agendaPeriod reads the period of the agenda from the from and to query parameters. It ends now and lasts
+// a week unless they say otherwise.
+func agendaPeriod(context *gin.Context) (time.Time, time.Time, bool) {
 	layout := time.RFC3339
 	fromString := context.Query("from")
 	toString := context.Query("to")
@@ -39,12 +192,103 @@ func (ac AgendaController) GetAgendaElements(context *gin.Context) {
 
 	if dateError != nil {
 		context.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadTimeFormat})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// maxForecastHorizon limits the forecast to ten years of days
+const maxForecastHorizon = 3650
+
+// GetForecast projects the balance of the user starting from the balance query parameter for horizon days
+// from now, by day or by month as the interval query parameter tells
+func (ac AgendaController) GetForecast(context *gin.Context) {
+	userId := uint(jwt.ExtractClaims(context)["user_id"].(float64))
+	var balance financeEntity.Money
+	var err error
+	if balanceString := context.Query("balance"); balanceString != "" {
+		balance, err = financeEntity.ParseMoney(balanceString)
+	}
+	horizon, horizonError := strconv.Atoi(context.DefaultQuery("horizon", "30"))
+	interval := entities.Day
+	switch strings.ToLower(context.DefaultQuery("interval", "day")) {
+	case "day":
+	case "month":
+		interval = entities.Month
+	default:
+		err = errors.New(codes.BadForecastRequest)
+	}
+	if err != nil || horizonError != nil || horizon <= 0 || horizon > maxForecastHorizon {
+		context.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadForecastRequest})
 		return
 	}
 
+	from := time.Now()
+	forecast, err := ac.agendaService.Forecast(userId, balance, from, from.AddDate(0, 0, horizon), interval)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"forecast": forecast})
+}
+
+// ExportAgenda returns the agenda elements of the period as an iCalendar file
+func (ac AgendaController) ExportAgenda(context *gin.Context) {
+	from, to, ok := agendaPeriod(context)
+	if !ok {
+		return
+	}
 	userId := uint(jwt.ExtractClaims(context)["user_id"].(float64))
+	elements := ac.agendaService.GetElementsByTimeAndUserID(from, to, time.Now(), userId)
+	context.Header("Content-Disposition", `attachment; filename="agenda.ics"`)
+	writeCalendar(context, "Agenda", services.ElementEvents(elements))
+}
 
-	elements := ac.agendaService.GetElementsByTimeAndUserID(from, to, userId)
+// GetCalendarFeedURL returns the secret URL of the live calendar feed of the user
+func (ac AgendaController) GetCalendarFeedURL(context *gin.Context) {
+	userId := uint(jwt.ExtractClaims(context)["user_id"].(float64))
+	token, err := ac.agendaService.CalendarToken(userId)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"url": calendarFeedURL(context, token)})
+}
+
+// RotateCalendarFeedURL replaces the secret URL of the calendar feed, so the old one stops working
+func (ac AgendaController) RotateCalendarFeedURL(context *gin.Context) {
+	userId := uint(jwt.ExtractClaims(context)["user_id"].(float64))
+	token, err := ac.agendaService.RotateCalendarToken(userId)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"url": calendarFeedURL(context, token)})
+}
 
-	context.JSON(http.StatusOK, gin.H{"dateFrom": from, "dateTo": to, "count": len(elements), "elements": elements})
+// GetCalendarFeed serves the calendar feed of the user the secret in the URL belongs to. Calendar apps
+// cannot sign in, so the secret is the only access check.
+func (ac AgendaController) GetCalendarFeed(context *gin.Context) {
+	userId := ac.agendaService.GetUserIDByCalendarToken(strings.TrimSuffix(context.Param("token"), ".ics"))
+	if userId == 0 {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
+		return
+	}
+	writeCalendar(context, "Credit Portfolio", ac.agendaService.GetCalendarEvents(userId, time.Now()))
+}
+
+func writeCalendar(context *gin.Context, name string, events []entities.CalendarEvent) {
+	context.Header("Content-Type", "text/calendar; charset=utf-8")
+	context.Status(http.StatusOK)
+	if err := services.WriteCalendar(context.Writer, name, events); err != nil {
+		context.Error(err)
+	}
+}
+
+func calendarFeedURL(context *gin.Context, token string) string {
+	scheme := "http"
+	if context.Request.TLS != nil || context.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + context.Request.Host + "/calendar/" + token + ".ics"
 }
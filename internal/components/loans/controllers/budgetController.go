@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"gopkg.in/appleboy/gin-jwt.v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxBudgetReportMonths limits the budget report to ten years
+const maxBudgetReportMonths = 120
+
+type BudgetController struct {
+	db              gorm.DB
+	categoryService services.CategoryService
+}
+
+func NewBudgetController(db *gorm.DB, categoryService services.CategoryService) BudgetController {
+	return BudgetController{db: *db, categoryService: categoryService}
+}
+
+func (bc BudgetController) GetBudgets(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	budgets := []entities.Budget{}
+	bc.db.Where("user_id = ?", userId).Order("category_id, month").Find(&budgets)
+	c.JSON(http.StatusOK, gin.H{"budgets": budgets})
+}
+
+// SetBudget sets the limit of a category for a month or, without a month, for every month
+func (bc BudgetController) SetBudget(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	var request entities.Budget
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	var category entities.Category
+	bc.db.Where("user_id = 0 OR user_id = ?", userId).First(&category, request.CategoryID)
+	if category.ID == 0 || request.Amount < 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadBudget})
+		return
+	}
+	month := time.Time{}
+	if !request.Month.IsZero() {
+		month = time.Date(request.Month.Year(), request.Month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	budget := entities.Budget{UserID: userId, CategoryID: category.ID, Month: month}
+	err := bc.db.Where(entities.Budget{UserID: userId, CategoryID: category.ID}).Where("month = ?", month).
+		Assign(entities.Budget{Amount: request.Amount}).FirstOrCreate(&budget).Error
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"budget": budget})
+}
+
+func (bc BudgetController) DeleteBudget(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
+		return
+	}
+	if bc.db.Where("id = ? AND user_id = ?", id, userId).Delete(&entities.Budget{}).RowsAffected == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": codes.ResDeleted})
+}
+
+// GetBudgetReport compares actual incomes and expenses with the budgets for every month between the from and
+// to query parameters, the current month by default
+func (bc BudgetController) GetBudgetReport(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := from.AddDate(0, 1, 0)
+	var fromError, toError error
+	if fromString := c.Query("from"); fromString != "" {
+		from, fromError = time.Parse(time.RFC3339, fromString)
+		to = from.AddDate(0, 1, 0)
+	}
+	if toString := c.Query("to"); toString != "" {
+		to, toError = time.Parse(time.RFC3339, toString)
+	}
+	if fromError != nil || toError != nil || !from.Before(to) || to.After(from.AddDate(0, maxBudgetReportMonths, 0)) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadTimeFormat})
+		return
+	}
+	asOf, ok := asOfDate(c)
+	if !ok {
+		return
+	}
+	report, err := bc.categoryService.GetBudgetReport(userId, from, to, asOf)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
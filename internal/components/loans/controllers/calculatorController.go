@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	financeServices "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -9,14 +12,17 @@ import (
 	"gopkg.in/appleboy/gin-jwt.v2"
 	"math"
 	"net/http"
+	"sort"
+	"time"
 )
 
 type CalculatorController struct {
-	db gorm.DB
+	db              gorm.DB
+	exchangeService financeServices.ExchangeService
 }
 
-func NewCalculatorController(pg *gorm.DB) CalculatorController {
-	return CalculatorController{db: *pg}
+func NewCalculatorController(pg *gorm.DB, exchangeService financeServices.ExchangeService) CalculatorController {
+	return CalculatorController{db: *pg, exchangeService: exchangeService}
 }
 
 func (cc CalculatorController) CalculateCredit(c *gin.Context) {
@@ -24,6 +30,20 @@ func (cc CalculatorController) CalculateCredit(c *gin.Context) {
 	var paymentPlan entities.PaymentPlan
 	c.ShouldBindWith(&paymentPlan, binding.JSON)
 	paymentPlan.UserID = userId
+	if paymentPlan.GraceMonths >= paymentPlan.Months {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadGracePeriod})
+		return
+	}
+	if paymentPlan.Frequency < 0 || paymentPlan.PaymentPeriod < entities.Day || paymentPlan.PaymentPeriod > entities.Year {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadPaymentPeriod})
+		return
+	}
+	if paymentPlan.RateIndexID != 0 {
+		cc.db.Preload("Values").First(&paymentPlan.RateIndex, paymentPlan.RateIndexID)
+	}
+	if paymentPlan.CurrencyID != 0 {
+		cc.db.First(&paymentPlan.Currency, paymentPlan.CurrencyID)
+	}
 	if paymentPlan.PaymentType == entities.Even {
 		paymentPlan = CalculateCreditWithEqualPayments(paymentPlan)
 	} else {
@@ -38,36 +58,425 @@ func (cc CalculatorController) CalculateCredit(c *gin.Context) {
 	})
 }
 
-func CalculateCreditWithEqualPayments(paymentPlan entities.PaymentPlan) entities.PaymentPlan {
-	var percent = paymentPlan.InterestRate / 12 / 100
-	var coefficient = (percent * math.Pow(1+percent, float64(paymentPlan.Months))) / (math.Pow(1+percent, float64(paymentPlan.Months)) - 1)
-	var sum = paymentPlan.Amount * coefficient
+// defaultDebtToIncomeCap is the share of income that may go to loans when the user sets no cap
+const defaultDebtToIncomeCap = 0.4
+
+// CalculateAffordability tells how large a new monthly payment and loan the user can afford on top of
+// the plans they already have. Incomes, expenses and plans in different currencies are only combined in
+// the base currency of the user.
+func (cc CalculatorController) CalculateAffordability(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	var request entities.AffordabilityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	if request.DebtToIncomeCap == 0 {
+		request.DebtToIncomeCap = defaultDebtToIncomeCap
+	}
+	if request.StartDate.IsZero() {
+		request.StartDate = time.Now()
+	}
+	if request.DebtToIncomeCap < 0 || request.DebtToIncomeCap > 1 || request.Months == 0 || request.InterestRate < 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadAffordabilityRequest})
+		return
+	}
+	user, paymentPlans, incomes, expenses := loadPortfolio(cc.db, userId)
+	converter, err := cc.exchangeService.Converter(user.BaseCurrencyID, time.Now())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	summary, err := SummarizePortfolio(paymentPlans, incomes, expenses, converter)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	// without a base currency the records of the user share one currency, and the new loan is in it
+	currency := converter.Currency
+	if currency.ID == 0 && summary.CurrencyID != 0 {
+		if err := cc.db.First(&currency, summary.CurrencyID).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"affordability": Afford(summary, request, currency)})
+}
 
-	paymentPlan.Payments = []entities.Payment{}
-	currentMonth := paymentPlan.StartDate
+// Afford returns the largest new monthly payment that keeps debt service within the debt-to-income cap
+// and within what is left of income after expenses, and the largest loans repaid with it
+func Afford(summary entities.PortfolioSummary, request entities.AffordabilityRequest, currency financeEntity.Currency) entities.Affordability {
+	affordability := entities.Affordability{
+		CurrencyID:         summary.CurrencyID,
+		DebtToIncomeCap:    request.DebtToIncomeCap,
+		MonthlyIncome:      summary.MonthlyIncome,
+		MonthlyExpenses:    summary.MonthlyExpenses,
+		MonthlyDebtService: summary.MonthlyDebtService,
+	}
+	maxPayment := summary.MonthlyIncome.Mul(request.DebtToIncomeCap) - summary.MonthlyDebtService
+	if disposable := summary.MonthlyIncome - summary.MonthlyExpenses - summary.MonthlyDebtService; disposable < maxPayment {
+		maxPayment = disposable
+	}
+	maxPayment = maxPayment.Round(financeEntity.Currency{MinorUnits: currency.MinorUnits, Rounding: financeEntity.Down})
+	if maxPayment <= 0 {
+		return affordability
+	}
+	affordability.MaxPayment = maxPayment
+	paymentPlan := entities.PaymentPlan{
+		Currency:     currency,
+		CurrencyID:   currency.ID,
+		InterestRate: request.InterestRate,
+		Months:       request.Months,
+		DayCount:     request.DayCount,
+		StartDate:    request.StartDate,
+	}
+	paymentPlan.PaymentType = entities.Even
+	affordability.MaxEvenLoanAmount = maxLoanAmount(paymentPlan, maxPayment)
+	paymentPlan.PaymentType = entities.Differentiated
+	affordability.MaxDifferentiatedLoanAmount = maxLoanAmount(paymentPlan, maxPayment)
+	return affordability
+}
 
-	for i := 0; i < int(paymentPlan.Months); i++ {
-		var payment = entities.Payment{PaymentDate: currentMonth, PaymentAmount: sum}
-		paymentPlan.Payments = append(paymentPlan.Payments, payment)
-		currentMonth = currentMonth.AddDate(0, 1, 0)
+// maxLoanAmount finds by bisection the largest amount of the plan none of whose installments exceeds maxPayment.
+// The last installment settles what rounding left of the previous ones, so it may exceed maxPayment by up to
+// half a rounding step per installment.
+func maxLoanAmount(paymentPlan entities.PaymentPlan, maxPayment financeEntity.Money) financeEntity.Money {
+	step := paymentPlan.Currency.Step()
+	periods := periodsWithin(paymentPlan, paymentPlan.Months)
+	affordable := func(steps financeEntity.Money) bool {
+		paymentPlan.Amount = steps * step
+		payments := schedule(paymentPlan, paymentPlan.StartDate, paymentPlan.Amount, periods, 0)
+		for i, payment := range payments {
+			limit := maxPayment
+			if i == len(payments)-1 {
+				limit += step * financeEntity.Money(periods) / 2
+			}
+			if payment.PaymentAmount > limit {
+				return false
+			}
+		}
+		return true
+	}
+	// Without interest the installments would repay exactly maxPayment a month
+	low, high := financeEntity.Money(0), maxPayment*financeEntity.Money(paymentPlan.Months)/step+1
+	for high-low > 1 {
+		middle := (low + high) / 2
+		if affordable(middle) {
+			low = middle
+		} else {
+			high = middle
+		}
 	}
-	paymentPlan.TotalPaymentAmount = sum * float64(paymentPlan.Months)
-	return paymentPlan
+	return low * step
+}
+
+// CalculateCreditWithEqualPayments schedules annuity installments. Like differentiated ones, the first is due
+// one payment period after the start date; plans saved when it fell on the start date are moved by
+// app.MigrateLegacyAnnuityPlans.
+func CalculateCreditWithEqualPayments(paymentPlan entities.PaymentPlan) entities.PaymentPlan {
+	paymentPlan.PaymentType = entities.Even
+	paymentPlan.Payments = schedule(paymentPlan, paymentPlan.StartDate, paymentPlan.Amount, periodsWithin(paymentPlan, paymentPlan.Months), 0)
+	paymentPlan.TotalPaymentAmount = totalAmount(paymentPlan.Payments)
+	paymentPlan.EffectiveRate = EffectiveAnnualRate(paymentPlan)
+	return paymentPlan.WithInterestTotals(time.Now())
 }
 
 func CalculateCreditWithDifferentiatedPayments(paymentPlan entities.PaymentPlan) entities.PaymentPlan {
-	var baseFee = paymentPlan.Amount / float64(paymentPlan.Months)
+	paymentPlan.PaymentType = entities.Differentiated
+	paymentPlan.Payments = schedule(paymentPlan, paymentPlan.StartDate, paymentPlan.Amount, periodsWithin(paymentPlan, paymentPlan.Months), 0)
+	paymentPlan.TotalPaymentAmount = totalAmount(paymentPlan.Payments)
+	paymentPlan.EffectiveRate = EffectiveAnnualRate(paymentPlan)
+	return paymentPlan.WithInterestTotals(time.Now())
+}
+
+// RecalculateFrom rebuilds the payments of the plan due after date for the interest rates in
+// effect by then, keeping the payments already due and the number of remaining payments
+func RecalculateFrom(paymentPlan entities.PaymentPlan, date time.Time) (entities.PaymentPlan, error) {
+	kept, remaining, balance, lastDate := splitSchedule(paymentPlan, date)
+	if len(remaining) == 0 || balance <= 0 {
+		return paymentPlan, errors.New(codes.PlanRepaid)
+	}
+	rebuilt := schedule(paymentPlan, lastDate, balance, uint(len(remaining)), uint(len(kept)))
+	return withSchedule(paymentPlan, kept, rebuilt), nil
+}
+
+// schedule builds the given number of installments following the elapsed ones, accruing interest from
+// the given date. The first ones cover what is left of the grace period, the rest repay the loan
+// according to the payment type of the plan. Interest and installments are rounded to the plan currency.
+func schedule(paymentPlan entities.PaymentPlan, from time.Time, balance financeEntity.Money, periods uint, elapsed uint) []entities.Payment {
+	if balance <= 0 {
+		return []entities.Payment{}
+	}
+	grace := remainingGrace(paymentPlan, periods, elapsed)
+	payments, from, balance := gracePayments(paymentPlan, from, balance, grace, elapsed)
+	if paymentPlan.PaymentType == entities.Even {
+		return append(payments, equalSchedule(paymentPlan, from, balance, periods-grace, elapsed+grace)...)
+	}
+	return append(payments, differentiatedSchedule(paymentPlan, from, balance, periods-grace, elapsed+grace)...)
+}
+
+// periodsWithin returns the number of installments due within the first months of the plan term
+func periodsWithin(paymentPlan entities.PaymentPlan, months uint) uint {
+	var periods uint
+	end := paymentPlan.StartDate.AddDate(0, int(months), 0)
+	for !paymentPlan.DueDate(periods + 1).After(end) {
+		periods++
+	}
+	return periods
+}
+
+// remainingGrace returns how many of the next installments still belong to the grace period.
+// At least one installment is always left to repay the loan.
+func remainingGrace(paymentPlan entities.PaymentPlan, periods uint, elapsed uint) uint {
+	gracePeriods := periodsWithin(paymentPlan, paymentPlan.GraceMonths)
+	if gracePeriods <= elapsed || periods == 0 {
+		return 0
+	}
+	if grace := gracePeriods - elapsed; grace < periods {
+		return grace
+	}
+	return periods - 1
+}
+
+// gracePayments builds the installments of the grace period: interest only, or nothing at all
+// with the interest added to the balance. It returns them with the date and balance they end with.
+func gracePayments(paymentPlan entities.PaymentPlan, from time.Time, balance financeEntity.Money, periods uint, elapsed uint) ([]entities.Payment, time.Time, financeEntity.Money) {
+	payments := []entities.Payment{}
+	currentDate := from
+	for i := uint(1); i <= periods; i++ {
+		dueDate := paymentPlan.DueDate(elapsed + i)
+		interest := accruedInterest(paymentPlan, balance, currentDate, dueDate)
+		currentDate = dueDate
+		payment := entities.Payment{PaymentDate: currentDate, PaymentAmount: interest, Interest: interest}
+		if paymentPlan.GraceType == entities.Deferred {
+			balance += interest
+			payment.PaymentAmount = 0
+			payment.Principal = -interest
+		}
+		payment.RemainingBalance = balance
+		payments = append(payments, payment)
+	}
+	return payments, currentDate, balance
+}
+
+// periodRate returns the nominal interest rate of the plan for the payment period starting on the
+// given date. Installments are sized with it.
+func periodRate(paymentPlan entities.PaymentPlan, date time.Time) float64 {
+	return paymentPlan.RateAt(date) / paymentPlan.PeriodsPerYear() / 100
+}
+
+// accruedRate returns the interest rate accrued between two dates according to the day count
+// convention of the plan. Interest of every installment is charged with it.
+func accruedRate(paymentPlan entities.PaymentPlan, from time.Time, to time.Time) float64 {
+	return paymentPlan.RateAt(from) / 100 * paymentPlan.DayCount.YearFraction(from, to)
+}
+
+// accruedInterest returns the interest accrued on balance between two dates, rounded to the plan currency
+func accruedInterest(paymentPlan entities.PaymentPlan, balance financeEntity.Money, from time.Time, to time.Time) financeEntity.Money {
+	return balance.Mul(accruedRate(paymentPlan, from, to)).Round(paymentPlan.Currency)
+}
+
+// annuity returns the fixed installment that repays amount in the given number of periods
+func annuity(amount financeEntity.Money, percent float64, periods uint) financeEntity.Money {
+	if percent == 0 {
+		return amount.Div(periods)
+	}
+	var coefficient = (percent * math.Pow(1+percent, float64(periods))) / (math.Pow(1+percent, float64(periods)) - 1)
+	return amount.Mul(coefficient)
+}
+
+// periodsToRepay returns how many installments of the given sum it takes to repay the balance.
+// It is not ok when the installment does not cover the interest of a period, so the balance never goes down.
+func periodsToRepay(balance financeEntity.Money, percent float64, sum financeEntity.Money) (uint, bool) {
+	if sum <= 0 || sum.Float64() <= balance.Float64()*percent {
+		return 0, false
+	}
+	if percent == 0 {
+		return uint(math.Ceil(balance.Float64()/sum.Float64() - 1e-9)), true
+	}
+	return uint(math.Ceil(-math.Log(1-balance.Float64()*percent/sum.Float64())/math.Log(1+percent) - 1e-9)), true
+}
+
+// equalSchedule builds the given number of annuity installments following the elapsed ones.
+// The installment is recalculated for the rest of the term whenever the interest rate changes,
+// and the last one closes whatever balance is left, absorbing the rounding of the previous ones.
+func equalSchedule(paymentPlan entities.PaymentPlan, from time.Time, balance financeEntity.Money, periods uint, elapsed uint) []entities.Payment {
+	payments := []entities.Payment{}
+	currentDate := from
+	var percent float64
+	var sum financeEntity.Money
+	for i := uint(0); i < periods && balance > 0; i++ {
+		if rate := periodRate(paymentPlan, currentDate); i == 0 || rate != percent {
+			percent = rate
+			sum = annuity(balance, percent, periods-i).Round(paymentPlan.Currency)
+		}
+		dueDate := paymentPlan.DueDate(elapsed + i + 1)
+		interest := accruedInterest(paymentPlan, balance, currentDate, dueDate)
+		currentDate = dueDate
+		amount := sum
+		if i == periods-1 || amount > balance+interest {
+			amount = balance + interest
+		}
+		balance -= amount - interest
+		payments = append(payments, entities.Payment{
+			PaymentDate:      currentDate,
+			PaymentAmount:    amount,
+			Principal:        amount - interest,
+			Interest:         interest,
+			RemainingBalance: balance,
+		})
+	}
+	return payments
+}
+
+// differentiatedSchedule builds the given number of installments following the elapsed ones that
+// repay equal parts of the principal plus the interest accrued on the remaining balance.
+// The last one repays whatever the rounding of the previous ones left.
+func differentiatedSchedule(paymentPlan entities.PaymentPlan, from time.Time, balance financeEntity.Money, periods uint, elapsed uint) []entities.Payment {
+	payments := []entities.Payment{}
+	currentDate := from
+	var baseFee = balance.Div(periods).Round(paymentPlan.Currency)
+	for i := uint(0); i < periods && balance > 0; i++ {
+		dueDate := paymentPlan.DueDate(elapsed + i + 1)
+		interest := accruedInterest(paymentPlan, balance, currentDate, dueDate)
+		currentDate = dueDate
+		principal := baseFee
+		if i == periods-1 || principal > balance {
+			principal = balance
+		}
+		balance -= principal
+		payments = append(payments, entities.Payment{
+			PaymentDate:      currentDate,
+			PaymentAmount:    principal + interest,
+			Principal:        principal,
+			Interest:         interest,
+			RemainingBalance: balance,
+		})
+	}
+	return payments
+}
+
+// splitSchedule replays the stored payments and prepayments of the plan up to date. It returns the
+// payments due by then, the payments due after it, the outstanding balance and the last due date.
+func splitSchedule(paymentPlan entities.PaymentPlan, date time.Time) ([]entities.Payment, []entities.Payment, financeEntity.Money, time.Time) {
+	sort.SliceStable(paymentPlan.Payments, func(i, j int) bool {
+		return paymentPlan.Payments[i].PaymentDate.Before(paymentPlan.Payments[j].PaymentDate)
+	})
+	sort.SliceStable(paymentPlan.Prepayments, func(i, j int) bool {
+		return paymentPlan.Prepayments[i].PrepaymentDate.Before(paymentPlan.Prepayments[j].PrepaymentDate)
+	})
+
+	var kept, remaining []entities.Payment
+	var balance = paymentPlan.Amount
+	var lastDate = paymentPlan.StartDate
+	var nextPrepayment = 0
+	for _, payment := range paymentPlan.Payments {
+		if payment.PaymentDate.After(date) {
+			remaining = append(remaining, payment)
+			continue
+		}
+		for ; nextPrepayment < len(paymentPlan.Prepayments) &&
+			paymentPlan.Prepayments[nextPrepayment].PrepaymentDate.Before(payment.PaymentDate); nextPrepayment++ {
+			balance -= paymentPlan.Prepayments[nextPrepayment].Amount
+		}
+		balance -= payment.PaymentAmount - accruedInterest(paymentPlan, balance, lastDate, payment.PaymentDate)
+		kept = append(kept, payment)
+		lastDate = payment.PaymentDate
+	}
+	for ; nextPrepayment < len(paymentPlan.Prepayments) &&
+		!paymentPlan.Prepayments[nextPrepayment].PrepaymentDate.After(date); nextPrepayment++ {
+		balance -= paymentPlan.Prepayments[nextPrepayment].Amount
+	}
+	return kept, remaining, balance, lastDate
+}
+
+// withSchedule replaces the payments of the plan with kept and rebuilt ones and updates the totals
+func withSchedule(paymentPlan entities.PaymentPlan, kept []entities.Payment, rebuilt []entities.Payment) entities.PaymentPlan {
+	for i := range rebuilt {
+		rebuilt[i].PaymentPlanID = paymentPlan.ID
+	}
+	paymentPlan.Payments = append(kept, rebuilt...)
+	if len(paymentPlan.Payments) > 0 {
+		paymentPlan.Months = monthsUntil(paymentPlan.StartDate, paymentPlan.Payments[len(paymentPlan.Payments)-1].PaymentDate)
+	}
+	paymentPlan.TotalPaymentAmount = totalAmount(paymentPlan.Payments)
+	for _, prepayment := range paymentPlan.Prepayments {
+		paymentPlan.TotalPaymentAmount += prepayment.Amount
+	}
+	paymentPlan.EffectiveRate = EffectiveAnnualRate(paymentPlan)
+	return paymentPlan.WithInterestTotals(time.Now())
+}
+
+// monthsUntil returns the number of whole months from start, rounded up, it takes to reach date
+func monthsUntil(start time.Time, date time.Time) uint {
+	var months uint
+	for start.AddDate(0, int(months), 0).Before(date) {
+		months++
+	}
+	return months
+}
+
+func totalAmount(payments []entities.Payment) financeEntity.Money {
+	var total financeEntity.Money
+	for _, payment := range payments {
+		total += payment.PaymentAmount
+	}
+	return total
+}
+
+type cashFlow struct {
+	date   time.Time
+	amount float64
+}
+
+// cashFlows lists money received (positive) and paid (negative) by the borrower over the plan
+func cashFlows(paymentPlan entities.PaymentPlan) []cashFlow {
+	var recurring float64
+	flows := []cashFlow{{date: paymentPlan.StartDate, amount: paymentPlan.Amount.Float64()}}
+	for _, fee := range paymentPlan.Fees {
+		if fee.FeeType == entities.Recurring {
+			recurring += fee.Charge(paymentPlan.Amount).Float64()
+		} else {
+			flows[0].amount -= fee.Charge(paymentPlan.Amount).Float64()
+		}
+	}
+	for _, payment := range paymentPlan.Payments {
+		flows = append(flows, cashFlow{date: payment.PaymentDate, amount: -payment.PaymentAmount.Float64() - recurring})
+	}
+	for _, prepayment := range paymentPlan.Prepayments {
+		flows = append(flows, cashFlow{date: prepayment.PrepaymentDate, amount: -prepayment.Amount.Float64()})
+	}
+	return flows
+}
 
-	paymentPlan.Payments = []entities.Payment{}
-	paymentPlan.TotalPaymentAmount = 0
-	currentMonth := paymentPlan.StartDate
+// EffectiveAnnualRate returns the internal rate of return of the plan cash flows, fees and
+// insurance included, as percent per year. Flows are discounted by actual days over a 365-day year.
+func EffectiveAnnualRate(paymentPlan entities.PaymentPlan) float64 {
+	flows := cashFlows(paymentPlan)
+	if len(flows) < 2 {
+		return 0
+	}
+	presentValue := func(rate float64) float64 {
+		var value float64
+		for _, flow := range flows {
+			years := flow.date.Sub(paymentPlan.StartDate).Hours() / 24 / 365
+			value += flow.amount / math.Pow(1+rate, years)
+		}
+		return value
+	}
 
-	for i := 0; i < int(paymentPlan.Months); i++ {
-		currentMonth = currentMonth.AddDate(0, 1, 0)
-		var sum = baseFee + (paymentPlan.Amount-baseFee*float64(i))*paymentPlan.InterestRate/100/12
-		var payment = entities.Payment{PaymentDate: currentMonth, PaymentAmount: sum}
-		paymentPlan.Payments = append(paymentPlan.Payments, payment)
-		paymentPlan.TotalPaymentAmount += sum
+	// Present value grows with the rate for a borrower, so the root is found by bisection
+	low, high := -0.99, 100.0
+	if presentValue(low) > 0 || presentValue(high) < 0 {
+		return 0
+	}
+	for i := 0; i < 200 && high-low > 1e-12; i++ {
+		middle := (low + high) / 2
+		if presentValue(middle) < 0 {
+			low = middle
+		} else {
+			high = middle
+		}
 	}
-	return paymentPlan
+	return (low + high) / 2 * 100
 }
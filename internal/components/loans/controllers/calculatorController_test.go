@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"math"
+	"testing"
+	"time"
+)
+
+var calculatorStart = time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+// checkBreakdown checks that every installment splits into its principal and interest, that the balance
+// goes down by the principal and that the schedule repays the plan amount in full
+func checkBreakdown(t *testing.T, paymentPlan entities.PaymentPlan) {
+	t.Helper()
+	balance := paymentPlan.Amount
+	var total financeEntity.Money
+	for i, payment := range paymentPlan.Payments {
+		if payment.Principal+payment.Interest != payment.PaymentAmount {
+			t.Errorf("payment %d: %v principal and %v interest make %v", i, payment.Principal, payment.Interest, payment.PaymentAmount)
+		}
+		balance -= payment.Principal
+		if payment.RemainingBalance != balance {
+			t.Errorf("payment %d: remaining balance = %v, want %v", i, payment.RemainingBalance, balance)
+		}
+		total += payment.PaymentAmount
+	}
+	if balance != 0 {
+		t.Errorf("%v is left after the last payment", balance)
+	}
+	if total != paymentPlan.TotalPaymentAmount {
+		t.Errorf("total payment amount = %v, want %v", paymentPlan.TotalPaymentAmount, total)
+	}
+}
+
+func TestCalculateCreditWithEqualPayments(t *testing.T) {
+	paymentPlan := CalculateCreditWithEqualPayments(entities.PaymentPlan{Amount: financeEntity.NewMoney(120000),
+		InterestRate: 12, Months: 12, StartDate: calculatorStart, Currency: financeEntity.Currency{MinorUnits: 100}})
+	if len(paymentPlan.Payments) != 12 {
+		t.Fatalf("%d payments, want 12", len(paymentPlan.Payments))
+	}
+	checkBreakdown(t, paymentPlan)
+	for i, payment := range paymentPlan.Payments {
+		if want := calculatorStart.AddDate(0, i+1, 0); !payment.PaymentDate.Equal(want) {
+			t.Errorf("payment %d is due on %v, want %v", i, payment.PaymentDate, want)
+		}
+		if i < 11 && payment.PaymentAmount != 106618500 {
+			t.Errorf("payment %d = %v, want 10661.85", i, payment.PaymentAmount)
+		}
+	}
+	first, last := paymentPlan.Payments[0], paymentPlan.Payments[11]
+	if first.Interest != 12000000 || first.Principal != 94618500 {
+		t.Errorf("first payment = %v principal and %v interest, want 9461.85 and 1200", first.Principal, first.Interest)
+	}
+	if last.PaymentAmount < 106610000 || last.PaymentAmount > 106630000 {
+		t.Errorf("last payment = %v, want the installment up to the rounding", last.PaymentAmount)
+	}
+	if paymentPlan.InterestPaid+paymentPlan.InterestRemaining != paymentPlan.TotalPaymentAmount-paymentPlan.Amount {
+		t.Errorf("interest paid %v and remaining %v do not add up to the total interest",
+			paymentPlan.InterestPaid, paymentPlan.InterestRemaining)
+	}
+}
+
+func TestCalculateCreditWithDifferentiatedPayments(t *testing.T) {
+	paymentPlan := CalculateCreditWithDifferentiatedPayments(entities.PaymentPlan{Amount: financeEntity.NewMoney(120000),
+		InterestRate: 12, Months: 12, StartDate: calculatorStart, Currency: financeEntity.Currency{MinorUnits: 100}})
+	if len(paymentPlan.Payments) != 12 {
+		t.Fatalf("%d payments, want 12", len(paymentPlan.Payments))
+	}
+	checkBreakdown(t, paymentPlan)
+	for i, payment := range paymentPlan.Payments {
+		// 10000 of the principal a month and 1% of the balance left before the payment
+		if payment.Principal != financeEntity.NewMoney(10000) || payment.Interest != financeEntity.NewMoney(float64(100*(12-i))) {
+			t.Errorf("payment %d = %v principal and %v interest", i, payment.Principal, payment.Interest)
+		}
+	}
+	if paymentPlan.TotalPaymentAmount != financeEntity.NewMoney(127800) {
+		t.Errorf("total payment amount = %v, want 127800", paymentPlan.TotalPaymentAmount)
+	}
+}
+
+func TestScheduleRounding(t *testing.T) {
+	for _, paymentType := range []entities.PaymentType{entities.Even, entities.Differentiated} {
+		paymentPlan := entities.PaymentPlan{Amount: financeEntity.NewMoney(100000), Months: 3, StartDate: calculatorStart,
+			PaymentType: paymentType, Currency: financeEntity.Currency{MinorUnits: 100}}
+		paymentPlan.Payments = schedule(paymentPlan, calculatorStart, paymentPlan.Amount, 3, 0)
+		paymentPlan.TotalPaymentAmount = totalAmount(paymentPlan.Payments)
+		checkBreakdown(t, paymentPlan)
+		// without interest 100000 splits into 33333.33 twice and the last payment takes the cent left over
+		for i, want := range []financeEntity.Money{333333300, 333333300, 333333400} {
+			if got := paymentPlan.Payments[i].PaymentAmount; got != want {
+				t.Errorf("%v: payment %d = %v, want %v", paymentType, i, got, want)
+			}
+		}
+	}
+}
+
+func TestEffectiveAnnualRate(t *testing.T) {
+	yearLater := calculatorStart.AddDate(0, 0, 365)
+	loan := func(fees ...entities.Fee) entities.PaymentPlan {
+		return entities.PaymentPlan{Amount: financeEntity.NewMoney(100), StartDate: calculatorStart, Fees: fees,
+			Payments: []entities.Payment{{PaymentDate: yearLater, PaymentAmount: financeEntity.NewMoney(110)}}}
+	}
+	withPrepayment := loan()
+	withPrepayment.Payments[0].PaymentAmount = financeEntity.NewMoney(55)
+	withPrepayment.Prepayments = []entities.Prepayment{{PrepaymentDate: yearLater, Amount: financeEntity.NewMoney(55)}}
+	for _, test := range []struct {
+		name        string
+		paymentPlan entities.PaymentPlan
+		want        float64
+	}{
+		{"interest only", loan(), 10},
+		{"one-off fee", loan(entities.Fee{FeeType: entities.OneOff, Amount: financeEntity.NewMoney(1)}), 100 * (110.0/99 - 1)},
+		{"one-off percent fee", loan(entities.Fee{FeeType: entities.OneOff, Percent: 1}), 100 * (110.0/99 - 1)},
+		{"recurring insurance", loan(entities.Fee{FeeType: entities.Recurring, IsInsurance: true, Amount: financeEntity.NewMoney(2)}), 12},
+		{"prepayment", withPrepayment, 10},
+		{"nothing repaid", entities.PaymentPlan{Amount: financeEntity.NewMoney(100), StartDate: calculatorStart}, 0},
+		{"no root", entities.PaymentPlan{Amount: financeEntity.NewMoney(100), StartDate: calculatorStart,
+			Payments: []entities.Payment{{PaymentDate: yearLater}}}, 0},
+	} {
+		if got := EffectiveAnnualRate(test.paymentPlan); math.Abs(got-test.want) > 1e-6 {
+			t.Errorf("%s: effective rate = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// monthly compounding of 12% a year makes about 12.68% a year
+	paymentPlan := CalculateCreditWithEqualPayments(entities.PaymentPlan{Amount: financeEntity.NewMoney(120000),
+		InterestRate: 12, Months: 12, StartDate: calculatorStart, DayCount: entities.Actual365, Currency: financeEntity.Currency{MinorUnits: 100}})
+	if paymentPlan.EffectiveRate < 12.6 || paymentPlan.EffectiveRate > 12.8 {
+		t.Errorf("effective rate of a 12%% annuity = %v", paymentPlan.EffectiveRate)
+	}
+	paymentPlan.Fees = []entities.Fee{{FeeType: entities.OneOff, Percent: 2}}
+	if rate := EffectiveAnnualRate(paymentPlan); rate <= paymentPlan.EffectiveRate+3 {
+		t.Errorf("effective rate with a 2%% fee = %v, want more than %v", rate, paymentPlan.EffectiveRate+3)
+	}
+}
+
+func TestRecalculateFrom(t *testing.T) {
+	before := CalculateCreditWithEqualPayments(entities.PaymentPlan{Amount: financeEntity.NewMoney(120000),
+		InterestRate: 12, Months: 12, StartDate: calculatorStart, Currency: financeEntity.Currency{MinorUnits: 100}})
+	change := before.DueDate(6)
+	paymentPlan := before
+	paymentPlan.Payments = append([]entities.Payment(nil), before.Payments...)
+	paymentPlan.RateChanges = []entities.RateChange{{ChangeDate: change, InterestRate: 24}}
+	after, err := RecalculateFrom(paymentPlan, change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Payments) != 12 {
+		t.Fatalf("%d payments, want the term kept", len(after.Payments))
+	}
+	checkBreakdown(t, after)
+	for i := 0; i < 6; i++ {
+		if after.Payments[i].PaymentAmount != before.Payments[i].PaymentAmount {
+			t.Errorf("payment %d due by the change = %v, want %v kept", i, after.Payments[i].PaymentAmount, before.Payments[i].PaymentAmount)
+		}
+	}
+	balance := before.Payments[5].RemainingBalance
+	if want := balance.Mul(0.02).Round(paymentPlan.Currency); after.Payments[6].Interest != want {
+		t.Errorf("interest after the change = %v, want 2%% of %v", after.Payments[6].Interest, balance)
+	}
+	if want := annuity(balance, 0.02, 6).Round(paymentPlan.Currency); after.Payments[6].PaymentAmount != want {
+		t.Errorf("installment after the change = %v, want %v", after.Payments[6].PaymentAmount, want)
+	}
+
+	if _, err := RecalculateFrom(before, before.DueDate(12)); err == nil {
+		t.Error("recalculating a repaid plan succeeded")
+	}
+}
+
+func TestPeriodsToRepay(t *testing.T) {
+	for _, test := range []struct {
+		balance financeEntity.Money
+		percent float64
+		sum     financeEntity.Money
+		want    uint
+		ok      bool
+	}{
+		{financeEntity.NewMoney(120000), 0.01, financeEntity.NewMoney(10661.86), 12, true},
+		// the annuity of 10661.854 rounded down leaves a few cents for one more period
+		{financeEntity.NewMoney(120000), 0.01, financeEntity.NewMoney(10661.85), 13, true},
+		{financeEntity.NewMoney(90000), 0.01, financeEntity.NewMoney(10661.85), 9, true},
+		{financeEntity.NewMoney(100), 0, financeEntity.NewMoney(30), 4, true},
+		{financeEntity.NewMoney(90), 0, financeEntity.NewMoney(30), 3, true},
+		{financeEntity.NewMoney(100000), 0.01, financeEntity.NewMoney(1000), 0, false},
+		{financeEntity.NewMoney(100000), 0.01, financeEntity.NewMoney(999), 0, false},
+		{financeEntity.NewMoney(100), 0, 0, 0, false},
+	} {
+		got, ok := periodsToRepay(test.balance, test.percent, test.sum)
+		if got != test.want || ok != test.ok {
+			t.Errorf("periodsToRepay(%v, %v, %v) = %d, %v, want %d, %v", test.balance, test.percent, test.sum, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	for _, test := range []struct {
+		graceType   entities.GraceType
+		paymentType entities.PaymentType
+		installment financeEntity.Money //первый взнос после льготного периода
+		balances    []financeEntity.Money
+	}{
+		{entities.InterestOnly, entities.Even, annuity(financeEntity.NewMoney(120000), 0.01, 9).Round(financeEntity.Currency{MinorUnits: 100}),
+			[]financeEntity.Money{financeEntity.NewMoney(120000), financeEntity.NewMoney(120000), financeEntity.NewMoney(120000)}},
+		{entities.InterestOnly, entities.Differentiated, financeEntity.NewMoney(120000/9.0 + 1200).Round(financeEntity.Currency{MinorUnits: 100}),
+			[]financeEntity.Money{financeEntity.NewMoney(120000), financeEntity.NewMoney(120000), financeEntity.NewMoney(120000)}},
+		{entities.Deferred, entities.Even, annuity(financeEntity.NewMoney(123636.12), 0.01, 9).Round(financeEntity.Currency{MinorUnits: 100}),
+			[]financeEntity.Money{financeEntity.NewMoney(121200), financeEntity.NewMoney(122412), financeEntity.NewMoney(123636.12)}},
+	} {
+		paymentPlan := entities.PaymentPlan{Amount: financeEntity.NewMoney(120000), InterestRate: 12, Months: 12,
+			GraceMonths: 3, GraceType: test.graceType, StartDate: calculatorStart, Currency: financeEntity.Currency{MinorUnits: 100}}
+		if test.paymentType == entities.Even {
+			paymentPlan = CalculateCreditWithEqualPayments(paymentPlan)
+		} else {
+			paymentPlan = CalculateCreditWithDifferentiatedPayments(paymentPlan)
+		}
+		if len(paymentPlan.Payments) != 12 {
+			t.Fatalf("%v %v: %d payments, want 12", test.graceType, test.paymentType, len(paymentPlan.Payments))
+		}
+		checkBreakdown(t, paymentPlan)
+		for i, balance := range test.balances {
+			payment := paymentPlan.Payments[i]
+			want := payment.Interest
+			if test.graceType == entities.Deferred {
+				want = 0
+			}
+			if payment.PaymentAmount != want || payment.RemainingBalance != balance {
+				t.Errorf("%v %v: grace payment %d = %v with %v left, want %v with %v left",
+					test.graceType, test.paymentType, i, payment.PaymentAmount, payment.RemainingBalance, want, balance)
+			}
+		}
+		if got := paymentPlan.Payments[3].PaymentAmount; got != test.installment {
+			t.Errorf("%v %v: first installment after the grace period = %v, want %v", test.graceType, test.paymentType, got, test.installment)
+		}
+	}
+}
+
+func TestGracePeriodLeavesOneInstallment(t *testing.T) {
+	paymentPlan := CalculateCreditWithEqualPayments(entities.PaymentPlan{Amount: financeEntity.NewMoney(1200), InterestRate: 12,
+		Months: 3, GraceMonths: 6, StartDate: calculatorStart, Currency: financeEntity.Currency{MinorUnits: 100}})
+	if len(paymentPlan.Payments) != 3 {
+		t.Fatalf("%d payments, want 3", len(paymentPlan.Payments))
+	}
+	checkBreakdown(t, paymentPlan)
+	if last := paymentPlan.Payments[2]; last.Principal != financeEntity.NewMoney(1200) {
+		t.Errorf("last payment repays %v, want the whole loan", last.Principal)
+	}
+}
+
+func TestScheduleFrequencies(t *testing.T) {
+	for _, test := range []struct {
+		period    entities.TimePeriod
+		frequency int
+		dayCount  entities.DayCountConvention
+		payments  int
+		interest  financeEntity.Money //проценты за первый период
+	}{
+		{entities.Month, 0, entities.Thirty360, 12, financeEntity.NewMoney(1200)},
+		{entities.Month, 2, entities.Thirty360, 6, financeEntity.NewMoney(2400)},
+		{entities.Quarter, 0, entities.Thirty360, 4, financeEntity.NewMoney(3600)},
+		{entities.Year, 0, entities.Thirty360, 1, financeEntity.NewMoney(14400)},
+		{entities.Week, 2, entities.Actual365, 26, financeEntity.NewMoney(120000 * 0.12 * 14 / 365).Round(financeEntity.Currency{MinorUnits: 100})},
+		{entities.Day, 0, entities.Thirty360, 12, financeEntity.NewMoney(1200)},
+	} {
+		paymentPlan := CalculateCreditWithDifferentiatedPayments(entities.PaymentPlan{Amount: financeEntity.NewMoney(120000),
+			InterestRate: 12, Months: 12, StartDate: calculatorStart, PaymentPeriod: test.period, Frequency: test.frequency,
+			DayCount: test.dayCount, Currency: financeEntity.Currency{MinorUnits: 100}})
+		if len(paymentPlan.Payments) != test.payments {
+			t.Errorf("every %d %v: %d payments, want %d", test.frequency, test.period, len(paymentPlan.Payments), test.payments)
+			continue
+		}
+		checkBreakdown(t, paymentPlan)
+		if first := paymentPlan.Payments[0]; first.Interest != test.interest || !first.PaymentDate.Equal(paymentPlan.DueDate(1)) {
+			t.Errorf("every %d %v: first payment on %v with %v interest, want %v with %v",
+				test.frequency, test.period, first.PaymentDate, first.Interest, paymentPlan.DueDate(1), test.interest)
+		}
+		if last := paymentPlan.Payments[len(paymentPlan.Payments)-1]; last.PaymentDate.After(calculatorStart.AddDate(1, 0, 0)) {
+			t.Errorf("every %d %v: last payment on %v, after the term", test.frequency, test.period, last.PaymentDate)
+		}
+	}
+}
+
+func TestAffordWithoutBaseCurrency(t *testing.T) {
+	usd := financeEntity.Currency{ID: 2, MinorUnits: 100}
+	salary := entities.Income{Amount: financeEntity.NewMoney(1000), CurrencyID: 2, StartDate: calculatorStart,
+		IsRepeatable: true, Frequency: 1, PaymentPeriod: entities.Month}
+	rent := entities.Expense{Amount: financeEntity.NewMoney(300), CurrencyID: 1, StartDate: calculatorStart,
+		IsRepeatable: true, Frequency: 1, PaymentPeriod: entities.Month}
+	converter := financeEntity.CurrencyConverter{Date: calculatorStart}
+	if _, err := SummarizePortfolio(nil, []entities.Income{salary}, []entities.Expense{rent}, converter); err == nil {
+		t.Error("incomes and expenses in different currencies were added up without a base currency")
+	}
+
+	rent.CurrencyID = 2
+	summary, err := SummarizePortfolio(nil, []entities.Income{salary}, []entities.Expense{rent}, converter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := entities.AffordabilityRequest{DebtToIncomeCap: 0.4, InterestRate: 12, Months: 12, StartDate: calculatorStart}
+	affordability := Afford(summary, request, usd)
+	if affordability.CurrencyID != 2 || affordability.MaxPayment != financeEntity.NewMoney(400) {
+		t.Errorf("affordability = %+v, want a payment of 400 in dollars", affordability)
+	}
+	// 400 a month for a year at 1% a month repays about 4502.03
+	if amount := affordability.MaxEvenLoanAmount; amount < financeEntity.NewMoney(4500) || amount > financeEntity.NewMoney(4503) {
+		t.Errorf("largest annuity loan = %v", amount)
+	}
+	if affordability.MaxDifferentiatedLoanAmount >= affordability.MaxEvenLoanAmount {
+		t.Errorf("largest differentiated loan = %v, want less than the annuity one", affordability.MaxDifferentiatedLoanAmount)
+	}
+}
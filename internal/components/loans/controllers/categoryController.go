@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"gopkg.in/appleboy/gin-jwt.v2"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CategoryController struct {
+	db              gorm.DB
+	categoryService services.CategoryService
+}
+
+func NewCategoryController(db *gorm.DB, categoryService services.CategoryService) CategoryController {
+	return CategoryController{db: *db, categoryService: categoryService}
+}
+
+// GetCategories returns the system categories and the own categories of the user
+func (cc CategoryController) GetCategories(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	c.JSON(http.StatusOK, gin.H{"categories": cc.categoryService.GetCategories(userId)})
+}
+
+// AddCategory adds an own category of the user, optionally inside a category of the same type
+func (cc CategoryController) AddCategory(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	var category entities.Category
+	if err := c.ShouldBindJSON(&category); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	category.ID = 0
+	category.UserID = userId
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" || category.CategoryType.String() == "Unknown" ||
+		!cc.categoryService.IsAllowed(userId, category.ParentID, category.CategoryType) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadCategory})
+		return
+	}
+	if cc.db.Create(&category).Error != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"category": category})
+}
+
+// DeleteCategory deletes an own category of the user; system categories cannot be deleted
+func (cc CategoryController) DeleteCategory(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
+		return
+	}
+	var category entities.Category
+	cc.db.Where("user_id = ?", userId).First(&category, id)
+	if category.ID == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
+		return
+	}
+	if cc.categoryService.DeleteCategory(category) != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": codes.ResDeleted})
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"gopkg.in/appleboy/gin-jwt.v2"
@@ -11,11 +12,12 @@ import (
 )
 
 type ExpenseController struct {
-	gormDB gorm.DB
+	gormDB          gorm.DB
+	categoryService services.CategoryService
 }
 
-func NewExpenseController(db *gorm.DB) ExpenseController {
-	return ExpenseController{gormDB: *db}
+func NewExpenseController(db *gorm.DB, categoryService services.CategoryService) ExpenseController {
+	return ExpenseController{gormDB: *db, categoryService: categoryService}
 }
 
 func (expenseController ExpenseController) GetExpensesByJWT(c *gin.Context) {
@@ -54,6 +56,14 @@ func (expenseController ExpenseController) AddExpense(c *gin.Context) {
 	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
 	c.BindJSON(&expense)
 	expense.UserID = userId
+	if !expenseController.categoryService.IsAllowed(userId, expense.CategoryID, entities.ExpenseCategory) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadCategory})
+		return
+	}
+	if !entities.ValidateRecurrence(expense.IsRepeatable, expense.RRule, expense.Frequency, expense.PaymentPeriod, expense.ExDates) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadRecurrence})
+		return
+	}
 	expenseController.gormDB.Create(&expense)
 	c.JSON(http.StatusCreated, gin.H{"expense": expense})
 }
@@ -72,6 +82,14 @@ func (expenseController ExpenseController) UpdateExpenseById(c *gin.Context) {
 		return
 	}
 	c.BindJSON(&expense)
+	if !expenseController.categoryService.IsAllowed(userId, expense.CategoryID, entities.ExpenseCategory) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadCategory})
+		return
+	}
+	if !entities.ValidateRecurrence(expense.IsRepeatable, expense.RRule, expense.Frequency, expense.PaymentPeriod, expense.ExDates) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadRecurrence})
+		return
+	}
 	expenseController.gormDB.Update(&expense)
 	c.JSON(http.StatusCreated, gin.H{"expense": expense})
 }
@@ -97,6 +115,14 @@ func (expenseController ExpenseController) UpdateExpenseByIdAndJWT(c *gin.Contex
 		return
 	}
 	c.BindJSON(&expense)
+	if !expenseController.categoryService.IsAllowed(userId, expense.CategoryID, entities.ExpenseCategory) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadCategory})
+		return
+	}
+	if !entities.ValidateRecurrence(expense.IsRepeatable, expense.RRule, expense.Frequency, expense.PaymentPeriod, expense.ExDates) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadRecurrence})
+		return
+	}
 	expenseController.gormDB.Update(&expense)
 	c.JSON(http.StatusCreated, gin.H{"expense": expense})
 }
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/export"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"gopkg.in/appleboy/gin-jwt.v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportController exports schedules and the agenda as CSV or XLSX files chosen by the format query parameter
+// and plans as printable PDF statements. Labels follow the lang query parameter or the Accept-Language header.
+type ExportController struct {
+	db            gorm.DB
+	agendaService services.AgendaService
+}
+
+func NewExportController(db *gorm.DB, agendaService services.AgendaService) ExportController {
+	return ExportController{db: *db, agendaService: agendaService}
+}
+
+var scheduleColumns = []string{"number", "date", "payment", "principal", "interest", "balance", "status", "paidAmount"}
+
+// ExportPlan exports the payment schedule of a plan
+func (ec ExportController) ExportPlan(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
+		return
+	}
+	var paymentPlan entities.PaymentPlan
+	preloadPlanDetails(&ec.db).Where("user_id = ?", userId).First(&paymentPlan, id)
+	if paymentPlan.ID == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
+		return
+	}
+	locale := exportLocale(c)
+	table := export.Table{Name: "schedule", Columns: scheduleColumns}
+	table.Rows = scheduleRows(paymentPlan, locale, nil)
+	writeExport(c, table, locale, fmt.Sprintf("schedule-%d", paymentPlan.ID))
+}
+
+// ExportStatement renders a printable PDF statement of a plan: its bank, currency and terms,
+// the full amortization schedule and its totals
+func (ec ExportController) ExportStatement(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
+		return
+	}
+	var paymentPlan entities.PaymentPlan
+	preloadPlanDetails(&ec.db).Preload("Bank").Where("user_id = ?", userId).First(&paymentPlan, id)
+	if paymentPlan.ID == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
+		return
+	}
+	locale := exportLocale(c)
+	statement := planStatement(paymentPlan, locale, time.Now())
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%d.pdf"`, paymentPlan.ID))
+	c.Status(http.StatusOK)
+	if err := export.WriteStatementPDF(c.Writer, statement, locale); err != nil {
+		c.Error(err)
+	}
+}
+
+// ExportPortfolio exports the payment schedules of all plans of the user one after another
+func (ec ExportController) ExportPortfolio(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	var paymentPlans []entities.PaymentPlan
+	preloadPlanDetails(&ec.db).Where("user_id = ?", userId).Order("id").Find(&paymentPlans)
+	locale := exportLocale(c)
+	table := export.Table{Name: "portfolio", Columns: append([]string{"plan"}, scheduleColumns...)}
+	for _, paymentPlan := range paymentPlans {
+		table.Rows = append(table.Rows, scheduleRows(paymentPlan, locale, []export.Cell{export.Text(paymentPlan.Title)})...)
+	}
+	writeExport(c, table, locale, "portfolio")
+}
+
+// ExportAgenda exports the agenda elements of the period selected by the agenda query parameters
+func (ec ExportController) ExportAgenda(c *gin.Context) {
+	from, to, ok := agendaPeriod(c)
+	if !ok {
+		return
+	}
+	query, ok := agendaQuery(c, from, to)
+	if !ok {
+		return
+	}
+	asOf, ok := asOfDate(c)
+	if !ok {
+		return
+	}
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	query.AsOf = asOf
+	elements := ec.agendaService.GetElements(query, userId)
+	elements, totals, err := ec.agendaService.ToBaseCurrency(elements, userId, asOf)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	query.Limit, query.Cursor = 0, ""
+	page, err := services.PageAgenda(elements, query, totals)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+
+	var currencies []financeEntity.Currency
+	ec.db.Find(&currencies)
+	currenciesById := map[uint]financeEntity.Currency{}
+	for _, currency := range currencies {
+		currenciesById[currency.ID] = currency
+	}
+	locale := exportLocale(c)
+	table := export.Table{Name: "agenda", Columns: []string{"date", "type", "title", "amount", "baseAmount"}}
+	for _, element := range page.Elements {
+		baseAmount := export.Amount(element.BaseAmount, currenciesById[totals.CurrencyID])
+		if element.Unconverted {
+			baseAmount = export.Text("")
+		}
+		table.Rows = append(table.Rows, []export.Cell{
+			export.Date(element.PaymentDate),
+			export.Text(locale.Label(element.ElementType)),
+			export.Text(element.Title),
+			export.Amount(element.PaymentAmount, currenciesById[element.CurrencyID]),
+			baseAmount,
+		})
+	}
+	writeExport(c, table, locale, "agenda")
+}
+
+// planStatement lays out the terms, schedule and totals of the plan as a statement generated at the given time
+func planStatement(paymentPlan entities.PaymentPlan, locale export.Locale, generated time.Time) export.Statement {
+	rate := locale.FormatPercent(paymentPlan.InterestRate)
+	if paymentPlan.RateIndexID != 0 {
+		rate = fmt.Sprintf("%s + %s", paymentPlan.RateIndex.Name, locale.FormatPercent(paymentPlan.RateMargin))
+	}
+	statement := export.Statement{
+		Title: fmt.Sprintf("%s: %s", locale.Label("statement"), paymentPlan.Title),
+		Details: []export.Field{
+			{Label: "bank", Value: export.Text(paymentPlan.Bank.Name)},
+			{Label: "currency", Value: export.Text(paymentPlan.Currency.Name)},
+			{Label: "loanAmount", Value: export.Amount(paymentPlan.Amount, paymentPlan.Currency)},
+			{Label: "interestRate", Value: export.Text(rate)},
+			{Label: "paymentType", Value: export.Text(locale.Label(paymentPlan.PaymentType.String()))},
+			{Label: "months", Value: export.Text(strconv.Itoa(int(paymentPlan.Months)))},
+			{Label: "startDate", Value: export.Date(paymentPlan.StartDate)},
+			{Label: "generated", Value: export.Date(generated)},
+		},
+		Table:     export.Table{Name: "schedule", Columns: scheduleColumns},
+		Generated: generated,
+	}
+	statement.Table.Rows = scheduleRows(paymentPlan, locale, nil)
+	var payments, principal, interest, paid financeEntity.Money
+	for _, payment := range paymentPlan.Payments {
+		payments += payment.PaymentAmount
+		principal += payment.Principal
+		interest += payment.Interest
+		paid += payment.PaidAmount
+	}
+	statement.Totals = []export.Field{
+		{Label: "totalPayments", Value: export.Amount(payments, paymentPlan.Currency)},
+		{Label: "totalPrincipal", Value: export.Amount(principal, paymentPlan.Currency)},
+		{Label: "totalInterest", Value: export.Amount(interest, paymentPlan.Currency)},
+		{Label: "totalPaid", Value: export.Amount(paid, paymentPlan.Currency)},
+	}
+	return statement
+}
+
+// scheduleRows returns a row per payment of the plan, each starting with the prefix cells
+func scheduleRows(paymentPlan entities.PaymentPlan, locale export.Locale, prefix []export.Cell) [][]export.Cell {
+	var rows [][]export.Cell
+	for i, payment := range paymentPlan.Payments {
+		row := append(append([]export.Cell{}, prefix...),
+			export.Text(strconv.Itoa(i+1)),
+			export.Date(payment.PaymentDate),
+			export.Amount(payment.PaymentAmount, paymentPlan.Currency),
+			export.Amount(payment.Principal, paymentPlan.Currency),
+			export.Amount(payment.Interest, paymentPlan.Currency),
+			export.Amount(payment.RemainingBalance, paymentPlan.Currency),
+			export.Text(locale.Label(payment.Status.String())),
+			export.Amount(payment.PaidAmount, paymentPlan.Currency),
+		)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func exportLocale(c *gin.Context) export.Locale {
+	if lang := c.Query("lang"); lang != "" {
+		return export.ParseLocale(lang)
+	}
+	return export.ParseLocale(c.GetHeader("Accept-Language"))
+}
+
+// writeExport writes the table in the format of the format query parameter, CSV by default
+func writeExport(c *gin.Context, table export.Table, locale export.Locale, name string) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	var write func() error
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		write = func() error { return export.WriteCSV(c.Writer, table, locale) }
+	case "xlsx":
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		write = func() error { return export.WriteXLSX(c.Writer, table, locale) }
+	default:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadExportFormat})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	c.Status(http.StatusOK)
+	if err := write(); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/bankstatement"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/appleboy/gin-jwt.v2"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxStatementSize limits the size of an uploaded bank statement
+const maxStatementSize = 10 << 20
+
+// ImportController imports bank statements in two steps: a preview of the operations of an uploaded file,
+// then the creation of the incomes and expenses the user confirms
+type ImportController struct {
+	importService services.ImportService
+}
+
+func NewImportController(importService services.ImportService) ImportController {
+	return ImportController{importService: importService}
+}
+
+// PreviewImport parses the statement in the file form field. The format field is csv, ofx or qfx and
+// defaults to the file extension, the mapping field holds the CSV column mapping as JSON and the currencyId
+// field the currency of statements that do not name one.
+func (ic ImportController) PreviewImport(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxStatementSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadStatementFile})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadStatementFile})
+		return
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadStatementFile})
+		return
+	}
+
+	var mapping bankstatement.CSVMapping
+	if value := c.PostForm("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadStatementFile})
+			return
+		}
+	}
+	var currencyId uint64
+	if value := c.PostForm("currencyId"); value != "" {
+		if currencyId, err = strconv.ParseUint(value, 10, 32); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
+			return
+		}
+	}
+	format := c.PostForm("format")
+	if extension := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileHeader.Filename), ".")); format == "" &&
+		(extension == "csv" || extension == "ofx" || extension == "qfx") {
+		format = extension
+	}
+
+	transactions, err := bankstatement.Parse(data, format, mapping)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	items, err := ic.importService.Preview(userId, transactions, uint(currencyId))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"transactions": items})
+}
+
+// Import creates the previewed operations of the request as incomes and expenses, skipping duplicates
+func (ic ImportController) Import(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	var request struct {
+		Transactions []entities.ImportedTransaction `json:"transactions"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.Transactions) == 0 ||
+		len(request.Transactions) > bankstatement.MaxTransactions {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadImport})
+		return
+	}
+	result, err := ic.importService.Import(userId, request.Transactions)
+	if err != nil && err.Error() == codes.BadImport {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadImport})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"gopkg.in/appleboy/gin-jwt.v2"
@@ -11,11 +12,12 @@ import (
 )
 
 type IncomeController struct {
-	gormDB gorm.DB
+	gormDB          gorm.DB
+	categoryService services.CategoryService
 }
 
-func NewIncomeController(db *gorm.DB) IncomeController {
-	return IncomeController{gormDB: *db}
+func NewIncomeController(db *gorm.DB, categoryService services.CategoryService) IncomeController {
+	return IncomeController{gormDB: *db, categoryService: categoryService}
 }
 
 func (incomeController IncomeController) GetIncomesByJWT(c *gin.Context) {
@@ -54,6 +56,14 @@ func (incomeController IncomeController) AddIncome(c *gin.Context) {
 	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
 	c.BindJSON(&income)
 	income.UserID = userId
+	if !incomeController.categoryService.IsAllowed(userId, income.CategoryID, entities.IncomeCategory) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadCategory})
+		return
+	}
+	if !entities.ValidateRecurrence(income.IsRepeatable, income.RRule, income.Frequency, income.PaymentPeriod, income.ExDates) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadRecurrence})
+		return
+	}
 	incomeController.gormDB.Create(&income)
 	c.JSON(http.StatusCreated, gin.H{"income": income})
 }
@@ -72,6 +82,14 @@ func (incomeController IncomeController) UpdateIncomeById(c *gin.Context) {
 		return
 	}
 	c.BindJSON(&income)
+	if !incomeController.categoryService.IsAllowed(userId, income.CategoryID, entities.IncomeCategory) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadCategory})
+		return
+	}
+	if !entities.ValidateRecurrence(income.IsRepeatable, income.RRule, income.Frequency, income.PaymentPeriod, income.ExDates) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadRecurrence})
+		return
+	}
 	incomeController.gormDB.Update(&income)
 	c.JSON(http.StatusCreated, gin.H{"income": income})
 }
@@ -97,6 +115,14 @@ func (incomeController IncomeController) UpdateIncomeByIdAndJWT(c *gin.Context)
 		return
 	}
 	c.BindJSON(&income)
+	if !incomeController.categoryService.IsAllowed(userId, income.CategoryID, entities.IncomeCategory) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadCategory})
+		return
+	}
+	if !entities.ValidateRecurrence(income.IsRepeatable, income.RRule, income.Frequency, income.PaymentPeriod, income.ExDates) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadRecurrence})
+		return
+	}
 	incomeController.gormDB.Update(&income)
 	c.JSON(http.StatusCreated, gin.H{"income": income})
 }
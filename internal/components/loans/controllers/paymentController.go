@@ -1,27 +1,146 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"gopkg.in/appleboy/gin-jwt.v2"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type PaymentController struct {
-	db gorm.DB
+	db             gorm.DB
+	penaltyService services.PenaltyService
 }
 
-func NewPaymentController(db *gorm.DB) PaymentController {
-	return PaymentController{db: *db}
+func NewPaymentController(db *gorm.DB, penaltyService services.PenaltyService) PaymentController {
+	return PaymentController{db: *db, penaltyService: penaltyService}
 }
 
 func (pc PaymentController) GetPaymentsByPlan(c *gin.Context) {
-	var payments []entities.Payment
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	pc.db.Where("PaymentPlanID = ?", id).Find(&payments)
-	c.JSON(http.StatusOK, payments)
+	var paymentPlan entities.PaymentPlan
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	userId := int(jwt.ExtractClaims(c)["user_id"].(float64))
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
+		return
+	}
+	pc.db.Preload("Payments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("payment_date")
+	}).Where("id = ? AND user_id = ?", id, userId).First(&paymentPlan)
+	if paymentPlan.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
+		return
+	}
+	asOf, ok := asOfDate(c)
+	if !ok {
+		return
+	}
+	paymentPlan = paymentPlan.WithInterestTotals(asOf).WithStatus(time.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"payments":          paymentPlan.Payments,
+		"interestPaid":      paymentPlan.InterestPaid,
+		"interestRemaining": paymentPlan.InterestRemaining,
+		"status":            paymentPlan.Status,
+	})
+}
+
+// RecordPayment records or corrects what was actually paid for a scheduled payment of the plan.
+// Penalties charged past the day the payment was paid are taken back.
+func (pc PaymentController) RecordPayment(c *gin.Context) {
+	paymentPlan, ok := findUserPaymentPlan(pc.db, c)
+	if !ok {
+		return
+	}
+	paymentId, err := strconv.ParseUint(c.Param("paymentId"), 10, 32)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
+		return
+	}
+	index := -1
+	for i, payment := range paymentPlan.Payments {
+		if payment.ID == uint(paymentId) {
+			index = i
+		}
+	}
+	if index < 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
+		return
+	}
+	var record entities.Payment
+	if err := c.ShouldBindJSON(&record); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	payment, err := recordPayment(paymentPlan, paymentPlan.Payments[index], record)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	tx := pc.db.Begin()
+	err = tx.Model(&entities.Payment{ID: payment.ID}).Updates(map[string]interface{}{
+		"status":      payment.Status,
+		"paid_date":   payment.PaidDate,
+		"paid_amount": payment.PaidAmount,
+	}).Error
+	if err == nil {
+		err = pc.penaltyService.RevisePenalties(tx, paymentPlan, payment)
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	paymentPlan.Payments[index] = payment
+	c.JSON(http.StatusOK, gin.H{
+		"payment":    payment,
+		"planStatus": paymentPlan.WithStatus(time.Now()).Status,
+	})
+}
+
+// recordPayment applies the status, paid date and paid amount of record to the payment. A paid payment
+// defaults to the scheduled amount paid today; a missed or scheduled one has nothing paid.
+func recordPayment(paymentPlan entities.PaymentPlan, payment entities.Payment, record entities.Payment) (entities.Payment, error) {
+	payment.Status = record.Status
+	payment.PaidDate = record.PaidDate
+	payment.PaidAmount = record.PaidAmount
+	switch record.Status {
+	case entities.Scheduled, entities.Missed:
+		if record.PaidAmount != 0 {
+			return payment, errors.New(codes.BadPaymentRecord)
+		}
+		payment.PaidDate = time.Time{}
+		return payment, nil
+	case entities.Paid:
+		if payment.PaidAmount == 0 {
+			payment.PaidAmount = payment.PaymentAmount
+		}
+		if payment.PaidAmount < payment.PaymentAmount {
+			return payment, errors.New(codes.BadPaymentRecord)
+		}
+	case entities.PartiallyPaid:
+		if payment.PaidAmount <= 0 || payment.PaidAmount >= payment.PaymentAmount {
+			return payment, errors.New(codes.BadPaymentRecord)
+		}
+	default:
+		return payment, errors.New(codes.BadPaymentRecord)
+	}
+	if payment.PaidDate.IsZero() {
+		payment.PaidDate = time.Now()
+	}
+	if payment.PaidDate.Before(paymentPlan.StartDate) {
+		return payment, errors.New(codes.BadPaymentRecord)
+	}
+	return payment, nil
 }
 
 func (pc PaymentController) GetPayment(c *gin.Context) {
@@ -77,3 +196,91 @@ func (pc PaymentController) DeletePayment(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": codes.ResDeleted})
 }
+
+// savePaymentsAfter stores the recalculated schedule of the plan in place of the payments due after date.
+// A new payment due on the same date as a stored one updates it, so its id, the penalties charged on it and
+// what was recorded as paid stay with it. Stored payments left without a counterpart are removed together
+// with their penalties.
+func savePaymentsAfter(db *gorm.DB, paymentPlan entities.PaymentPlan, date time.Time) error {
+	var stored []entities.Payment
+	err := db.Where("payment_plan_id = ? AND payment_date > ?", paymentPlan.ID, date).Find(&stored).Error
+	if err != nil {
+		return err
+	}
+	payments, removed := matchStoredPayments(paymentPlan.Payments, stored)
+	if len(removed) > 0 {
+		err = db.Where("payment_id IN (?)", removed).Delete(entities.Penalty{}).Error
+		if err == nil {
+			err = db.Where("id IN (?)", removed).Delete(entities.Payment{}).Error
+		}
+	}
+	for i := range payments {
+		if err != nil || paymentPlan.Payments[i].ID != 0 {
+			continue
+		}
+		paymentPlan.Payments[i] = payments[i]
+		payment := &paymentPlan.Payments[i]
+		if payment.ID == 0 {
+			err = db.Create(payment).Error
+			continue
+		}
+		err = db.Model(&entities.Payment{ID: payment.ID}).Updates(map[string]interface{}{
+			"payment_amount":    payment.PaymentAmount,
+			"principal":         payment.Principal,
+			"interest":          payment.Interest,
+			"remaining_balance": payment.RemainingBalance,
+			"status":            payment.Status,
+			"paid_date":         payment.PaidDate,
+			"paid_amount":       payment.PaidAmount,
+		}).Error
+	}
+	if err == nil {
+		err = db.Model(&entities.PaymentPlan{ID: paymentPlan.ID}).Updates(map[string]interface{}{
+			"months":               paymentPlan.Months,
+			"total_payment_amount": paymentPlan.TotalPaymentAmount,
+		}).Error
+	}
+	return err
+}
+
+// matchStoredPayments gives the new payments of a recalculated schedule the ids and the paid records of the
+// stored payments due on the same dates. It returns the ids of the stored payments no new payment took over.
+func matchStoredPayments(payments []entities.Payment, stored []entities.Payment) ([]entities.Payment, []uint) {
+	matched := make([]entities.Payment, len(payments))
+	taken := make([]bool, len(stored))
+	for i, payment := range payments {
+		matched[i] = payment
+		if payment.ID != 0 {
+			continue
+		}
+		for j, old := range stored {
+			if !taken[j] && old.PaymentDate.Equal(payment.PaymentDate) {
+				taken[j] = true
+				matched[i] = carryPaymentRecord(payment, old)
+				matched[i].ID, matched[i].CreatedAt = old.ID, old.CreatedAt
+				break
+			}
+		}
+	}
+	var removed []uint
+	for j, old := range stored {
+		if !taken[j] {
+			removed = append(removed, old.ID)
+		}
+	}
+	return matched, removed
+}
+
+// carryPaymentRecord moves what was recorded about the stored payment over to the new one due on its date
+func carryPaymentRecord(payment entities.Payment, old entities.Payment) entities.Payment {
+	if old.Status == entities.Scheduled {
+		return payment
+	}
+	payment.Status, payment.PaidDate, payment.PaidAmount = old.Status, old.PaidDate, old.PaidAmount
+	if payment.Status == entities.Paid && payment.PaidAmount < payment.PaymentAmount {
+		payment.Status = entities.PartiallyPaid
+	} else if payment.Status == entities.PartiallyPaid && payment.PaidAmount >= payment.PaymentAmount {
+		payment.Status = entities.Paid
+	}
+	return payment
+}
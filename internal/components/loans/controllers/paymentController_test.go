@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"testing"
+	"time"
+)
+
+// storedPlan is the plan of prepaymentPlan as read from the database, with its third payment missed and
+// charged a late fee
+func storedPlan(paymentType entities.PaymentType) (entities.PaymentPlan, entities.Penalty) {
+	paymentPlan := prepaymentPlan(paymentType)
+	for i := range paymentPlan.Payments {
+		paymentPlan.Payments[i].ID = uint(i + 1)
+		paymentPlan.Payments[i].PaymentPlanID = paymentPlan.ID
+	}
+	paymentPlan.Payments[0].Status, paymentPlan.Payments[1].Status = entities.Paid, entities.Paid
+	paymentPlan.Payments[2].Status = entities.Missed
+	lateFee := entities.Penalty{ID: 1, PaymentPlanID: paymentPlan.ID, PaymentID: 3, PenaltyType: entities.LateFee,
+		AccruedFrom: paymentPlan.Payments[2].PaymentDate, AccruedTo: paymentPlan.Payments[2].PaymentDate, Amount: financeEntity.NewMoney(500)}
+	return paymentPlan, lateFee
+}
+
+func TestMatchStoredPaymentsAfterPenalty(t *testing.T) {
+	for _, strategy := range []entities.PrepaymentStrategy{entities.ReducePayment, entities.ReduceTerm} {
+		paymentPlan, lateFee := storedPlan(entities.Even)
+		// a prepayment recorded late, dated before the missed payment that was already charged
+		prepayment := entities.Prepayment{PrepaymentDate: time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC),
+			Amount: financeEntity.NewMoney(30000), Strategy: strategy}
+		recalculated, _, err := ApplyPrepayment(paymentPlan, prepayment)
+		if err != nil {
+			t.Fatalf("%v: %v", strategy, err)
+		}
+		stored := paymentPlan.Payments[2:]
+		payments, removed := matchStoredPayments(recalculated.Payments, stored)
+
+		missed := payments[2]
+		if missed.ID != lateFee.PaymentID || missed.Status != entities.Missed {
+			t.Errorf("%v: payment due on %v = %+v, want the charged payment %d still missed",
+				strategy, missed.PaymentDate, missed, lateFee.PaymentID)
+		}
+		if missed.PaymentAmount != recalculated.Payments[2].PaymentAmount {
+			t.Errorf("%v: payment amount = %v, want the recalculated %v", strategy, missed.PaymentAmount, recalculated.Payments[2].PaymentAmount)
+		}
+		ids := map[uint]bool{}
+		for i, payment := range payments {
+			if payment.ID == 0 || ids[payment.ID] {
+				t.Errorf("%v: payment %d has id %d", strategy, i, payment.ID)
+			}
+			ids[payment.ID] = true
+		}
+		for _, id := range removed {
+			if ids[id] || id == lateFee.PaymentID {
+				t.Errorf("%v: payment %d is both kept and removed", strategy, id)
+			}
+		}
+		if len(payments)+len(removed) != len(paymentPlan.Payments) {
+			t.Errorf("%v: %d payments kept and %d removed of %d", strategy, len(payments), len(removed), len(paymentPlan.Payments))
+		}
+	}
+}
+
+func TestCarryPaymentRecord(t *testing.T) {
+	due := time.Date(2020, time.March, 15, 0, 0, 0, 0, time.UTC)
+	payment := entities.Payment{PaymentDate: due, PaymentAmount: financeEntity.NewMoney(100)}
+	for _, test := range []struct {
+		old    entities.Payment
+		status entities.PaymentStatus
+	}{
+		{entities.Payment{Status: entities.Scheduled}, entities.Scheduled},
+		{entities.Payment{Status: entities.Missed}, entities.Missed},
+		{entities.Payment{Status: entities.Paid, PaidAmount: financeEntity.NewMoney(80)}, entities.PartiallyPaid},
+		{entities.Payment{Status: entities.Paid, PaidAmount: financeEntity.NewMoney(100)}, entities.Paid},
+		{entities.Payment{Status: entities.PartiallyPaid, PaidAmount: financeEntity.NewMoney(100)}, entities.Paid},
+	} {
+		if got := carryPaymentRecord(payment, test.old); got.Status != test.status || got.PaidAmount != test.old.PaidAmount {
+			t.Errorf("record of %+v carried as %+v, want status %v", test.old, got, test.status)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	financeServices "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
 	"github.com/gin-gonic/gin"
@@ -9,21 +12,41 @@ import (
 	"gopkg.in/appleboy/gin-jwt.v2"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type PaymentPlanController struct {
-	gormDB      gorm.DB
-	userService services.UserService
+	gormDB          gorm.DB
+	userService     services.UserService
+	exchangeService financeServices.ExchangeService
 }
 
-func NewPaymentPlanController(db *gorm.DB, userService services.UserService) PaymentPlanController {
-	return PaymentPlanController{*db, userService}
+func NewPaymentPlanController(db *gorm.DB, userService services.UserService, exchangeService financeServices.ExchangeService) PaymentPlanController {
+	return PaymentPlanController{*db, userService, exchangeService}
 }
 
 func (paymentPlanController PaymentPlanController) GetPaymentPlans(c *gin.Context) {
 	var paymentPlans []entities.PaymentPlan
 	userId := int(jwt.ExtractClaims(c)["user_id"].(float64))
-	paymentPlanController.gormDB.Where("user_id = ?", userId).Find(&paymentPlans)
+	asOf, ok := asOfDate(c)
+	if !ok {
+		return
+	}
+	preloadPlanList(&paymentPlanController.gormDB, asOf).Where("user_id = ?", userId).Find(&paymentPlans)
+	paymentPlans, err := paymentPlanController.withValuations(paymentPlans, uint(userId), asOf)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	statuses, err := planStatuses(paymentPlanController.gormDB, paymentPlans, time.Now())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	for i := range paymentPlans {
+		paymentPlans[i].Status = statuses[paymentPlans[i].ID]
+		paymentPlans[i].Payments = nil
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"paymentPlans": paymentPlans,
 	})
@@ -37,12 +60,23 @@ func (paymentPlanController PaymentPlanController) GetPaymentPlan(c *gin.Context
 		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
 		return
 	}
-	paymentPlanController.gormDB.Preload("Payments").Where("id = ? AND user_id = ?", id, userId).First(&paymentPlan, id)
+	asOf, ok := asOfDate(c)
+	if !ok {
+		return
+	}
+	preloadPlanDetails(&paymentPlanController.gormDB).Where("id = ? AND user_id = ?", id, userId).First(&paymentPlan, id)
 	if paymentPlan.ID == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"paymentPlan": paymentPlan})
+	paymentPlans, err := paymentPlanController.withValuations([]entities.PaymentPlan{paymentPlan}, uint(userId), asOf)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	paymentPlan = paymentPlans[0]
+	paymentPlan.EffectiveRate = EffectiveAnnualRate(paymentPlan)
+	c.JSON(http.StatusOK, gin.H{"paymentPlan": paymentPlan.WithInterestTotals(asOf).WithStatus(time.Now())})
 }
 
 func (paymentPlanController PaymentPlanController) AddPaymentPlan(c *gin.Context) {
@@ -77,3 +111,131 @@ func (paymentPlanController PaymentPlanController) DeletePaymentPlan(c *gin.Cont
 	paymentPlanController.gormDB.Delete(&paymentPlan)
 	c.JSON(http.StatusOK, gin.H{"message": codes.ResDeleted})
 }
+
+// withValuations values the plans in the base currency of the user at the exchange rates of asOf.
+// The rates of asOf and of the start dates of all the plans are loaded at once. Plans in a currency
+// with no known exchange rate are left without a valuation.
+func (paymentPlanController PaymentPlanController) withValuations(paymentPlans []entities.PaymentPlan, userId uint, asOf time.Time) ([]entities.PaymentPlan, error) {
+	user := paymentPlanController.userService.GetUserByID(userId)
+	currencyIds := make([]uint, len(paymentPlans))
+	dates := []time.Time{asOf}
+	for i, paymentPlan := range paymentPlans {
+		currencyIds[i] = paymentPlan.CurrencyID
+		dates = append(dates, paymentPlan.StartDate)
+	}
+	converters, err := paymentPlanController.exchangeService.Converters(user.BaseCurrencyID, currencyIds, dates)
+	if err != nil {
+		return paymentPlans, err
+	}
+	for i, paymentPlan := range paymentPlans {
+		if valuation, err := ValuePlan(paymentPlan, converters[0], converters[i+1]); err == nil {
+			paymentPlans[i].Valuation = &valuation
+		}
+	}
+	return paymentPlans, nil
+}
+
+// ValuePlan values the outstanding principal of the plan on the converter date in the converter
+// currency and tells how much of it comes from the exchange rate moving since the plan start.
+// startConverter converts at the rates of the plan start date.
+func ValuePlan(paymentPlan entities.PaymentPlan, converter financeEntity.CurrencyConverter, startConverter financeEntity.CurrencyConverter) (entities.Valuation, error) {
+	valuation := entities.Valuation{CurrencyID: converter.Currency.ID, AsOf: converter.Date}
+	rate, ok := converter.RateAt(paymentPlan.CurrencyID, converter.Currency.ID)
+	startRate, startOk := startConverter.RateAt(paymentPlan.CurrencyID, converter.Currency.ID)
+	if !ok || !startOk {
+		return valuation, errors.New(codes.NoExchangeRate)
+	}
+	if !paymentPlan.StartDate.After(converter.Date) {
+		_, _, valuation.Balance, _ = splitSchedule(paymentPlan, converter.Date)
+	}
+	valuation.Rate = rate
+	valuation.StartRate = startRate
+	valuation.BaseBalance = valuation.Balance.Mul(rate).Round(converter.Currency)
+	valuation.BaseAmount = paymentPlan.Amount.Mul(startRate).Round(converter.Currency)
+	valuation.CurrencyEffect = valuation.BaseBalance - valuation.Balance.Mul(startRate).Round(converter.Currency)
+	return valuation, nil
+}
+
+// asOfDate returns the date of the asOf query parameter, today if there is none.
+// It aborts the request and returns false if the date is malformed.
+func asOfDate(c *gin.Context) (time.Time, bool) {
+	asOfString := c.Query("asOf")
+	if asOfString == "" {
+		return time.Now(), true
+	}
+	asOf, err := time.Parse(time.RFC3339, asOfString)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadTimeFormat})
+		return asOf, false
+	}
+	return asOf, true
+}
+
+// preloadPlanDetails preloads everything the calculator needs to rebuild the schedule of a plan
+func preloadPlanDetails(db *gorm.DB) *gorm.DB {
+	return preloadPlan(db, func(db *gorm.DB) *gorm.DB {
+		return db.Order("payment_date")
+	})
+}
+
+// preloadPlanList preloads what the list of plans returns and what valuing them on asOf takes. The list
+// leaves out the payments, so only those due by asOf are loaded, as the outstanding balance is made of them.
+func preloadPlanList(db *gorm.DB, asOf time.Time) *gorm.DB {
+	return preloadPlan(db, func(db *gorm.DB) *gorm.DB {
+		return db.Where("payment_date <= ?", asOf).Order("payment_date")
+	})
+}
+
+func preloadPlan(db *gorm.DB, payments func(*gorm.DB) *gorm.DB) *gorm.DB {
+	return db.Preload("Payments", payments).Preload("Prepayments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("prepayment_date")
+	}).Preload("Fees").Preload("Penalties", func(db *gorm.DB) *gorm.DB {
+		return db.Order("accrued_to")
+	}).Preload("RateChanges").Preload("RateIndex.Values").Preload("Currency")
+}
+
+// planStatuses derives the status of the plans on date from counts of their payments, without loading them
+func planStatuses(db gorm.DB, paymentPlans []entities.PaymentPlan, date time.Time) (map[uint]entities.PlanStatus, error) {
+	statuses := map[uint]entities.PlanStatus{}
+	ids := []uint{0}
+	for _, paymentPlan := range paymentPlans {
+		ids = append(ids, paymentPlan.ID)
+	}
+	rows, err := db.Model(&entities.Payment{}).
+		Select("payment_plan_id, COUNT(*),"+
+			" SUM(CASE WHEN status <> ? AND payment_amount <> 0 THEN 1 ELSE 0 END),"+
+			" SUM(CASE WHEN status <> ? AND payment_amount <> 0 AND payment_date < ? THEN 1 ELSE 0 END)",
+			entities.Paid, entities.Paid, date).
+		Where("payment_plan_id IN (?)", ids).Group("payment_plan_id").Rows()
+	if err != nil {
+		return statuses, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uint
+		var payments, unsettled, overdue int
+		if err := rows.Scan(&id, &payments, &unsettled, &overdue); err != nil {
+			return statuses, err
+		}
+		statuses[id] = entities.StatusOf(payments, unsettled, overdue)
+	}
+	return statuses, rows.Err()
+}
+
+// findUserPaymentPlan loads the plan given by the id path parameter if it belongs to the
+// authenticated user. Otherwise it aborts the request and returns false.
+func findUserPaymentPlan(db gorm.DB, c *gin.Context) (entities.PaymentPlan, bool) {
+	var paymentPlan entities.PaymentPlan
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	userId := int(jwt.ExtractClaims(c)["user_id"].(float64))
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
+		return paymentPlan, false
+	}
+	preloadPlanDetails(&db).Where("id = ? AND user_id = ?", id, userId).First(&paymentPlan)
+	if paymentPlan.ID == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
+		return paymentPlan, false
+	}
+	return paymentPlan, true
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	financeServices "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"gopkg.in/appleboy/gin-jwt.v2"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// maxPayoffMonths bounds the simulation for budgets that never repay the debts
+const maxPayoffMonths = 1200
+
+type PayoffController struct {
+	db              gorm.DB
+	exchangeService financeServices.ExchangeService
+}
+
+func NewPayoffController(db *gorm.DB, exchangeService financeServices.ExchangeService) PayoffController {
+	return PayoffController{db: *db, exchangeService: exchangeService}
+}
+
+// GetPayoffPlans compares the strategies of repaying all plans of the user with an extra monthly budget
+// in the base currency of the user. The custom strategy is included when the request gives an order of plans.
+func (pc PayoffController) GetPayoffPlans(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	var request entities.PayoffRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	var user entities.User
+	var paymentPlans []entities.PaymentPlan
+	pc.db.First(&user, userId)
+	preloadPlanDetails(&pc.db).Where("user_id = ?", userId).Find(&paymentPlans)
+	date := time.Now()
+	converter, err := pc.exchangeService.Converter(user.BaseCurrencyID, date)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+
+	strategies := []entities.PayoffStrategy{entities.Avalanche, entities.Snowball}
+	if len(request.Order) > 0 {
+		strategies = append(strategies, entities.Custom)
+	}
+	var payoffPlans []entities.PayoffPlan
+	for _, strategy := range strategies {
+		payoffPlan, err := PlanPayoff(paymentPlans, request, strategy, converter)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+			return
+		}
+		payoffPlans = append(payoffPlans, payoffPlan)
+	}
+	c.JSON(http.StatusOK, gin.H{"payoffPlans": payoffPlans})
+}
+
+type debt struct {
+	paymentPlan entities.PaymentPlan
+	balance     financeEntity.Money
+	remaining   []entities.Payment
+	rate        float64 //сколько базовой валюты стоит единица валюты плана
+}
+
+// toBase converts an amount of the plan currency into the base currency
+func (d debt) toBase(amount financeEntity.Money, base financeEntity.Currency) financeEntity.Money {
+	return amount.Mul(d.rate).Round(base)
+}
+
+// fromBase converts an amount of the base currency into the plan currency, rounding down
+// so that it never costs more than the amount converted
+func (d debt) fromBase(amount financeEntity.Money) financeEntity.Money {
+	currency := d.paymentPlan.Currency
+	return amount.Mul(1 / d.rate).Round(financeEntity.Currency{MinorUnits: currency.MinorUnits, Rounding: financeEntity.Down})
+}
+
+// PlanPayoff simulates repaying the plans month by month from the converter date. Every month each plan gets
+// its scheduled installments; the extra payment and the installments of plans already repaid go to the
+// plans in the order of the strategy, so freed up money rolls over to the next debt. Money is pooled in the
+// converter currency: installments and balances are converted into it at the rates of the converter date,
+// payments to each plan are reported in the plan currency, the total interest in the converter currency.
+// Without a base currency only plans in a single currency can be pooled.
+func PlanPayoff(paymentPlans []entities.PaymentPlan, request entities.PayoffRequest, strategy entities.PayoffStrategy, converter financeEntity.CurrencyConverter) (entities.PayoffPlan, error) {
+	date, base := converter.Date, converter.Currency
+	payoffPlan := entities.PayoffPlan{Strategy: strategy, CurrencyID: base.ID, DebtFreeDate: date, Months: []entities.PayoffMonth{}}
+	if request.ExtraPayment < 0 {
+		return payoffPlan, errors.New(codes.BadPayoffRequest)
+	}
+	var debts []*debt
+	var currencyId uint
+	for _, paymentPlan := range paymentPlans {
+		_, remaining, balance, _ := splitSchedule(paymentPlan, date)
+		if balance <= 0 {
+			continue
+		}
+		if base.ID == 0 && paymentPlan.CurrencyID != 0 {
+			if currencyId != 0 && currencyId != paymentPlan.CurrencyID {
+				return payoffPlan, errors.New(codes.NoBaseCurrency)
+			}
+			currencyId = paymentPlan.CurrencyID
+		}
+		rate, ok := converter.RateAt(paymentPlan.CurrencyID, base.ID)
+		if !ok || rate <= 0 {
+			return payoffPlan, errors.New(codes.NoExchangeRate)
+		}
+		debts = append(debts, &debt{paymentPlan: paymentPlan, balance: balance, remaining: remaining, rate: rate})
+	}
+	if err := orderDebts(debts, strategy, request.Order, base, date); err != nil {
+		return payoffPlan, err
+	}
+	for _, d := range debts {
+		payoffPlan.Order = append(payoffPlan.Order, d.paymentPlan.ID)
+	}
+
+	for month := 1; month <= maxPayoffMonths && !repaid(debts); month++ {
+		from, to := date.AddDate(0, month-1, 0), date.AddDate(0, month, 0)
+		budget := request.ExtraPayment
+		payments := make([]entities.PayoffPayment, len(debts))
+		for i, d := range debts {
+			scheduled := scheduledBetween(d.remaining, from, to)
+			budget += d.toBase(scheduled, base)
+			payments[i].PaymentPlanID = d.paymentPlan.ID
+			if d.balance <= 0 {
+				continue
+			}
+			interest := accruedInterest(d.paymentPlan, d.balance, from, to)
+			amount := scheduled
+			if amount > d.balance+interest || !hasPaymentsAfter(d.remaining, to) {
+				amount = d.balance + interest
+			}
+			cost := d.toBase(amount, base)
+			if cost > budget {
+				amount, cost = d.fromBase(budget), budget
+			}
+			d.balance += interest - amount
+			budget -= cost
+			payments[i].PaymentAmount = amount
+			payments[i].Interest = interest
+			payoffPlan.TotalInterest += d.toBase(interest, base)
+		}
+		for i, d := range debts {
+			if budget <= 0 || d.balance <= 0 {
+				continue
+			}
+			extra, cost := d.balance, d.toBase(d.balance, base)
+			if cost > budget {
+				extra, cost = d.fromBase(budget), budget
+			}
+			if extra <= 0 {
+				continue
+			}
+			d.balance -= extra
+			budget -= cost
+			payments[i].PaymentAmount += extra
+			payments[i].Extra = extra
+		}
+		payoffMonth := entities.PayoffMonth{Date: to}
+		for i, d := range debts {
+			if payments[i].PaymentAmount != 0 || payments[i].Interest != 0 {
+				payments[i].RemainingBalance = d.balance
+				payoffMonth.Payments = append(payoffMonth.Payments, payments[i])
+			}
+		}
+		payoffPlan.Months = append(payoffPlan.Months, payoffMonth)
+		payoffPlan.DebtFreeDate = to
+	}
+	return payoffPlan, nil
+}
+
+// orderDebts sorts the debts in the order the strategy repays them. Avalanche goes from the highest
+// rate, snowball from the smallest balance in the base currency, custom follows the order of plan ids
+// given by the user.
+func orderDebts(debts []*debt, strategy entities.PayoffStrategy, order []uint, base financeEntity.Currency, date time.Time) error {
+	switch strategy {
+	case entities.Avalanche:
+		sort.SliceStable(debts, func(i, j int) bool {
+			return debts[i].paymentPlan.RateAt(date) > debts[j].paymentPlan.RateAt(date)
+		})
+	case entities.Snowball:
+		sort.SliceStable(debts, func(i, j int) bool {
+			return debts[i].toBase(debts[i].balance, base) < debts[j].toBase(debts[j].balance, base)
+		})
+	case entities.Custom:
+		position := map[uint]int{}
+		for i, id := range order {
+			position[id] = i
+		}
+		for _, d := range debts {
+			if _, ok := position[d.paymentPlan.ID]; !ok {
+				return errors.New(codes.BadPayoffRequest)
+			}
+		}
+		sort.SliceStable(debts, func(i, j int) bool {
+			return position[debts[i].paymentPlan.ID] < position[debts[j].paymentPlan.ID]
+		})
+	default:
+		return errors.New(codes.BadPayoffRequest)
+	}
+	return nil
+}
+
+func repaid(debts []*debt) bool {
+	for _, d := range debts {
+		if d.balance > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// scheduledBetween sums the installments due after from and up to to
+func scheduledBetween(payments []entities.Payment, from time.Time, to time.Time) financeEntity.Money {
+	var total financeEntity.Money
+	for _, payment := range payments {
+		if payment.PaymentDate.After(from) && !payment.PaymentDate.After(to) {
+			total += payment.PaymentAmount
+		}
+	}
+	return total
+}
+
+func hasPaymentsAfter(payments []entities.Payment, date time.Time) bool {
+	return len(payments) > 0 && payments[len(payments)-1].PaymentDate.After(date)
+}
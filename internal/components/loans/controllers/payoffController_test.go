@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"testing"
+	"time"
+)
+
+var payoffStart = time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+func TestPlanPayoffConvertsCurrencies(t *testing.T) {
+	rub := financeEntity.Currency{ID: 1, MinorUnits: 100}
+	usd := financeEntity.Currency{ID: 2, MinorUnits: 100}
+	paymentPlans := []entities.PaymentPlan{
+		CalculateCreditWithEqualPayments(entities.PaymentPlan{ID: 1, Currency: rub, CurrencyID: 1,
+			Amount: financeEntity.NewMoney(50000), InterestRate: 10, Months: 12, StartDate: payoffStart}),
+		CalculateCreditWithEqualPayments(entities.PaymentPlan{ID: 2, Currency: usd, CurrencyID: 2,
+			Amount: financeEntity.NewMoney(1000), InterestRate: 5, Months: 12, StartDate: payoffStart}),
+	}
+	converter := financeEntity.CurrencyConverter{Currency: rub, Date: payoffStart, Rates: []financeEntity.ExchangeRate{
+		{CurrencyID: 2, QuoteCurrencyID: 1, RateDate: payoffStart, Rate: 100},
+	}}
+	request := entities.PayoffRequest{ExtraPayment: financeEntity.NewMoney(10000)}
+
+	payoffPlan, err := PlanPayoff(paymentPlans, request, entities.Snowball, converter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1000 USD is 100000 RUB, more than the 50000 RUB plan
+	if len(payoffPlan.Order) != 2 || payoffPlan.Order[0] != 1 {
+		t.Errorf("snowball order = %v, want the smaller balance in the base currency first", payoffPlan.Order)
+	}
+	if payoffPlan.CurrencyID != 1 {
+		t.Errorf("currency = %d, want the base one", payoffPlan.CurrencyID)
+	}
+	first := payoffPlan.Months[0].Payments
+	if first[0].PaymentPlanID != 1 || first[0].Extra != financeEntity.NewMoney(10000) {
+		t.Errorf("first month = %+v, want the extra payment of 10000 RUB on the first plan", first)
+	}
+	if first[1].Extra != 0 || first[1].PaymentAmount > financeEntity.NewMoney(100) {
+		t.Errorf("first month = %+v, want only the scheduled installment in USD on the second plan", first)
+	}
+	last := payoffPlan.Months[len(payoffPlan.Months)-1]
+	for _, payment := range last.Payments {
+		if payment.RemainingBalance != 0 {
+			t.Errorf("plan %d is left with %v", payment.PaymentPlanID, payment.RemainingBalance)
+		}
+	}
+	if !payoffPlan.DebtFreeDate.Before(payoffStart.AddDate(1, 0, 0)) {
+		t.Errorf("debt free on %v, want before the end of the terms", payoffPlan.DebtFreeDate)
+	}
+
+	converter.Rates = nil
+	if _, err := PlanPayoff(paymentPlans, request, entities.Snowball, converter); err == nil || err.Error() != codes.NoExchangeRate {
+		t.Errorf("payoff without an exchange rate returned %v", err)
+	}
+	if _, err := PlanPayoff(paymentPlans, request, entities.Snowball, financeEntity.CurrencyConverter{Date: payoffStart}); err == nil || err.Error() != codes.NoBaseCurrency {
+		t.Errorf("payoff of mixed currencies without a base one returned %v", err)
+	}
+	if _, err := PlanPayoff(paymentPlans[:1], request, entities.Avalanche, financeEntity.CurrencyConverter{Date: payoffStart}); err != nil {
+		t.Errorf("payoff of a single currency without a base one returned %v", err)
+	}
+}
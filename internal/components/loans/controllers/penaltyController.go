@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/services"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+type PenaltyController struct {
+	db             gorm.DB
+	penaltyService services.PenaltyService
+}
+
+func NewPenaltyController(db *gorm.DB, penaltyService services.PenaltyService) PenaltyController {
+	return PenaltyController{db: *db, penaltyService: penaltyService}
+}
+
+func (pc PenaltyController) GetPenaltiesByPlan(c *gin.Context) {
+	paymentPlan, ok := findUserPaymentPlan(pc.db, c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"penalties": paymentPlan.Penalties})
+}
+
+// AccruePenalties runs the penalty job right away instead of waiting for its schedule
+func (pc PenaltyController) AccruePenalties(c *gin.Context) {
+	count, err := pc.penaltyService.AccruePenalties(time.Now())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accrued": count})
+}
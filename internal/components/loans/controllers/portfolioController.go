@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	financeServices "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"gopkg.in/appleboy/gin-jwt.v2"
+	"net/http"
+	"sort"
+)
+
+type PortfolioController struct {
+	db              gorm.DB
+	exchangeService financeServices.ExchangeService
+}
+
+func NewPortfolioController(db *gorm.DB, exchangeService financeServices.ExchangeService) PortfolioController {
+	return PortfolioController{db: *db, exchangeService: exchangeService}
+}
+
+// GetPortfolioSummary returns the financial position of the user as of the asOf query parameter
+func (pc PortfolioController) GetPortfolioSummary(c *gin.Context) {
+	userId := uint(jwt.ExtractClaims(c)["user_id"].(float64))
+	asOf, ok := asOfDate(c)
+	if !ok {
+		return
+	}
+	user, paymentPlans, incomes, expenses := loadPortfolio(pc.db, userId)
+	converter, err := pc.exchangeService.Converter(user.BaseCurrencyID, asOf)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	summary, err := SummarizePortfolio(paymentPlans, incomes, expenses, converter)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"summary": summary})
+}
+
+// loadPortfolio loads the user with the plans, incomes and expenses a portfolio summary is made of
+func loadPortfolio(db gorm.DB, userId uint) (entities.User, []entities.PaymentPlan, []entities.Income, []entities.Expense) {
+	var user entities.User
+	var paymentPlans []entities.PaymentPlan
+	var incomes []entities.Income
+	var expenses []entities.Expense
+	db.First(&user, userId)
+	preloadPlanDetails(&db).Where("user_id = ?", userId).Find(&paymentPlans)
+	db.Where("user_id = ?", userId).Find(&incomes)
+	db.Where("user_id = ?", userId).Find(&expenses)
+	return user, paymentPlans, incomes, expenses
+}
+
+// SummarizePortfolio sums up the plans, incomes and expenses of a user on the converter date in the
+// converter currency. Debt service counts the installments due within a month after that date;
+// income and expenses count repeating records started by then, averaged per month. The next payment
+// of a plan is its earliest one not paid in full, which may be overdue. Without a base currency only
+// records in a single currency can be summed up, and the summary is in that currency.
+func SummarizePortfolio(paymentPlans []entities.PaymentPlan, incomes []entities.Income, expenses []entities.Expense, converter financeEntity.CurrencyConverter) (entities.PortfolioSummary, error) {
+	asOf := converter.Date
+	summary := entities.PortfolioSummary{
+		CurrencyID:   converter.Currency.ID,
+		AsOf:         asOf,
+		Breakdown:    []entities.PortfolioShare{},
+		NextPayments: []entities.AgendaElement{},
+	}
+	convert := func(amount financeEntity.Money, currencyID uint) (financeEntity.Money, error) {
+		if converter.Currency.ID == 0 && currencyID != 0 {
+			if summary.CurrencyID != 0 && summary.CurrencyID != currencyID {
+				return 0, errors.New(codes.NoBaseCurrency)
+			}
+			summary.CurrencyID = currencyID
+		}
+		converted, ok := converter.Convert(amount, currencyID)
+		if !ok {
+			return 0, errors.New(codes.NoExchangeRate)
+		}
+		return converted, nil
+	}
+
+	var weightedRate float64
+	monthEnd := asOf.AddDate(0, 1, 0)
+	for _, paymentPlan := range paymentPlans {
+		if paymentPlan.StartDate.After(asOf) {
+			continue
+		}
+		_, remaining, balance, _ := splitSchedule(paymentPlan, asOf)
+		if balance <= 0 {
+			continue
+		}
+		baseBalance, err := convert(balance, paymentPlan.CurrencyID)
+		if err != nil {
+			return summary, err
+		}
+		summary.Outstanding += baseBalance
+		summary.Breakdown = addShare(summary.Breakdown, entities.PortfolioShare{
+			BankID:      paymentPlan.BankID,
+			CurrencyID:  paymentPlan.CurrencyID,
+			Balance:     balance,
+			BaseBalance: baseBalance,
+		})
+
+		rate := paymentPlan.RateAt(asOf)
+		weightedRate += rate * baseBalance.Float64()
+		if rate > summary.MaxRate {
+			summary.MaxRate = rate
+		}
+
+		for _, payment := range remaining {
+			if !payment.PaymentDate.After(monthEnd) {
+				amount, err := convert(payment.PaymentAmount, paymentPlan.CurrencyID)
+				if err != nil {
+					return summary, err
+				}
+				summary.MonthlyDebtService += amount
+			}
+		}
+		for _, payment := range paymentPlan.Payments {
+			if payment.IsSettled() {
+				continue
+			}
+			payment.PaymentPlan = paymentPlan
+			element := payment.Transform(asOf)
+			if element.BaseAmount, err = convert(payment.PaymentAmount, paymentPlan.CurrencyID); err != nil {
+				return summary, err
+			}
+			summary.NextPayments = append(summary.NextPayments, element)
+			break
+		}
+	}
+	if summary.Outstanding > 0 {
+		summary.AverageRate = weightedRate / summary.Outstanding.Float64()
+	}
+	sort.SliceStable(summary.NextPayments, func(i, j int) bool {
+		return summary.NextPayments[i].PaymentDate.Before(summary.NextPayments[j].PaymentDate)
+	})
+
+	for _, income := range incomes {
+		if income.StartDate.After(asOf) {
+			continue
+		}
+		amount, err := convert(income.MonthlyAmount(), income.CurrencyID)
+		if err != nil {
+			return summary, err
+		}
+		summary.MonthlyIncome += amount
+	}
+	for _, expense := range expenses {
+		if expense.StartDate.After(asOf) {
+			continue
+		}
+		amount, err := convert(expense.MonthlyAmount(), expense.CurrencyID)
+		if err != nil {
+			return summary, err
+		}
+		summary.MonthlyExpenses += amount
+	}
+	if summary.MonthlyIncome > 0 {
+		summary.DebtToIncome = summary.MonthlyDebtService.Float64() / summary.MonthlyIncome.Float64()
+		summary.ObligationsToIncome = (summary.MonthlyDebtService + summary.MonthlyExpenses).Float64() / summary.MonthlyIncome.Float64()
+	}
+	return summary, nil
+}
+
+// addShare adds the balance of a plan to the share of its bank and currency
+func addShare(shares []entities.PortfolioShare, share entities.PortfolioShare) []entities.PortfolioShare {
+	for i := range shares {
+		if shares[i].BankID == share.BankID && shares[i].CurrencyID == share.CurrencyID {
+			shares[i].Balance += share.Balance
+			shares[i].BaseBalance += share.BaseBalance
+			return shares
+		}
+	}
+	return append(shares, share)
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"testing"
+	"time"
+)
+
+var portfolioStart = time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+func TestSummarizePortfolioCurrencies(t *testing.T) {
+	rub := financeEntity.Currency{ID: 1, MinorUnits: 100}
+	paymentPlans := []entities.PaymentPlan{CalculateCreditWithEqualPayments(entities.PaymentPlan{ID: 1, Currency: rub, CurrencyID: 1,
+		Amount: financeEntity.NewMoney(120000), InterestRate: 12, Months: 12, StartDate: portfolioStart})}
+	salary := entities.Income{Amount: financeEntity.NewMoney(1000), CurrencyID: 2, StartDate: portfolioStart,
+		IsRepeatable: true, Frequency: 1, PaymentPeriod: entities.Month}
+	asOf := portfolioStart.AddDate(0, 0, 1)
+
+	// without a base currency amounts in rubles and dollars cannot be added up
+	if _, err := SummarizePortfolio(paymentPlans, []entities.Income{salary}, nil, financeEntity.CurrencyConverter{Date: asOf}); err == nil || err.Error() != codes.NoBaseCurrency {
+		t.Errorf("summary of mixed currencies without a base one returned %v", err)
+	}
+
+	salary.CurrencyID = 1
+	summary, err := SummarizePortfolio(paymentPlans, []entities.Income{salary}, nil, financeEntity.CurrencyConverter{Date: asOf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.CurrencyID != 1 || summary.Outstanding != financeEntity.NewMoney(120000) || summary.MonthlyIncome != financeEntity.NewMoney(1000) {
+		t.Errorf("summary = %+v, want the totals in rubles", summary)
+	}
+
+	salary.CurrencyID = 2
+	converter := financeEntity.CurrencyConverter{Currency: rub, Date: asOf, Rates: []financeEntity.ExchangeRate{
+		{CurrencyID: 2, QuoteCurrencyID: 1, RateDate: portfolioStart, Rate: 100},
+	}}
+	summary, err = SummarizePortfolio(paymentPlans, []entities.Income{salary}, nil, converter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.CurrencyID != 1 || summary.MonthlyIncome != financeEntity.NewMoney(100000) {
+		t.Errorf("summary = %+v, want the income converted into rubles", summary)
+	}
+	if summary.DebtToIncome <= 0 || summary.DebtToIncome >= 1 {
+		t.Errorf("debt to income = %v", summary.DebtToIncome)
+	}
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"math"
+	"net/http"
+)
+
+type PrepaymentController struct {
+	db gorm.DB
+}
+
+func NewPrepaymentController(db *gorm.DB) PrepaymentController {
+	return PrepaymentController{db: *db}
+}
+
+func (pc PrepaymentController) GetPrepaymentsByPlan(c *gin.Context) {
+	paymentPlan, ok := findUserPaymentPlan(pc.db, c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"prepayments": paymentPlan.Prepayments})
+}
+
+// SimulatePrepayment returns the recalculated plan without saving anything
+func (pc PrepaymentController) SimulatePrepayment(c *gin.Context) {
+	paymentPlan, ok := findUserPaymentPlan(pc.db, c)
+	if !ok {
+		return
+	}
+	var prepayment entities.Prepayment
+	if err := c.ShouldBindJSON(&prepayment); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	paymentPlan, prepayment, err := ApplyPrepayment(paymentPlan, prepayment)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"paymentPlan":   paymentPlan,
+		"prepayment":    prepayment,
+		"interestSaved": prepayment.InterestSaved,
+	})
+}
+
+// AddPrepayment registers a prepayment and replaces the payments scheduled after its date
+func (pc PrepaymentController) AddPrepayment(c *gin.Context) {
+	paymentPlan, ok := findUserPaymentPlan(pc.db, c)
+	if !ok {
+		return
+	}
+	var prepayment entities.Prepayment
+	if err := c.ShouldBindJSON(&prepayment); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	paymentPlan, prepayment, err := ApplyPrepayment(paymentPlan, prepayment)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+
+	tx := pc.db.Begin()
+	err = savePaymentsAfter(tx, paymentPlan, prepayment.PrepaymentDate)
+	if err == nil {
+		err = tx.Create(&prepayment).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	tx.Commit()
+
+	paymentPlan.Prepayments[len(paymentPlan.Prepayments)-1] = prepayment
+	c.JSON(http.StatusCreated, gin.H{
+		"paymentPlan":   paymentPlan,
+		"prepayment":    prepayment,
+		"interestSaved": prepayment.InterestSaved,
+	})
+}
+
+// ApplyPrepayment recalculates the schedule of the plan after an early repayment.
+// Payments due on or before the prepayment date stay as they are; the rest are
+// rebuilt from the reduced balance either keeping the installment (ReduceTerm)
+// or keeping the number of remaining payments (ReducePayment). The term is never
+// lengthened, and ReduceTerm fails when the installment cannot repay the rest of
+// the balance. The prepayment is appended to the plan's Prepayments. A prepayment with
+// an unknown strategy is rejected.
+func ApplyPrepayment(paymentPlan entities.PaymentPlan, prepayment entities.Prepayment) (entities.PaymentPlan, entities.Prepayment, error) {
+	if prepayment.Amount <= 0 || prepayment.Strategy.String() == "Unknown" || !prepayment.PrepaymentDate.After(paymentPlan.StartDate) {
+		return paymentPlan, prepayment, errors.New(codes.BadPrepayment)
+	}
+	for _, previous := range paymentPlan.Prepayments {
+		if previous.PrepaymentDate.After(prepayment.PrepaymentDate) {
+			return paymentPlan, prepayment, errors.New(codes.BadPrepayment)
+		}
+	}
+	kept, remaining, balance, lastDate := splitSchedule(paymentPlan, prepayment.PrepaymentDate)
+	if len(remaining) == 0 || balance <= 0 {
+		return paymentPlan, prepayment, errors.New(codes.PlanRepaid)
+	}
+
+	prepayment.PaymentPlanID = paymentPlan.ID
+	if prepayment.Amount > balance {
+		prepayment.Amount = balance
+	}
+	var rest = balance - prepayment.Amount
+	var periods = uint(len(remaining))
+	if grace := remainingGrace(paymentPlan, periods, uint(len(kept))); prepayment.Strategy == entities.ReduceTerm {
+		// The term is shortened after the grace period, keeping the installment the loan is repaid with
+		_, graceEnd, balanceAfterGrace := gracePayments(paymentPlan, lastDate, balance, grace, uint(len(kept)))
+		_, _, restAfterGrace := gracePayments(paymentPlan, lastDate, rest, grace, uint(len(kept)))
+		if paymentPlan.PaymentType == entities.Even {
+			repaidIn, ok := periodsToRepay(restAfterGrace, periodRate(paymentPlan, graceEnd), remaining[grace].PaymentAmount)
+			if !ok {
+				return paymentPlan, prepayment, errors.New(codes.TermNotReducible)
+			}
+			periods = grace + repaidIn
+		} else {
+			baseFee := balanceAfterGrace.Div(periods - grace).Round(paymentPlan.Currency)
+			if baseFee <= 0 {
+				return paymentPlan, prepayment, errors.New(codes.TermNotReducible)
+			}
+			periods = grace + uint(math.Ceil(restAfterGrace.Float64()/baseFee.Float64()-1e-9))
+		}
+		if periods > uint(len(remaining)) {
+			periods = uint(len(remaining))
+		}
+	}
+	rebuilt := schedule(paymentPlan, lastDate, rest, periods, uint(len(kept)))
+	prepayment.InterestSaved = totalAmount(remaining) - totalAmount(rebuilt) - prepayment.Amount
+
+	paymentPlan.Prepayments = append(paymentPlan.Prepayments, prepayment)
+	return withSchedule(paymentPlan, kept, rebuilt), prepayment, nil
+}
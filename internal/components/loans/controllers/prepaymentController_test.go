@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"testing"
+	"time"
+)
+
+var prepaymentStart = time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+// prepaymentPlan is a year-long loan of 120000 at 12% a year, which is exactly 1% a month under 30/360
+func prepaymentPlan(paymentType entities.PaymentType) entities.PaymentPlan {
+	paymentPlan := entities.PaymentPlan{ID: 1, Amount: financeEntity.NewMoney(120000), InterestRate: 12, Months: 12,
+		StartDate: prepaymentStart, Currency: financeEntity.Currency{MinorUnits: 100}}
+	if paymentType == entities.Even {
+		return CalculateCreditWithEqualPayments(paymentPlan)
+	}
+	return CalculateCreditWithDifferentiatedPayments(paymentPlan)
+}
+
+// checkRepaid checks that the payments and prepayments of the plan repay its amount to the last cent
+func checkRepaid(t *testing.T, paymentPlan entities.PaymentPlan) {
+	t.Helper()
+	var principal financeEntity.Money
+	for _, payment := range paymentPlan.Payments {
+		principal += payment.Principal
+	}
+	for _, prepayment := range paymentPlan.Prepayments {
+		principal += prepayment.Amount
+	}
+	if principal != paymentPlan.Amount {
+		t.Errorf("principal repaid = %v, want %v", principal, paymentPlan.Amount)
+	}
+	if last := paymentPlan.Payments[len(paymentPlan.Payments)-1]; last.RemainingBalance != 0 {
+		t.Errorf("balance after the last payment = %v", last.RemainingBalance)
+	}
+}
+
+func TestApplyPrepaymentReducePayment(t *testing.T) {
+	for _, paymentType := range []entities.PaymentType{entities.Even, entities.Differentiated} {
+		before := prepaymentPlan(paymentType)
+		prepayment := entities.Prepayment{PrepaymentDate: time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC),
+			Amount: financeEntity.NewMoney(30000), Strategy: entities.ReducePayment}
+		after, applied, err := ApplyPrepayment(before, prepayment)
+		if err != nil {
+			t.Fatalf("%v: %v", paymentType, err)
+		}
+		if len(after.Payments) != 12 || !after.Payments[11].PaymentDate.Equal(before.Payments[11].PaymentDate) {
+			t.Errorf("%v: %d payments until %v, want the same term", paymentType, len(after.Payments), after.Payments[len(after.Payments)-1].PaymentDate)
+		}
+		for i := 0; i < 2; i++ {
+			if after.Payments[i].PaymentAmount != before.Payments[i].PaymentAmount ||
+				!after.Payments[i].PaymentDate.Equal(before.Payments[i].PaymentDate) {
+				t.Errorf("%v: payment %d due before the prepayment changed to %+v", paymentType, i, after.Payments[i])
+			}
+		}
+		if after.Payments[2].PaymentAmount >= before.Payments[2].PaymentAmount {
+			t.Errorf("%v: installment after the prepayment = %v, want less than %v",
+				paymentType, after.Payments[2].PaymentAmount, before.Payments[2].PaymentAmount)
+		}
+		if applied.InterestSaved <= 0 || after.TotalPaymentAmount != before.TotalPaymentAmount-applied.InterestSaved {
+			t.Errorf("%v: interest saved = %v, total %v before and %v after",
+				paymentType, applied.InterestSaved, before.TotalPaymentAmount, after.TotalPaymentAmount)
+		}
+		if len(after.Prepayments) != 1 || applied.PaymentPlanID != 1 {
+			t.Errorf("%v: prepayments = %+v", paymentType, after.Prepayments)
+		}
+		checkRepaid(t, after)
+	}
+}
+
+func TestApplyPrepaymentReduceTerm(t *testing.T) {
+	for _, paymentType := range []entities.PaymentType{entities.Even, entities.Differentiated} {
+		before := prepaymentPlan(paymentType)
+		prepayment := entities.Prepayment{PrepaymentDate: time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC),
+			Amount: financeEntity.NewMoney(30000), Strategy: entities.ReduceTerm}
+		after, applied, err := ApplyPrepayment(before, prepayment)
+		if err != nil {
+			t.Fatalf("%v: %v", paymentType, err)
+		}
+		if len(after.Payments) >= 12 || after.Months >= 12 {
+			t.Errorf("%v: %d payments over %d months, want a shorter term", paymentType, len(after.Payments), after.Months)
+		}
+		if paymentType == entities.Even {
+			// the installment is sized anew for the shortened term, so it may only go down
+			for _, payment := range after.Payments {
+				if payment.PaymentAmount > before.Payments[0].PaymentAmount {
+					t.Errorf("installment = %v, want at most %v", payment.PaymentAmount, before.Payments[0].PaymentAmount)
+				}
+			}
+		} else if after.Payments[2].Principal != before.Payments[2].Principal {
+			t.Errorf("principal part = %v, want %v kept", after.Payments[2].Principal, before.Payments[2].Principal)
+		}
+		if applied.InterestSaved <= 0 {
+			t.Errorf("%v: interest saved = %v", paymentType, applied.InterestSaved)
+		}
+		checkRepaid(t, after)
+	}
+}
+
+func TestApplyPrepaymentErrors(t *testing.T) {
+	paymentPlan := prepaymentPlan(entities.Even)
+	april := time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC)
+	withPrepayment, _, err := ApplyPrepayment(paymentPlan, entities.Prepayment{PrepaymentDate: april, Amount: financeEntity.NewMoney(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name        string
+		paymentPlan entities.PaymentPlan
+		prepayment  entities.Prepayment
+		want        string
+	}{
+		{"zero amount", paymentPlan, entities.Prepayment{PrepaymentDate: april}, codes.BadPrepayment},
+		{"negative amount", paymentPlan, entities.Prepayment{PrepaymentDate: april, Amount: -1}, codes.BadPrepayment},
+		{"unknown strategy", paymentPlan, entities.Prepayment{PrepaymentDate: april, Amount: 1, Strategy: 2}, codes.BadPrepayment},
+		{"negative strategy", paymentPlan, entities.Prepayment{PrepaymentDate: april, Amount: 1, Strategy: -1}, codes.BadPrepayment},
+		{"on the start date", paymentPlan, entities.Prepayment{PrepaymentDate: prepaymentStart, Amount: 1}, codes.BadPrepayment},
+		{"before a previous one", withPrepayment,
+			entities.Prepayment{PrepaymentDate: april.AddDate(0, 0, -1), Amount: 1}, codes.BadPrepayment},
+		{"after the last payment", paymentPlan,
+			entities.Prepayment{PrepaymentDate: prepaymentStart.AddDate(1, 0, 0), Amount: 1}, codes.PlanRepaid},
+	} {
+		if _, _, err := ApplyPrepayment(test.paymentPlan, test.prepayment); err == nil || err.Error() != test.want {
+			t.Errorf("%s: error = %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestApplyPrepaymentRepaysWholeBalance(t *testing.T) {
+	before := prepaymentPlan(entities.Even)
+	after, applied, err := ApplyPrepayment(before, entities.Prepayment{PrepaymentDate: time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC),
+		Amount: financeEntity.NewMoney(1000000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Payments) != 2 || applied.Amount != before.Payments[1].RemainingBalance {
+		t.Errorf("%d payments left and %v prepaid, want none left and the balance of %v prepaid",
+			len(after.Payments)-2, applied.Amount, before.Payments[1].RemainingBalance)
+	}
+	if repaid := after.Payments[0].Principal + after.Payments[1].Principal + applied.Amount; repaid != before.Amount {
+		t.Errorf("principal repaid = %v, want %v", repaid, before.Amount)
+	}
+}
+
+func TestApplyPrepaymentTermNotReducible(t *testing.T) {
+	prepayment := entities.Prepayment{PrepaymentDate: time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC),
+		Amount: financeEntity.NewMoney(100), Strategy: entities.ReduceTerm}
+
+	// the installment sized for 12% does not even cover the interest at 200% from the third period on
+	paymentPlan := prepaymentPlan(entities.Even)
+	paymentPlan.RateChanges = []entities.RateChange{{ChangeDate: paymentPlan.DueDate(2), InterestRate: 200}}
+	if _, _, err := ApplyPrepayment(paymentPlan, prepayment); err == nil || err.Error() != codes.TermNotReducible {
+		t.Errorf("installment below the interest: error = %v", err)
+	}
+
+	// the installment covers the interest at 20%, but repaying the rest with it takes longer than the term
+	paymentPlan.RateChanges[0].InterestRate = 20
+	after, _, err := ApplyPrepayment(paymentPlan, prepayment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Payments) != 12 || !after.Payments[11].PaymentDate.Equal(paymentPlan.Payments[11].PaymentDate) {
+		t.Errorf("%d payments until %v, want the term kept", len(after.Payments), after.Payments[len(after.Payments)-1].PaymentDate)
+	}
+	checkRepaid(t, after)
+
+	// a few cents left over eleven payments give a differentiated installment rounded down to zero
+	paymentPlan = entities.PaymentPlan{Amount: financeEntity.NewMoney(100), Months: 12, StartDate: prepaymentStart,
+		PaymentType: entities.Differentiated, Currency: financeEntity.Currency{MinorUnits: 100}}
+	paymentPlan.Payments = append([]entities.Payment{{PaymentDate: paymentPlan.DueDate(1), PaymentAmount: 999500}},
+		CalculateCreditWithDifferentiatedPayments(paymentPlan).Payments[1:]...)
+	prepayment.Amount = 100
+	prepayment.PrepaymentDate = time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if _, _, err := ApplyPrepayment(paymentPlan, prepayment); err == nil || err.Error() != codes.TermNotReducible {
+		t.Errorf("zero differentiated installment: error = %v", err)
+	}
+}
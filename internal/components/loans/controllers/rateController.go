@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntities "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"strconv"
+)
+
+// RateController manages floating rates: rate change schedules of plans and rate indexes
+type RateController struct {
+	db gorm.DB
+}
+
+func NewRateController(db *gorm.DB) RateController {
+	return RateController{db: *db}
+}
+
+func (rc RateController) GetRateChanges(c *gin.Context) {
+	paymentPlan, ok := findUserPaymentPlan(rc.db, c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rateChanges": paymentPlan.RateChanges})
+}
+
+// AddRateChange changes the rate of the plan from the given date and recalculates the payments after it
+func (rc RateController) AddRateChange(c *gin.Context) {
+	paymentPlan, ok := findUserPaymentPlan(rc.db, c)
+	if !ok {
+		return
+	}
+	var rateChange entities.RateChange
+	if err := c.ShouldBindJSON(&rateChange); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	if paymentPlan.RateIndexID != 0 || rateChange.ChangeDate.Before(paymentPlan.StartDate) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadRateChange})
+		return
+	}
+	rateChange.PaymentPlanID = paymentPlan.ID
+	paymentPlan.RateChanges = append(paymentPlan.RateChanges, rateChange)
+	paymentPlan, err := RecalculateFrom(paymentPlan, rateChange.ChangeDate)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+
+	tx := rc.db.Begin()
+	err = savePaymentsAfter(tx, paymentPlan, rateChange.ChangeDate)
+	if err == nil {
+		err = tx.Create(&rateChange).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	tx.Commit()
+
+	paymentPlan.RateChanges[len(paymentPlan.RateChanges)-1] = rateChange
+	c.JSON(http.StatusCreated, gin.H{"paymentPlan": paymentPlan, "rateChange": rateChange})
+}
+
+func (rc RateController) GetRateIndexes(c *gin.Context) {
+	var rateIndexes []financeEntities.RateIndex
+	rc.db.Preload("Values", func(db *gorm.DB) *gorm.DB {
+		return db.Order("value_date")
+	}).Find(&rateIndexes)
+	c.JSON(http.StatusOK, gin.H{"count": len(rateIndexes), "rateIndexes": rateIndexes})
+}
+
+func (rc RateController) AddRateIndex(c *gin.Context) {
+	var rateIndex financeEntities.RateIndex
+	if err := c.ShouldBindJSON(&rateIndex); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	if err := rc.db.Create(&rateIndex).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.ResourceExists})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"rateIndex": rateIndex})
+}
+
+// AddRateIndexValue publishes an index value, replacing the one published for the same date, and recalculates
+// every plan linked to the index from its date
+func (rc RateController) AddRateIndexValue(c *gin.Context) {
+	var rateIndex financeEntities.RateIndex
+	var indexValue financeEntities.RateIndexValue
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadID})
+		return
+	}
+	rc.db.First(&rateIndex, id)
+	if rateIndex.ID == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": codes.ResNotFound})
+		return
+	}
+	if err := c.ShouldBindJSON(&indexValue); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	if indexValue.ValueDate.IsZero() {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadRateIndexValue})
+		return
+	}
+	indexValue.RateIndexID = rateIndex.ID
+
+	var paymentPlans []entities.PaymentPlan
+	tx := rc.db.Begin()
+	err = tx.Where(financeEntities.RateIndexValue{RateIndexID: indexValue.RateIndexID, ValueDate: indexValue.ValueDate}).
+		Assign(map[string]interface{}{"value": indexValue.Value}).FirstOrCreate(&indexValue).Error
+	if err == nil {
+		err = preloadPlanDetails(tx).Where("rate_index_id = ?", rateIndex.ID).Find(&paymentPlans).Error
+	}
+	var recalculated = 0
+	for _, paymentPlan := range paymentPlans {
+		if err != nil {
+			break
+		}
+		paymentPlan, recalculationErr := RecalculateFrom(paymentPlan, indexValue.ValueDate)
+		if recalculationErr != nil {
+			continue
+		}
+		err = savePaymentsAfter(tx, paymentPlan, indexValue.ValueDate)
+		recalculated++
+	}
+	if err != nil {
+		tx.Rollback()
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": codes.InternalError})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"rateIndexValue": indexValue, "recalculatedPlans": recalculated})
+}
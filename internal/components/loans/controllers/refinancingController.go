@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/http"
+	"time"
+)
+
+type RefinancingController struct {
+	db gorm.DB
+}
+
+func NewRefinancingController(db *gorm.DB) RefinancingController {
+	return RefinancingController{db: *db}
+}
+
+// CompareRefinancing compares the rest of the plan with a candidate offer without saving anything
+func (rc RefinancingController) CompareRefinancing(c *gin.Context) {
+	paymentPlan, ok := findUserPaymentPlan(rc.db, c)
+	if !ok {
+		return
+	}
+	var offer entities.RefinancingOffer
+	if err := c.ShouldBindJSON(&offer); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.InvalidJSON})
+		return
+	}
+	if offer.StartDate.IsZero() {
+		offer.StartDate = time.Now()
+	}
+	comparison, err := CompareRefinancing(paymentPlan, offer)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"comparison": comparison})
+}
+
+// CompareRefinancing calculates a loan that repays the balance of the plan outstanding on the
+// offer start date and compares what is left to pay under both. One-off fees of the current plan
+// are already paid and are not counted; recurring ones are.
+func CompareRefinancing(paymentPlan entities.PaymentPlan, offer entities.RefinancingOffer) (entities.RefinancingComparison, error) {
+	var comparison entities.RefinancingComparison
+	if offer.Months == 0 || offer.InterestRate < 0 {
+		return comparison, errors.New(codes.BadRefinancingOffer)
+	}
+	_, remaining, balance, _ := splitSchedule(paymentPlan, offer.StartDate)
+	if len(remaining) == 0 || balance <= 0 {
+		return comparison, errors.New(codes.PlanRepaid)
+	}
+
+	offerPlan := entities.PaymentPlan{
+		Title:        paymentPlan.Title,
+		UserID:       paymentPlan.UserID,
+		BankID:       offer.BankID,
+		Currency:     paymentPlan.Currency,
+		CurrencyID:   paymentPlan.CurrencyID,
+		Amount:       balance,
+		InterestRate: offer.InterestRate,
+		Months:       offer.Months,
+		DayCount:     offer.DayCount,
+		StartDate:    offer.StartDate,
+		Fees:         offer.Fees,
+	}
+	if offer.PaymentType == entities.Even {
+		offerPlan = CalculateCreditWithEqualPayments(offerPlan)
+	} else {
+		offerPlan = CalculateCreditWithDifferentiatedPayments(offerPlan)
+	}
+
+	currentCosts := monthlyCosts(paymentPlan, remaining, offer.StartDate, false)
+	offerCosts := monthlyCosts(offerPlan, offerPlan.Payments, offer.StartDate, true)
+	comparison = entities.RefinancingComparison{
+		Balance:        balance,
+		CurrentCost:    currentCosts[len(currentCosts)-1],
+		OfferCost:      offerCosts[len(offerCosts)-1],
+		CurrentPayment: remaining[0].PaymentAmount,
+		BreakEvenMonth: breakEvenMonth(currentCosts, offerCosts),
+		CurrentPlan:    paymentPlan.WithInterestTotals(offer.StartDate),
+		OfferPlan:      offerPlan,
+	}
+	if len(offerPlan.Payments) > 0 {
+		comparison.OfferPayment = offerPlan.Payments[0].PaymentAmount
+	}
+	comparison.Savings = comparison.CurrentCost - comparison.OfferCost
+	comparison.PaymentDifference = comparison.CurrentPayment - comparison.OfferPayment
+	return comparison, nil
+}
+
+// monthlyCosts returns how much the borrower has paid for the given payments of the plan by the end of
+// every month since date, recurring fees included. The 0-th month holds one-off fees if they are counted.
+func monthlyCosts(paymentPlan entities.PaymentPlan, payments []entities.Payment, date time.Time, withOneOffFees bool) []financeEntity.Money {
+	var recurring, total financeEntity.Money
+	for _, fee := range paymentPlan.Fees {
+		if fee.FeeType == entities.Recurring {
+			recurring += fee.Charge(paymentPlan.Amount)
+		} else if withOneOffFees {
+			total += fee.Charge(paymentPlan.Amount)
+		}
+	}
+	costs := []financeEntity.Money{total}
+	for i := 0; i < len(payments); {
+		monthEnd := date.AddDate(0, len(costs), 0)
+		for ; i < len(payments) && !payments[i].PaymentDate.After(monthEnd); i++ {
+			total += payments[i].PaymentAmount + recurring
+		}
+		costs = append(costs, total)
+	}
+	return costs
+}
+
+// breakEvenMonth returns the first month from which the offer has cost no more than the current plan
+// and stays so until both are repaid, or 0 if the offer never pays off
+func breakEvenMonth(currentCosts []financeEntity.Money, offerCosts []financeEntity.Money) uint {
+	last := len(currentCosts) - 1
+	if len(offerCosts)-1 > last {
+		last = len(offerCosts) - 1
+	}
+	var month uint
+	for i := last; i > 0 && costAt(offerCosts, i) <= costAt(currentCosts, i); i-- {
+		month = uint(i)
+	}
+	return month
+}
+
+func costAt(costs []financeEntity.Money, month int) financeEntity.Money {
+	if month >= len(costs) {
+		return costs[len(costs)-1]
+	}
+	return costs[month]
+}
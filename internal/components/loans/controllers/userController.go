@@ -90,6 +90,10 @@ func (uc UserController) UpdateUserByJWT(c *gin.Context) {
 	role := user.Role
 	c.ShouldBindWith(&user, binding.JSON)
 	user.Role = role
+	if user.BaseCurrencyID != 0 && !uc.userService.CurrencyExists(user.BaseCurrencyID) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"message": codes.BadBaseCurrency})
+		return
+	}
 	user = uc.userService.UpdateUser(user)
 	c.JSON(http.StatusOK, gin.H{
 		"status": "OK",
@@ -0,0 +1,27 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+type AffordabilityRequest struct {
+	DebtToIncomeCap float64            `json:"debtToIncomeCap"` //доля дохода, которую можно отдавать на кредиты, по умолчанию 0.4
+	InterestRate    float64            `json:"interestRate"`
+	Months          uint               `json:"numberOfMonths"`
+	DayCount        DayCountConvention `json:"dayCount"`
+	StartDate       time.Time          `json:"startDate"` //по умолчанию сегодня
+}
+
+// Affordability is how much more a user can borrow. Amounts are in CurrencyID, the base currency of the user
+// or, without one, the single currency of their records.
+type Affordability struct {
+	CurrencyID                  uint                `json:"currencyId"`
+	DebtToIncomeCap             float64             `json:"debtToIncomeCap"`
+	MonthlyIncome               financeEntity.Money `json:"monthlyIncome"`
+	MonthlyExpenses             financeEntity.Money `json:"monthlyExpenses"`
+	MonthlyDebtService          financeEntity.Money `json:"monthlyDebtService"`
+	MaxPayment                  financeEntity.Money `json:"maxPayment"`                  //наибольший новый ежемесячный платеж
+	MaxEvenLoanAmount           financeEntity.Money `json:"maxEvenLoanAmount"`           //наибольший кредит с аннуитетными платежами
+	MaxDifferentiatedLoanAmount financeEntity.Money `json:"maxDifferentiatedLoanAmount"` //наибольший кредит с дифференцированными платежами
+}
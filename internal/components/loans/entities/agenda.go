@@ -1,14 +1,74 @@
 package entities
 
-import "time"
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
 
 type AgendaElement struct {
-	ElementType   string    `json:"elementType"`
-	ID            uint      `json:"id"`
-	UserID        uint      `json:"userId"`
-	Title         string    `json:"title"`
-	PaymentAmount float64   `json:"paymentAmount"`
-	PaymentDate   time.Time `json:"paymentDate"`
+	ElementType   string              `json:"elementType"`
+	ID            uint                `json:"id"`
+	UserID        uint                `json:"userId"`
+	Title         string              `json:"title"`
+	PaymentAmount financeEntity.Money `json:"paymentAmount"`
+	PaymentDate   time.Time           `json:"paymentDate"`
+	IsOverdue     bool                `json:"isOverdue"` //платеж по кредиту просрочен на AgendaQuery.AsOf
+	CurrencyID    uint                `json:"currencyId"`
+	BaseAmount    financeEntity.Money `json:"baseAmount"` //PaymentAmount в базовой валюте пользователя
+	PaymentPlanID uint                `json:"paymentPlanId,omitempty"`
+	CategoryID    uint                `json:"categoryId,omitempty"`
+	Unconverted   bool                `json:"unconverted,omitempty"` //нет курса к базовой валюте, в итоги не входит
+}
+
+// AgendaTotals sums agenda elements in one currency
+type AgendaTotals struct {
+	CurrencyID  uint                `json:"currencyId"`
+	Incomes     financeEntity.Money `json:"incomes"`
+	Expenses    financeEntity.Money `json:"expenses"`
+	Payments    financeEntity.Money `json:"payments"`
+	Balance     financeEntity.Money `json:"balance"`     //доходы за вычетом расходов и платежей
+	Unconverted int                 `json:"unconverted"` //сколько элементов не вошло в итоги из-за отсутствия курса
+}
+
+// AgendaQuery selects, orders, groups and pages agenda elements. Amount bounds apply to amounts in the base currency.
+type AgendaQuery struct {
+	From         time.Time
+	To           time.Time
+	AsOf         time.Time //на этот момент платежи считаются просроченными
+	Overdue      bool      //добавить платежи, просроченные на AsOf, даже если они раньше From
+	ElementTypes []string  //Income, Expense, Payment; пустой - все
+	PlanIDs      []uint    //только платежи этих планов
+	CategoryIDs  []uint    //только доходы и расходы этих категорий и их подкатегорий
+	MinAmount    *financeEntity.Money
+	MaxAmount    *financeEntity.Money
+	SortByAmount bool //иначе по дате
+	Descending   bool
+	GroupBy      *TimePeriod //Day, Week или Month, nil - без групп
+	Limit        int         //0 - без ограничения
+	Cursor       string      //из nextCursor предыдущей страницы
+}
+
+// Includes tells whether elements of the type are selected
+func (query AgendaQuery) Includes(elementType string) bool {
+	if len(query.ElementTypes) == 0 {
+		return true
+	}
+	for _, selected := range query.ElementTypes {
+		if selected == elementType {
+			return true
+		}
+	}
+	return false
+}
+
+// AgendaGroup sums up the agenda elements of a day, a week or a month
+type AgendaGroup struct {
+	Start    time.Time           `json:"start"`
+	Count    int                 `json:"count"`
+	Incomes  financeEntity.Money `json:"incomes"`
+	Expenses financeEntity.Money `json:"expenses"`
+	Payments financeEntity.Money `json:"payments"`
+	Balance  financeEntity.Money `json:"balance"`
 }
 
 type AgendaElementTransformable interface {
@@ -0,0 +1,43 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+// Budget limits the monthly amount of a category in the base currency of the user. A budget for a particular
+// month overrides the one set for every month.
+type Budget struct {
+	ID         uint                `gorm:"primary_key" json:"id"`
+	CreatedAt  time.Time           `json:"-"`
+	UpdatedAt  time.Time           `json:"-"`
+	UserID     uint                `json:"userId" gorm:"unique_index:idx_budget"`
+	Category   Category            `json:"-" gorm:"save_associations:false"`
+	CategoryID uint                `json:"categoryId" gorm:"unique_index:idx_budget"`
+	Month      time.Time           `json:"month" gorm:"unique_index:idx_budget"` //первое число месяца, пустая дата - каждый месяц
+	Amount     financeEntity.Money `json:"amount"`
+}
+
+// BudgetReport compares actual incomes and expenses with the budgets of every month of a period
+type BudgetReport struct {
+	CurrencyID uint          `json:"currencyId"`
+	Months     []BudgetMonth `json:"months"`
+}
+
+type BudgetMonth struct {
+	Month       time.Time        `json:"month"`
+	Categories  []CategoryBudget `json:"categories"`
+	Unconverted int              `json:"unconverted"` //сколько доходов и расходов не учтено из-за отсутствия курса
+}
+
+// CategoryBudget is the actual amount of a category in a month, subcategories included, against its budget
+type CategoryBudget struct {
+	CategoryID   uint                `json:"categoryId"` //0 для записей без категории
+	Name         string              `json:"name"`
+	ParentID     uint                `json:"parentId"`
+	CategoryType CategoryType        `json:"categoryType"`
+	Actual       financeEntity.Money `json:"actual"`
+	Budget       financeEntity.Money `json:"budget"`    //0, если бюджет не задан
+	Remaining    financeEntity.Money `json:"remaining"` //бюджет за вычетом фактической суммы
+	IsOverBudget bool                `json:"isOverBudget"`
+}
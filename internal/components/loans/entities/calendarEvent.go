@@ -0,0 +1,14 @@
+package entities
+
+import "time"
+
+// CalendarEvent is an all-day event of the iCalendar feed of a user
+type CalendarEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Date        time.Time
+	Rule        *RecurrenceRule //nil для однократных событий
+	ExDates     []time.Time
+	RDates      []time.Time //даты повторений, если правило нельзя передать RRULE
+}
@@ -0,0 +1,32 @@
+package entities
+
+import "time"
+
+// Category groups incomes or expenses. Categories without a user are the system defaults shared by everyone.
+type Category struct {
+	ID           uint         `gorm:"primary_key" json:"id"`
+	CreatedAt    time.Time    `json:"-"`
+	UpdatedAt    time.Time    `json:"-"`
+	UserID       uint         `json:"userId"` //0 для системных категорий
+	Name         string       `json:"name"`
+	CategoryType CategoryType `json:"categoryType"`
+	ParentID     uint         `json:"parentId"` //0 для категорий верхнего уровня
+}
+
+// DefaultCategories are created on start unless they already exist
+var DefaultCategories = []Category{
+	{Name: "Salary", CategoryType: IncomeCategory},
+	{Name: "Bonus", CategoryType: IncomeCategory},
+	{Name: "Investments", CategoryType: IncomeCategory},
+	{Name: "Gifts", CategoryType: IncomeCategory},
+	{Name: "Other income", CategoryType: IncomeCategory},
+	{Name: "Housing", CategoryType: ExpenseCategory},
+	{Name: "Utilities", CategoryType: ExpenseCategory},
+	{Name: "Groceries", CategoryType: ExpenseCategory},
+	{Name: "Transport", CategoryType: ExpenseCategory},
+	{Name: "Health", CategoryType: ExpenseCategory},
+	{Name: "Education", CategoryType: ExpenseCategory},
+	{Name: "Entertainment", CategoryType: ExpenseCategory},
+	{Name: "Clothing", CategoryType: ExpenseCategory},
+	{Name: "Other expenses", CategoryType: ExpenseCategory},
+}
@@ -0,0 +1,18 @@
+package entities
+
+const (
+	IncomeCategory  CategoryType = 0
+	ExpenseCategory CategoryType = 1
+)
+
+type CategoryType int
+
+func (categoryType CategoryType) String() string {
+	names := [...]string{
+		"Income",
+		"Expense"}
+	if categoryType < IncomeCategory || categoryType > ExpenseCategory {
+		return "Unknown"
+	}
+	return names[categoryType]
+}
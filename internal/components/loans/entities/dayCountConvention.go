@@ -0,0 +1,64 @@
+package entities
+
+import "time"
+
+const (
+	Thirty360    DayCountConvention = 0
+	Actual365    DayCountConvention = 1
+	ActualActual DayCountConvention = 2
+)
+
+type DayCountConvention int
+
+func (convention DayCountConvention) String() string {
+	names := [...]string{
+		"30/360",
+		"Actual/365",
+		"Actual/Actual"}
+	if convention < Thirty360 || convention > ActualActual {
+		return "Unknown"
+	}
+	return names[convention]
+}
+
+// YearFraction returns the part of a year interest accrues for between two dates.
+// 30/360 counts every whole month as 30 days of a 360-day year, Actual/365 counts
+// calendar days of a 365-day year and Actual/Actual divides the days falling on
+// each calendar year by the length of that year.
+func (convention DayCountConvention) YearFraction(from time.Time, to time.Time) float64 {
+	switch convention {
+	case Actual365:
+		return days(from, to) / 365
+	case ActualActual:
+		var fraction float64
+		for from.Year() < to.Year() {
+			nextYear := time.Date(from.Year()+1, time.January, 1, 0, 0, 0, 0, from.Location())
+			fraction += days(from, nextYear) / daysInYear(from.Year())
+			from = nextYear
+		}
+		return fraction + days(from, to)/daysInYear(to.Year())
+	default:
+		months := 12*(to.Year()-from.Year()) + int(to.Month()) - int(from.Month())
+		if isLastDayOfMonth(from) && isLastDayOfMonth(to) {
+			return 30 * float64(months) / 360
+		}
+		if addMonths(from, months).After(to) {
+			months--
+		}
+		return (30*float64(months) + days(addMonths(from, months), to)) / 360
+	}
+}
+
+func days(from time.Time, to time.Time) float64 {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return to.Sub(from).Hours() / 24
+}
+
+func daysInYear(year int) float64 {
+	return days(time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func isLastDayOfMonth(date time.Time) bool {
+	return date.AddDate(0, 0, 1).Day() == 1
+}
@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestYearFraction(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	for _, test := range []struct {
+		convention DayCountConvention
+		from       time.Time
+		to         time.Time
+		want       float64
+	}{
+		{Thirty360, date(2020, time.January, 15), date(2020, time.February, 15), 30.0 / 360},
+		{Thirty360, date(2020, time.January, 15), date(2021, time.January, 15), 1},
+		{Thirty360, date(2020, time.January, 31), date(2020, time.February, 29), 30.0 / 360},
+		{Thirty360, date(2020, time.January, 30), date(2020, time.February, 29), 30.0 / 360},
+		{Thirty360, date(2020, time.January, 15), date(2020, time.March, 1), 45.0 / 360},
+		{Thirty360, date(2020, time.January, 15), date(2020, time.January, 25), 10.0 / 360},
+		{Actual365, date(2020, time.January, 15), date(2020, time.February, 15), 31.0 / 365},
+		{Actual365, date(2020, time.January, 1), date(2021, time.January, 1), 366.0 / 365},
+		{ActualActual, date(2020, time.January, 1), date(2021, time.January, 1), 1},
+		{ActualActual, date(2019, time.December, 1), date(2020, time.February, 1), 31.0/365 + 31.0/366},
+		{ActualActual, date(2019, time.June, 1), date(2021, time.June, 1), 214.0/365 + 1 + 151.0/365},
+		{Actual365, time.Date(2020, time.March, 28, 23, 0, 0, 0, time.UTC), time.Date(2020, time.March, 30, 1, 0, 0, 0, time.UTC), 2.0 / 365},
+	} {
+		if got := test.convention.YearFraction(test.from, test.to); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("%v from %v to %v = %v, want %v", test.convention, test.from, test.to, got, test.want)
+		}
+	}
+}
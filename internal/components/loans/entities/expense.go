@@ -1,20 +1,31 @@
 package entities
 
-import "time"
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
 
 type Expense struct {
-	ID             uint       `gorm:"primary_key" json:"id"`
-	CreatedAt      time.Time  `json:"-"`
-	UpdatedAt      time.Time  `json:"-"`
-	User           User       `json:"user"`
-	UserID         uint       `json:"userId"`
-	Reason         string     `json:"reason"`
-	Amount         float64    `json:"amount"`
-	StartDate      time.Time  `json:"startDate"`
-	IsRepeatable   bool       `json:"isRepeatable"` //рекуррентный платеж или нет
-	Frequency      int        `json:"frequency"`
-	PaymentPeriod  TimePeriod `json:"paymentPeriod"`
-	RecurrentCount uint       `json:"recurrentCount"` //число повторений в TimePeriod(4 недели, 12 лет)
+	ID             uint                   `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time              `json:"-"`
+	UpdatedAt      time.Time              `json:"-"`
+	User           User                   `json:"user"`
+	UserID         uint                   `json:"userId"`
+	Reason         string                 `json:"reason"`
+	Amount         financeEntity.Money    `json:"amount"`
+	Currency       financeEntity.Currency `json:"-" gorm:"save_associations:false"`
+	CurrencyID     uint                   `json:"currencyId"`
+	Category       Category               `json:"-" gorm:"save_associations:false"`
+	CategoryID     uint                   `json:"categoryId"`
+	StartDate      time.Time              `json:"startDate"`
+	IsRepeatable   bool                   `json:"isRepeatable"` //рекуррентный платеж или нет
+	Frequency      int                    `json:"frequency"`
+	PaymentPeriod  TimePeriod             `json:"paymentPeriod"`
+	RecurrentCount uint                   `json:"recurrentCount"`          //число повторений, 0 - без ограничения
+	EndDate        time.Time              `json:"endDate"`                 //последняя возможная дата повторения, пустая - без ограничения
+	RRule          string                 `json:"rrule"`                   //правило повторения RFC 5545, заменяет frequency и paymentPeriod
+	ExDates        string                 `json:"exDates"`                 //даты-исключения через запятую, например 2019-01-15,2019-02-15
+	ExternalID     string                 `json:"externalId" gorm:"index"` //идентификатор операции из банковской выписки
 }
 
 func (e Expense) TransformSingle() AgendaElement {
@@ -25,46 +36,47 @@ func (e Expense) TransformSingle() AgendaElement {
 		e.Reason,
 		e.Amount,
 		e.StartDate,
+		false,
+		e.CurrencyID,
+		0,
+		0,
+		e.CategoryID,
+		false,
 	}
 	return singleElement
 }
 
 func (e Expense) TransformWithPeriod(from time.Time, to time.Time) []AgendaElement {
-	if !e.IsRepeatable {
-		return []AgendaElement{e.TransformSingle()}
+	rule, err := e.Recurrence()
+	if !e.IsRepeatable || err != nil {
+		if e.StartDate.After(from) && e.StartDate.Before(to) {
+			return []AgendaElement{e.TransformSingle()}
+		}
+		return []AgendaElement{}
 	}
+	exceptions, _ := ParseExceptionDates(e.ExDates)
 	var elements []AgendaElement
-	currentIncomeTime := e.StartDate
-	for currentIncomeTime.Before(to) {
-		if currentIncomeTime.After(from) {
-			element := AgendaElement{
-				"Expense",
-				e.ID,
-				e.UserID,
-				e.Reason,
-				e.Amount,
-				currentIncomeTime,
-			}
-			elements = append(elements, element)
-		}
-		currentIncomeTime = e.getNextPeriod(currentIncomeTime)
+	for _, date := range rule.Between(e.StartDate, from, to, exceptions) {
+		element := e.TransformSingle()
+		element.PaymentDate = date
+		elements = append(elements, element)
 	}
 	return elements
 }
 
-func (e Expense) getNextPeriod(currentTime time.Time) time.Time {
-	switch e.PaymentPeriod {
-	case Day:
-		return currentTime.AddDate(0, 0, e.Frequency)
-	case Week:
-		return currentTime.AddDate(0, 0, 7*e.Frequency)
-	case Month:
-		return currentTime.AddDate(0, e.Frequency, 0)
-	case Quarter:
-		return currentTime.AddDate(0, 3*e.Frequency, 0)
-	case Year:
-		return currentTime.AddDate(e.Frequency, 0, 0)
-	default:
-		return currentTime
+// Recurrence returns the rule the expense repeats by
+func (e Expense) Recurrence() (RecurrenceRule, error) {
+	return recurrenceOf(e.RRule, e.Frequency, e.PaymentPeriod, e.RecurrentCount, e.EndDate)
+}
+
+// MonthlyAmount returns the average amount per month of a repeating expense, zero for a single one
+func (e Expense) MonthlyAmount() financeEntity.Money {
+	if !e.IsRepeatable {
+		return 0
+	}
+	rule, err := e.Recurrence()
+	if err != nil {
+		return 0
 	}
+	return e.Amount.Mul(rule.TimesPerYear(e.StartDate) / 12)
 }
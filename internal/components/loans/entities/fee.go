@@ -0,0 +1,24 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+type Fee struct {
+	ID            uint                `gorm:"primary_key" json:"id"`
+	CreatedAt     time.Time           `json:"-"`
+	UpdatedAt     time.Time           `json:"-"`
+	PaymentPlan   PaymentPlan         `json:"-"`
+	PaymentPlanID uint                `json:"paymentPlanId"`
+	Title         string              `json:"title"`
+	FeeType       FeeType             `json:"feeType"`     //разовая комиссия при выдаче или платеж вместе с каждым взносом
+	IsInsurance   bool                `json:"isInsurance"` //страховка, а не комиссия банка
+	Amount        financeEntity.Money `json:"amount"`
+	Percent       float64             `json:"percent"` //процент от суммы кредита, добавляется к Amount
+}
+
+// Charge returns the fee amount for a loan of the given size
+func (f Fee) Charge(loanAmount financeEntity.Money) financeEntity.Money {
+	return f.Amount + loanAmount.Mul(f.Percent/100)
+}
@@ -0,0 +1,18 @@
+package entities
+
+const (
+	OneOff    FeeType = 0
+	Recurring FeeType = 1
+)
+
+type FeeType int
+
+func (feeType FeeType) String() string {
+	names := [...]string{
+		"OneOff",
+		"Recurring"}
+	if feeType < OneOff || feeType > Recurring {
+		return "Unknown"
+	}
+	return names[feeType]
+}
@@ -0,0 +1,32 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+// Forecast projects the balance of a user over the agenda. Amounts are in the base currency of the user.
+type Forecast struct {
+	CurrencyID     uint                `json:"currencyId"`
+	From           time.Time           `json:"from"`
+	To             time.Time           `json:"to"`
+	Interval       TimePeriod          `json:"interval"` //Day или Month
+	StartBalance   financeEntity.Money `json:"startBalance"`
+	EndBalance     financeEntity.Money `json:"endBalance"`
+	MinBalance     financeEntity.Money `json:"minBalance"`
+	MinBalanceDate time.Time           `json:"minBalanceDate"`
+	NegativeDates  []time.Time         `json:"negativeDates"` //даты, когда баланс уходит в минус
+	Points         []ForecastPoint     `json:"points"`
+	Unconverted    int                 `json:"unconverted"` //сколько элементов не учтено из-за отсутствия курса
+}
+
+// ForecastPoint is the projected balance at the end of a day or a month
+type ForecastPoint struct {
+	Date       time.Time           `json:"date"` //начало дня или месяца
+	Incomes    financeEntity.Money `json:"incomes"`
+	Expenses   financeEntity.Money `json:"expenses"`
+	Payments   financeEntity.Money `json:"payments"`
+	Balance    financeEntity.Money `json:"balance"`
+	MinBalance financeEntity.Money `json:"minBalance"` //наименьший баланс внутри периода
+	IsNegative bool                `json:"isNegative"`
+}
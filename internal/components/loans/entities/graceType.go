@@ -0,0 +1,18 @@
+package entities
+
+const (
+	InterestOnly GraceType = 0
+	Deferred     GraceType = 1
+)
+
+type GraceType int
+
+func (graceType GraceType) String() string {
+	names := [...]string{
+		"InterestOnly",
+		"Deferred"}
+	if graceType < InterestOnly || graceType > Deferred {
+		return "Unknown"
+	}
+	return names[graceType]
+}
@@ -0,0 +1,26 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+// ImportedTransaction is an operation of a bank statement shown as the income or expense it is imported as
+type ImportedTransaction struct {
+	ElementType string              `json:"elementType"` //Income или Expense
+	Reason      string              `json:"reason"`
+	Amount      financeEntity.Money `json:"amount"`
+	CurrencyID  uint                `json:"currencyId"`
+	CategoryID  uint                `json:"categoryId"`
+	Date        time.Time           `json:"date"`
+	ExternalID  string              `json:"externalId"`            //идентификатор операции в выписке
+	Duplicate   bool                `json:"duplicate"`             //операция уже есть у пользователя и не импортируется
+	DuplicateOf uint                `json:"duplicateOf,omitempty"` //id совпавшего дохода или расхода
+}
+
+// ImportResult lists the incomes and expenses an import created
+type ImportResult struct {
+	Incomes  []Income  `json:"incomes"`
+	Expenses []Expense `json:"expenses"`
+	Skipped  int       `json:"skipped"` //пропущенные повторы
+}
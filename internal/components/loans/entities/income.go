@@ -1,20 +1,31 @@
 package entities
 
-import "time"
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
 
 type Income struct {
-	ID             uint       `gorm:"primary_key" json:"id"`
-	CreatedAt      time.Time  `json:"-"`
-	UpdatedAt      time.Time  `json:"-"`
-	User           User       `json:"user"`
-	UserID         uint       `json:"userId"`
-	Reason         string     `json:"reason"`
-	Amount         float64    `json:"amount"`
-	StartDate      time.Time  `json:"startDate"`
-	IsRepeatable   bool       `json:"isRepeatable"` //рекуррентный платеж или нет
-	Frequency      int        `json:"frequency"`
-	PaymentPeriod  TimePeriod `json:"paymentPeriod"`
-	RecurrentCount uint       `json:"recurrentCount"` //число повторений в TimePeriod(4 недели, 12 лет)
+	ID             uint                   `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time              `json:"-"`
+	UpdatedAt      time.Time              `json:"-"`
+	User           User                   `json:"user"`
+	UserID         uint                   `json:"userId"`
+	Reason         string                 `json:"reason"`
+	Amount         financeEntity.Money    `json:"amount"`
+	Currency       financeEntity.Currency `json:"-" gorm:"save_associations:false"`
+	CurrencyID     uint                   `json:"currencyId"`
+	Category       Category               `json:"-" gorm:"save_associations:false"`
+	CategoryID     uint                   `json:"categoryId"`
+	StartDate      time.Time              `json:"startDate"`
+	IsRepeatable   bool                   `json:"isRepeatable"` //рекуррентный платеж или нет
+	Frequency      int                    `json:"frequency"`
+	PaymentPeriod  TimePeriod             `json:"paymentPeriod"`
+	RecurrentCount uint                   `json:"recurrentCount"`          //число повторений, 0 - без ограничения
+	EndDate        time.Time              `json:"endDate"`                 //последняя возможная дата повторения, пустая - без ограничения
+	RRule          string                 `json:"rrule"`                   //правило повторения RFC 5545, заменяет frequency и paymentPeriod
+	ExDates        string                 `json:"exDates"`                 //даты-исключения через запятую, например 2019-01-15,2019-02-15
+	ExternalID     string                 `json:"externalId" gorm:"index"` //идентификатор операции из банковской выписки
 }
 
 func (i Income) TransformSingle() AgendaElement {
@@ -25,46 +36,47 @@ func (i Income) TransformSingle() AgendaElement {
 		i.Reason,
 		i.Amount,
 		i.StartDate,
+		false,
+		i.CurrencyID,
+		0,
+		0,
+		i.CategoryID,
+		false,
 	}
 	return singleElement
 }
 
 func (i Income) TransformWithPeriod(from time.Time, to time.Time) []AgendaElement {
-	if !i.IsRepeatable {
-		return []AgendaElement{i.TransformSingle()}
+	rule, err := i.Recurrence()
+	if !i.IsRepeatable || err != nil {
+		if i.StartDate.After(from) && i.StartDate.Before(to) {
+			return []AgendaElement{i.TransformSingle()}
+		}
+		return []AgendaElement{}
 	}
+	exceptions, _ := ParseExceptionDates(i.ExDates)
 	var elements []AgendaElement
-	currentIncomeTime := i.StartDate
-	for currentIncomeTime.Before(to) {
-		if currentIncomeTime.After(from) {
-			element := AgendaElement{
-				"Income",
-				i.ID,
-				i.UserID,
-				i.Reason,
-				i.Amount,
-				currentIncomeTime,
-			}
-			elements = append(elements, element)
-		}
-		currentIncomeTime = i.getNextPeriod(currentIncomeTime)
+	for _, date := range rule.Between(i.StartDate, from, to, exceptions) {
+		element := i.TransformSingle()
+		element.PaymentDate = date
+		elements = append(elements, element)
 	}
 	return elements
 }
 
-func (i Income) getNextPeriod(currentTime time.Time) time.Time {
-	switch i.PaymentPeriod {
-	case Day:
-		return currentTime.AddDate(0, 0, i.Frequency)
-	case Week:
-		return currentTime.AddDate(0, 0, 7*i.Frequency)
-	case Month:
-		return currentTime.AddDate(0, i.Frequency, 0)
-	case Quarter:
-		return currentTime.AddDate(0, 3*i.Frequency, 0)
-	case Year:
-		return currentTime.AddDate(i.Frequency, 0, 0)
-	default:
-		return currentTime
+// Recurrence returns the rule the income repeats by
+func (i Income) Recurrence() (RecurrenceRule, error) {
+	return recurrenceOf(i.RRule, i.Frequency, i.PaymentPeriod, i.RecurrentCount, i.EndDate)
+}
+
+// MonthlyAmount returns the average amount per month of a repeating income, zero for a single one
+func (i Income) MonthlyAmount() financeEntity.Money {
+	if !i.IsRepeatable {
+		return 0
+	}
+	rule, err := i.Recurrence()
+	if err != nil {
+		return 0
 	}
+	return i.Amount.Mul(rule.TimesPerYear(i.StartDate) / 12)
 }
@@ -1,20 +1,46 @@
 package entities
 
 import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
 	"time"
 )
 
 type Payment struct {
-	ID            uint        `gorm:"primary_key" json:"id"`
-	CreatedAt     time.Time   `json:"-"`
-	UpdatedAt     time.Time   `json:"-"`
-	PaymentPlan   PaymentPlan `json:"-"`
-	PaymentPlanID uint        `json:"paymentPlanId"`
-	PaymentDate   time.Time   `json:"paymentDate"`
-	PaymentAmount float64     `json:"paymentAmount"`
+	ID               uint                `gorm:"primary_key" json:"id"`
+	CreatedAt        time.Time           `json:"-"`
+	UpdatedAt        time.Time           `json:"-"`
+	PaymentPlan      PaymentPlan         `json:"-"`
+	PaymentPlanID    uint                `json:"paymentPlanId"`
+	PaymentDate      time.Time           `json:"paymentDate"`
+	PaymentAmount    financeEntity.Money `json:"paymentAmount"`
+	Principal        financeEntity.Money `json:"principal"`        //погашение основного долга, при капитализации процентов отрицательное
+	Interest         financeEntity.Money `json:"interest"`         //проценты за период
+	RemainingBalance financeEntity.Money `json:"remainingBalance"` //остаток долга после платежа
+	Status           PaymentStatus       `json:"status"`
+	PaidDate         time.Time           `json:"paidDate"`   //когда платеж внесен на самом деле
+	PaidAmount       financeEntity.Money `json:"paidAmount"` //сколько внесено на самом деле
 }
 
-func (p Payment) Transform() AgendaElement {
+// IsSettled tells whether nothing is left to pay for the payment
+func (p Payment) IsSettled() bool {
+	return p.Status == Paid || p.PaymentAmount == 0
+}
+
+// OverdueAmount returns the part of the payment that was not paid on time
+func (p Payment) OverdueAmount() financeEntity.Money {
+	if p.Status == PartiallyPaid {
+		return p.PaymentAmount - p.PaidAmount
+	}
+	return p.PaymentAmount
+}
+
+// IsOverdue tells whether the payment was due before date and has not been paid in full
+func (p Payment) IsOverdue(date time.Time) bool {
+	return !p.IsSettled() && p.PaymentDate.Before(date)
+}
+
+// Transform shows the payment in the agenda, overdue if it is not settled by asOf
+func (p Payment) Transform(asOf time.Time) AgendaElement {
 	return AgendaElement{
 		"Payment",
 		p.ID,
@@ -22,12 +48,19 @@ func (p Payment) Transform() AgendaElement {
 		p.PaymentPlan.Title,
 		p.PaymentAmount,
 		p.PaymentDate,
+		p.IsOverdue(asOf),
+		p.PaymentPlan.CurrencyID,
+		0,
+		p.PaymentPlanID,
+		0,
+		false,
 	}
 }
 
-func (p Payment) TransformWithPeriod(from time.Time, to time.Time) []AgendaElement {
-	if p.PaymentDate.After(from) && p.PaymentDate.Before(to) {
-		return []AgendaElement{p.Transform()}
+// TransformWithPeriod shows the payment if it is due between from and to, as Income and Expense do
+func (p Payment) TransformWithPeriod(from time.Time, to time.Time, asOf time.Time) []AgendaElement {
+	if p.PaymentAmount != 0 && p.PaymentDate.Before(to) && p.PaymentDate.After(from) {
+		return []AgendaElement{p.Transform(asOf)}
 	} else {
 		return []AgendaElement{}
 	}
@@ -6,21 +6,142 @@ import (
 )
 
 type PaymentPlan struct {
-	ID                 uint                   `gorm:"primary_key" json:"id"`
-	CreatedAt          time.Time              `json:"-"`
-	UpdatedAt          time.Time              `json:"-"`
-	Title              string                 `json:"title"`
-	User               User                   `json:"-"`
-	UserID             uint                   `json:"userId"`
-	Bank               financeEntity.Bank     `json:"-"`
-	BankID             uint                   `json:"bankId"`
-	Currency           financeEntity.Currency `json:"-"`
-	CurrencyID         uint                   `json:"currencyId"`
-	PaymentType        PaymentType            `json:"paymentType"`
-	Amount             float64                `json:"paymentAmount"`
-	InterestRate       float64                `json:"interestRate"`
-	Months             uint                   `json:"numberOfMonths"`
-	StartDate          time.Time              `json:"startDate"`
-	Payments           []Payment              `json:"paymentList"`
-	TotalPaymentAmount float64                `json:"totalPaymentAmount"`
+	ID                 uint                    `gorm:"primary_key" json:"id"`
+	CreatedAt          time.Time               `json:"-"`
+	UpdatedAt          time.Time               `json:"-"`
+	Title              string                  `json:"title"`
+	User               User                    `json:"-"`
+	UserID             uint                    `json:"userId"`
+	Bank               financeEntity.Bank      `json:"-"`
+	BankID             uint                    `json:"bankId"`
+	Currency           financeEntity.Currency  `json:"-" gorm:"save_associations:false"`
+	CurrencyID         uint                    `json:"currencyId"`
+	PaymentType        PaymentType             `json:"paymentType"`
+	Amount             financeEntity.Money     `json:"paymentAmount"`
+	InterestRate       float64                 `json:"interestRate"`
+	RateChanges        []RateChange            `json:"rateChanges"`
+	RateIndex          financeEntity.RateIndex `json:"-" gorm:"save_associations:false"`
+	RateIndexID        uint                    `json:"rateIndexId"` //плавающая ставка: значение индекса + RateMargin
+	RateMargin         float64                 `json:"rateMargin"`
+	Months             uint                    `json:"numberOfMonths"`
+	GraceMonths        uint                    `json:"graceMonths"` //первые месяцы срока без погашения основного долга
+	GraceType          GraceType               `json:"graceType"`
+	PaymentPeriod      TimePeriod              `json:"paymentPeriod"`
+	Frequency          int                     `json:"frequency"` //платеж раз в Frequency периодов PaymentPeriod, 0 - раз в PaymentPeriod, без периода - ежемесячно
+	DayCount           DayCountConvention      `json:"dayCount"`  //как начисляются проценты за период
+	StartDate          time.Time               `json:"startDate"`
+	Payments           []Payment               `json:"paymentList"`
+	Prepayments        []Prepayment            `json:"prepayments"`
+	Fees               []Fee                   `json:"fees"`
+	LateFee            financeEntity.Money     `json:"lateFee"`     //штраф за каждый просроченный платеж
+	PenaltyRate        float64                 `json:"penaltyRate"` //пени на просроченную сумму, % годовых
+	Penalties          []Penalty               `json:"penalties"`
+	TotalPaymentAmount financeEntity.Money     `json:"totalPaymentAmount"`
+	InterestPaid       financeEntity.Money     `json:"interestPaid" gorm:"-"`
+	InterestRemaining  financeEntity.Money     `json:"interestRemaining" gorm:"-"`
+	EffectiveRate      float64                 `json:"effectiveRate" gorm:"-"` //полная стоимость кредита с учетом комиссий, % годовых
+	Status             PlanStatus              `json:"status" gorm:"-"`
+	Valuation          *Valuation              `json:"valuation,omitempty" gorm:"-"`
+}
+
+// WithInterestTotals splits the interest of the schedule into the part due before date and the rest
+func (p PaymentPlan) WithInterestTotals(date time.Time) PaymentPlan {
+	p.InterestPaid = 0
+	p.InterestRemaining = 0
+	for _, payment := range p.Payments {
+		if payment.PaymentDate.After(date) {
+			p.InterestRemaining += payment.Interest
+		} else {
+			p.InterestPaid += payment.Interest
+		}
+	}
+	return p
+}
+
+// WithStatus derives the status of the plan on the given date from the status of its payments
+func (p PaymentPlan) WithStatus(date time.Time) PaymentPlan {
+	var unsettled, overdue int
+	for _, payment := range p.Payments {
+		if !payment.IsSettled() {
+			unsettled++
+		}
+		if payment.IsOverdue(date) {
+			overdue++
+		}
+	}
+	p.Status = StatusOf(len(p.Payments), unsettled, overdue)
+	return p
+}
+
+// RateAt returns the annual interest rate in effect on the given date. Index-linked plans
+// fall back to InterestRate until the first index value is published.
+func (p PaymentPlan) RateAt(date time.Time) float64 {
+	if p.RateIndexID != 0 {
+		if value, ok := p.RateIndex.ValueAt(date); ok {
+			return value + p.RateMargin
+		}
+		return p.InterestRate
+	}
+	rate := p.InterestRate
+	var changeDate time.Time
+	for _, change := range p.RateChanges {
+		if !change.ChangeDate.After(date) && !change.ChangeDate.Before(changeDate) {
+			rate = change.InterestRate
+			changeDate = change.ChangeDate
+		}
+	}
+	return rate
+}
+
+// DueDate returns the date the n-th installment of the plan is due, the 0-th being the start date.
+// Installments paid in months keep the day of the start date, falling on the last day of shorter months.
+func (p PaymentPlan) DueDate(n uint) time.Time {
+	return p.AddPeriods(p.StartDate, int(n))
+}
+
+// AddPeriods returns the date n payment periods of the plan after date, or before it for a negative n
+func (p PaymentPlan) AddPeriods(date time.Time, n int) time.Time {
+	period, frequency := p.schedulePeriod()
+	periods := n * frequency
+	switch period {
+	case Day:
+		return date.AddDate(0, 0, periods)
+	case Week:
+		return date.AddDate(0, 0, 7*periods)
+	case Quarter:
+		return addMonths(date, 3*periods)
+	case Year:
+		return addMonths(date, 12*periods)
+	default:
+		return addMonths(date, periods)
+	}
+}
+
+// PeriodsPerYear returns how many installments of the plan fall on a year
+func (p PaymentPlan) PeriodsPerYear() float64 {
+	period, frequency := p.schedulePeriod()
+	return period.TimesPerYear(frequency)
+}
+
+// schedulePeriod returns the period and the frequency the installments of the plan are paid with.
+// A payment period given without a frequency is paid once per period. Day is the zero value of the
+// period, so a plan with neither is paid monthly and daily installments need a frequency.
+func (p PaymentPlan) schedulePeriod() (TimePeriod, int) {
+	if p.Frequency > 0 {
+		return p.PaymentPeriod, p.Frequency
+	}
+	if p.PaymentPeriod == Day {
+		return Month, 1
+	}
+	return p.PaymentPeriod, 1
+}
+
+func addMonths(date time.Time, months int) time.Time {
+	firstDay := time.Date(date.Year(), date.Month()+time.Month(months), 1,
+		date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), date.Location())
+	day := date.Day()
+	if lastDay := firstDay.AddDate(0, 1, -1).Day(); day > lastDay {
+		day = lastDay
+	}
+	return firstDay.AddDate(0, 0, day-1)
 }
@@ -0,0 +1,154 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"testing"
+	"time"
+)
+
+func TestDueDate(t *testing.T) {
+	start := time.Date(2020, time.January, 31, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		period    TimePeriod
+		frequency int
+		n         uint
+		want      time.Time
+	}{
+		{Day, 0, 1, time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{Day, 0, 2, time.Date(2020, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{Month, 0, 3, time.Date(2020, time.April, 30, 0, 0, 0, 0, time.UTC)},
+		{Month, 2, 1, time.Date(2020, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{Week, 0, 1, time.Date(2020, time.February, 7, 0, 0, 0, 0, time.UTC)},
+		{Week, 2, 2, time.Date(2020, time.February, 28, 0, 0, 0, 0, time.UTC)},
+		{Day, 10, 3, time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{Quarter, 0, 1, time.Date(2020, time.April, 30, 0, 0, 0, 0, time.UTC)},
+		{Quarter, 1, 4, time.Date(2021, time.January, 31, 0, 0, 0, 0, time.UTC)},
+		{Year, 0, 1, time.Date(2021, time.January, 31, 0, 0, 0, 0, time.UTC)},
+		{Month, 0, 0, start},
+	} {
+		paymentPlan := PaymentPlan{StartDate: start, PaymentPeriod: test.period, Frequency: test.frequency}
+		if got := paymentPlan.DueDate(test.n); !got.Equal(test.want) {
+			t.Errorf("DueDate(%d) every %d %v = %v, want %v", test.n, test.frequency, test.period, got, test.want)
+		}
+	}
+}
+
+func TestAddPeriodsBack(t *testing.T) {
+	date := time.Date(2020, time.March, 31, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		period    TimePeriod
+		frequency int
+		want      time.Time
+	}{
+		{Month, 0, time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{Month, 3, time.Date(2019, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{Week, 0, time.Date(2020, time.March, 24, 0, 0, 0, 0, time.UTC)},
+		{Week, 2, time.Date(2020, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{Quarter, 0, time.Date(2019, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{Year, 0, time.Date(2019, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{Day, 10, time.Date(2020, time.March, 21, 0, 0, 0, 0, time.UTC)},
+	} {
+		paymentPlan := PaymentPlan{PaymentPeriod: test.period, Frequency: test.frequency}
+		if got := paymentPlan.AddPeriods(date, -1); !got.Equal(test.want) {
+			t.Errorf("a period of %d %v before %v = %v, want %v", test.frequency, test.period, date, got, test.want)
+		}
+	}
+}
+
+func TestPeriodsPerYear(t *testing.T) {
+	for _, test := range []struct {
+		period    TimePeriod
+		frequency int
+		want      float64
+	}{
+		{Day, 0, 12},
+		{Month, 0, 12},
+		{Month, 3, 4},
+		{Week, 0, 52},
+		{Week, 2, 26},
+		{Day, 1, 365},
+		{Quarter, 0, 4},
+		{Year, 0, 1},
+		{Year, 2, 0.5},
+	} {
+		paymentPlan := PaymentPlan{PaymentPeriod: test.period, Frequency: test.frequency}
+		if got := paymentPlan.PeriodsPerYear(); got != test.want {
+			t.Errorf("PeriodsPerYear every %d %v = %v, want %v", test.frequency, test.period, got, test.want)
+		}
+	}
+}
+
+func TestRateAt(t *testing.T) {
+	start := time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC)
+	march, june := start.AddDate(0, 2, 0), start.AddDate(0, 5, 0)
+	scheduled := PaymentPlan{StartDate: start, InterestRate: 10, RateChanges: []RateChange{
+		{ChangeDate: june, InterestRate: 14},
+		{ChangeDate: march, InterestRate: 12},
+	}}
+	indexed := PaymentPlan{StartDate: start, InterestRate: 10, RateIndexID: 1, RateMargin: 1.5,
+		RateIndex: financeEntity.RateIndex{ID: 1, Values: []financeEntity.RateIndexValue{
+			{ValueDate: june, Value: 7},
+			{ValueDate: march, Value: 6},
+		}}}
+	for _, test := range []struct {
+		paymentPlan PaymentPlan
+		date        time.Time
+		want        float64
+	}{
+		{scheduled, start, 10},
+		{scheduled, march.AddDate(0, 0, -1), 10},
+		{scheduled, march, 12},
+		{scheduled, june.AddDate(0, 0, -1), 12},
+		{scheduled, june.AddDate(1, 0, 0), 14},
+		{indexed, start, 10},
+		{indexed, march, 7.5},
+		{indexed, june, 8.5},
+	} {
+		if got := test.paymentPlan.RateAt(test.date); got != test.want {
+			t.Errorf("rate on %v = %v, want %v", test.date, got, test.want)
+		}
+	}
+}
+
+func TestTimesPerYear(t *testing.T) {
+	for _, test := range []struct {
+		period    TimePeriod
+		frequency int
+		want      float64
+	}{
+		{Day, 7, 365.0 / 7},
+		{Week, 1, 52},
+		{Month, 1, 12},
+		{Month, 6, 2},
+		{Quarter, 2, 2},
+		{Year, 1, 1},
+		{Month, 0, 0},
+		{Week, -1, 0},
+	} {
+		if got := test.period.TimesPerYear(test.frequency); got != test.want {
+			t.Errorf("%v.TimesPerYear(%d) = %v, want %v", test.period, test.frequency, got, test.want)
+		}
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	date := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	paid := Payment{PaymentDate: date.AddDate(0, -1, 0), PaymentAmount: 100, Status: Paid}
+	missed := Payment{PaymentDate: date.AddDate(0, -1, 0), PaymentAmount: 100, Status: Missed}
+	scheduled := Payment{PaymentDate: date.AddDate(0, 1, 0), PaymentAmount: 100}
+	empty := Payment{PaymentDate: date.AddDate(0, 1, 0)}
+	for _, test := range []struct {
+		payments []Payment
+		want     PlanStatus
+	}{
+		{nil, OnTrack},
+		{[]Payment{paid, scheduled}, OnTrack},
+		{[]Payment{paid, missed, scheduled}, Overdue},
+		{[]Payment{paid, empty}, Closed},
+	} {
+		paymentPlan := PaymentPlan{Payments: test.payments}.WithStatus(date)
+		if paymentPlan.Status != test.want {
+			t.Errorf("status of %+v = %v, want %v", test.payments, paymentPlan.Status, test.want)
+		}
+	}
+}
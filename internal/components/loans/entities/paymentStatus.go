@@ -0,0 +1,22 @@
+package entities
+
+const (
+	Scheduled     PaymentStatus = 0
+	Paid          PaymentStatus = 1
+	PartiallyPaid PaymentStatus = 2
+	Missed        PaymentStatus = 3
+)
+
+type PaymentStatus int
+
+func (status PaymentStatus) String() string {
+	names := [...]string{
+		"Scheduled",
+		"Paid",
+		"PartiallyPaid",
+		"Missed"}
+	if status < Scheduled || status > Missed {
+		return "Unknown"
+	}
+	return names[status]
+}
@@ -0,0 +1,33 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+type PayoffRequest struct {
+	ExtraPayment financeEntity.Money `json:"extraPayment"` //сколько пользователь готов платить сверх графиков каждый месяц, в базовой валюте
+	Order        []uint              `json:"order"`        //порядок погашения планов для стратегии Custom
+}
+
+type PayoffPlan struct {
+	Strategy      PayoffStrategy      `json:"strategy"`
+	CurrencyID    uint                `json:"currencyId"` //базовая валюта, в ней TotalInterest; платежи - в валютах планов
+	Order         []uint              `json:"order"`
+	DebtFreeDate  time.Time           `json:"debtFreeDate"`
+	TotalInterest financeEntity.Money `json:"totalInterest"`
+	Months        []PayoffMonth       `json:"months"`
+}
+
+type PayoffMonth struct {
+	Date     time.Time       `json:"date"`
+	Payments []PayoffPayment `json:"payments"`
+}
+
+type PayoffPayment struct {
+	PaymentPlanID    uint                `json:"paymentPlanId"`
+	PaymentAmount    financeEntity.Money `json:"paymentAmount"`
+	Extra            financeEntity.Money `json:"extra"` //часть платежа сверх графика
+	Interest         financeEntity.Money `json:"interest"`
+	RemainingBalance financeEntity.Money `json:"remainingBalance"`
+}
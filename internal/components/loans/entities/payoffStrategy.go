@@ -0,0 +1,20 @@
+package entities
+
+const (
+	Avalanche PayoffStrategy = 0
+	Snowball  PayoffStrategy = 1
+	Custom    PayoffStrategy = 2
+)
+
+type PayoffStrategy int
+
+func (strategy PayoffStrategy) String() string {
+	names := [...]string{
+		"Avalanche",
+		"Snowball",
+		"Custom"}
+	if strategy < Avalanche || strategy > Custom {
+		return "Unknown"
+	}
+	return names[strategy]
+}
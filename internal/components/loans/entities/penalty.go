@@ -0,0 +1,18 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+type Penalty struct {
+	ID            uint                `gorm:"primary_key" json:"id"`
+	CreatedAt     time.Time           `json:"-"`
+	UpdatedAt     time.Time           `json:"-"`
+	PaymentPlanID uint                `json:"paymentPlanId"`
+	PaymentID     uint                `json:"paymentId" gorm:"unique_index:idx_penalty_accrual"`
+	PenaltyType   PenaltyType         `json:"penaltyType" gorm:"unique_index:idx_penalty_accrual"`
+	AccruedFrom   time.Time           `json:"accruedFrom"`
+	AccruedTo     time.Time           `json:"accruedTo" gorm:"unique_index:idx_penalty_accrual"` //по какой день начислено, повторный запуск продолжает с этой даты
+	Amount        financeEntity.Money `json:"amount"`
+}
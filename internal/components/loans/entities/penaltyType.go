@@ -0,0 +1,18 @@
+package entities
+
+const (
+	LateFee         PenaltyType = 0
+	PenaltyInterest PenaltyType = 1
+)
+
+type PenaltyType int
+
+func (penaltyType PenaltyType) String() string {
+	names := [...]string{
+		"LateFee",
+		"PenaltyInterest"}
+	if penaltyType < LateFee || penaltyType > PenaltyInterest {
+		return "Unknown"
+	}
+	return names[penaltyType]
+}
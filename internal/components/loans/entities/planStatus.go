@@ -0,0 +1,32 @@
+package entities
+
+const (
+	OnTrack PlanStatus = 0
+	Overdue PlanStatus = 1
+	Closed  PlanStatus = 2
+)
+
+type PlanStatus int
+
+func (status PlanStatus) String() string {
+	names := [...]string{
+		"OnTrack",
+		"Overdue",
+		"Closed"}
+	if status < OnTrack || status > Closed {
+		return "Unknown"
+	}
+	return names[status]
+}
+
+// StatusOf derives the status of a plan from the number of its payments, of those not settled
+// and of those overdue
+func StatusOf(payments int, unsettled int, overdue int) PlanStatus {
+	switch {
+	case overdue > 0:
+		return Overdue
+	case payments > 0 && unsettled == 0:
+		return Closed
+	}
+	return OnTrack
+}
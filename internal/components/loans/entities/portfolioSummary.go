@@ -0,0 +1,31 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+// PortfolioSummary is the financial position of a user on AsOf. Totals are in CurrencyID, the base currency
+// of the user or, without one, the single currency of their records.
+type PortfolioSummary struct {
+	CurrencyID          uint                `json:"currencyId"`
+	AsOf                time.Time           `json:"asOf"`
+	Outstanding         financeEntity.Money `json:"outstanding"` //остаток основного долга по всем планам
+	Breakdown           []PortfolioShare    `json:"breakdown"`
+	NextPayments        []AgendaElement     `json:"nextPayments"`       //ближайший неоплаченный платеж по каждому плану
+	MonthlyDebtService  financeEntity.Money `json:"monthlyDebtService"` //платежи по кредитам за месяц после AsOf
+	AverageRate         float64             `json:"averageRate"`        //средняя ставка, взвешенная по остатку долга
+	MaxRate             float64             `json:"maxRate"`
+	MonthlyIncome       financeEntity.Money `json:"monthlyIncome"`       //регулярные доходы в среднем за месяц
+	MonthlyExpenses     financeEntity.Money `json:"monthlyExpenses"`     //регулярные расходы в среднем за месяц
+	DebtToIncome        float64             `json:"debtToIncome"`        //MonthlyDebtService / MonthlyIncome
+	ObligationsToIncome float64             `json:"obligationsToIncome"` //(MonthlyDebtService + MonthlyExpenses) / MonthlyIncome
+}
+
+// PortfolioShare is the outstanding principal owed to a bank in a currency
+type PortfolioShare struct {
+	BankID      uint                `json:"bankId"`
+	CurrencyID  uint                `json:"currencyId"`
+	Balance     financeEntity.Money `json:"balance"`
+	BaseBalance financeEntity.Money `json:"baseBalance"`
+}
@@ -0,0 +1,18 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+type Prepayment struct {
+	ID             uint                `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time           `json:"-"`
+	UpdatedAt      time.Time           `json:"-"`
+	PaymentPlan    PaymentPlan         `json:"-"`
+	PaymentPlanID  uint                `json:"paymentPlanId"`
+	PrepaymentDate time.Time           `json:"prepaymentDate"`
+	Amount         financeEntity.Money `json:"amount"`
+	Strategy       PrepaymentStrategy  `json:"strategy"`
+	InterestSaved  financeEntity.Money `json:"interestSaved"` //сколько процентов сэкономлено по сравнению с графиком до досрочного погашения
+}
@@ -0,0 +1,18 @@
+package entities
+
+const (
+	ReduceTerm    PrepaymentStrategy = 0
+	ReducePayment PrepaymentStrategy = 1
+)
+
+type PrepaymentStrategy int
+
+func (strategy PrepaymentStrategy) String() string {
+	names := [...]string{
+		"ReduceTerm",
+		"ReducePayment"}
+	if strategy < ReduceTerm || strategy > ReducePayment {
+		return "Unknown"
+	}
+	return names[strategy]
+}
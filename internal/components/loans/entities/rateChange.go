@@ -0,0 +1,13 @@
+package entities
+
+import "time"
+
+type RateChange struct {
+	ID            uint        `gorm:"primary_key" json:"id"`
+	CreatedAt     time.Time   `json:"-"`
+	UpdatedAt     time.Time   `json:"-"`
+	PaymentPlan   PaymentPlan `json:"-"`
+	PaymentPlanID uint        `json:"paymentPlanId"`
+	ChangeDate    time.Time   `json:"changeDate"`
+	InterestRate  float64     `json:"interestRate"` //новая ставка, действует для периодов, начинающихся с ChangeDate
+}
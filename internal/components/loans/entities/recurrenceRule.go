@@ -0,0 +1,524 @@
+package entities
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecurrenceRule is a subset of the RRULE of RFC 5545 enough for incomes and expenses: FREQ of DAILY, WEEKLY,
+// MONTHLY or YEARLY with INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and BYSETPOS. Weeks start on Monday
+// and ordinals of BYDAY always count within a month.
+// For example "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1" is the last business day of a month,
+// "FREQ=MONTHLY;BYMONTHDAY=15,-1" the 15th and the last day and "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR" every
+// second Friday.
+type RecurrenceRule struct {
+	Frequency  TimePeriod //Day, Week, Month или Year
+	Interval   int
+	Count      uint      //0 - без ограничения
+	Until      time.Time //включительно, пустая дата - без ограничения
+	ByDay      []RecurrenceDay
+	ByMonthDay []int //отрицательные считаются от конца месяца
+	ByMonth    []time.Month
+	BySetPos   []int
+}
+
+// RecurrenceDay is a weekday, the ordinal one within a month or a year if Ordinal is not zero
+type RecurrenceDay struct {
+	Weekday time.Weekday
+	Ordinal int //2 - второй, -1 - последний
+}
+
+var rruleFrequencies = map[string]TimePeriod{"DAILY": Day, "WEEKLY": Week, "MONTHLY": Month, "YEARLY": Year}
+var rruleWeekdays = map[string]time.Weekday{"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday}
+var recurrenceDateLayouts = []string{"20060102T150405Z", "20060102T150405", "20060102", "2006-01-02", time.RFC3339}
+
+var errBadRecurrenceRule = errors.New("bad recurrence rule")
+
+// ParseRecurrenceRule parses the value of an RRULE property, with or without the "RRULE:" prefix
+func ParseRecurrenceRule(value string) (RecurrenceRule, error) {
+	rule := RecurrenceRule{Interval: 1}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	hasFrequency := false
+	for _, part := range strings.Split(value, ";") {
+		nameValue := strings.SplitN(part, "=", 2)
+		if len(nameValue) != 2 {
+			return rule, errBadRecurrenceRule
+		}
+		name, value := strings.ToUpper(strings.TrimSpace(nameValue[0])), strings.ToUpper(strings.TrimSpace(nameValue[1]))
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Frequency, hasFrequency = rruleFrequencies[value]
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if rule.Interval <= 0 {
+				err = errBadRecurrenceRule
+			}
+		case "COUNT":
+			var count uint64
+			count, err = strconv.ParseUint(value, 10, 32)
+			rule.Count = uint(count)
+		case "UNTIL":
+			rule.Until, err = parseRecurrenceDate(value)
+		case "BYDAY":
+			rule.ByDay, err = parseRecurrenceDays(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseRecurrenceNumbers(value, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseRecurrenceNumbers(value, 12)
+			for _, month := range months {
+				if month < 0 {
+					err = errBadRecurrenceRule
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		case "BYSETPOS":
+			rule.BySetPos, err = parseRecurrenceNumbers(value, 366)
+		case "WKST":
+		default:
+			err = errBadRecurrenceRule
+		}
+		if err != nil {
+			return rule, errBadRecurrenceRule
+		}
+	}
+	if !hasFrequency {
+		return rule, errBadRecurrenceRule
+	}
+	return rule, nil
+}
+
+// ParseExceptionDates parses dates separated by commas
+func ParseExceptionDates(value string) ([]time.Time, error) {
+	var dates []time.Time
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		date, err := parseRecurrenceDate(part)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	return dates, nil
+}
+
+func parseRecurrenceDate(value string) (time.Time, error) {
+	for _, layout := range recurrenceDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, errBadRecurrenceRule
+}
+
+func parseRecurrenceNumbers(value string, limit int) ([]int, error) {
+	var numbers []int
+	for _, part := range strings.Split(value, ",") {
+		number, err := strconv.Atoi(part)
+		if err != nil || number == 0 || number > limit || number < -limit {
+			return nil, errBadRecurrenceRule
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+func parseRecurrenceDays(value string) ([]RecurrenceDay, error) {
+	var days []RecurrenceDay
+	for _, part := range strings.Split(value, ",") {
+		if len(part) < 2 {
+			return nil, errBadRecurrenceRule
+		}
+		weekday, ok := rruleWeekdays[part[len(part)-2:]]
+		if !ok {
+			return nil, errBadRecurrenceRule
+		}
+		day := RecurrenceDay{Weekday: weekday}
+		if ordinal := part[:len(part)-2]; ordinal != "" {
+			var err error
+			day.Ordinal, err = strconv.Atoi(strings.TrimPrefix(ordinal, "+"))
+			if err != nil || day.Ordinal == 0 || day.Ordinal > 53 || day.Ordinal < -53 {
+				return nil, errBadRecurrenceRule
+			}
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// String formats the rule as the value of an RRULE property. UNTIL is written as a date, which suits all-day events.
+// The value keeps the meaning of this type; CalendarRule gives one that calendar clients read the same way.
+func (rule RecurrenceRule) String() string {
+	var frequency string
+	for name, period := range rruleFrequencies {
+		if period == rule.Frequency {
+			frequency = name
+		}
+	}
+	parts := []string{"FREQ=" + frequency}
+	if rule.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(rule.Interval))
+	}
+	if rule.Count != 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(int(rule.Count)))
+	}
+	if !rule.Until.IsZero() {
+		parts = append(parts, "UNTIL="+rule.Until.Format("20060102"))
+	}
+	if len(rule.ByDay) > 0 {
+		var days []string
+		for _, day := range rule.ByDay {
+			for name, weekday := range rruleWeekdays {
+				if weekday == day.Weekday && day.Ordinal != 0 {
+					days = append(days, strconv.Itoa(day.Ordinal)+name)
+				} else if weekday == day.Weekday {
+					days = append(days, name)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(rule.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinNumbers(rule.ByMonthDay))
+	}
+	if len(rule.ByMonth) > 0 {
+		var months []int
+		for _, month := range rule.ByMonth {
+			months = append(months, int(month))
+		}
+		parts = append(parts, "BYMONTH="+joinNumbers(months))
+	}
+	if len(rule.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinNumbers(rule.BySetPos))
+	}
+	return strings.Join(parts, ";")
+}
+
+// CalendarRule returns a rule that calendar clients, reading it by RFC 5545, expand from start into the same
+// occurrences as this one. The RFC skips months without the day of start where this rule moves to their last
+// day, and counts ordinals of BYDAY in a yearly rule without BYMONTH within the year; the returned rule spells
+// those out with BYMONTHDAY, BYSETPOS and BYMONTH. It returns false if the RFC cannot express the rule, such as
+// BYMONTHDAY in a weekly rule or BYDAY ordinals in a daily or weekly one.
+func (rule RecurrenceRule) CalendarRule(start time.Time) (RecurrenceRule, bool) {
+	hasOrdinals := false
+	for _, day := range rule.ByDay {
+		hasOrdinals = hasOrdinals || day.Ordinal != 0
+	}
+	switch rule.Frequency {
+	case Day:
+		return rule, !hasOrdinals
+	case Week:
+		return rule, !hasOrdinals && len(rule.ByMonthDay) == 0
+	}
+	if len(rule.ByDay) > 0 || len(rule.ByMonthDay) > 0 {
+		if rule.Frequency == Year && len(rule.ByMonth) == 0 {
+			for month := time.January; month <= time.December; month++ {
+				rule.ByMonth = append(rule.ByMonth, month)
+			}
+		}
+		return rule, true
+	}
+
+	months := rule.ByMonth
+	if len(months) == 0 && rule.Frequency == Year {
+		months = []time.Month{start.Month()}
+	} else if len(months) == 0 {
+		for month := time.January; month <= time.December; month++ {
+			months = append(months, month)
+		}
+	}
+	day, isShorter, isLast := start.Day(), false, true
+	for _, month := range months {
+		// February of a leap year is the longest one and February of any other year the shortest one
+		longest := time.Date(2020, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		shortest := time.Date(2021, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		isShorter = isShorter || shortest < day
+		isLast = isLast && longest <= day
+	}
+	if !isShorter {
+		return rule, true
+	}
+	if len(rule.BySetPos) > 0 || rule.Frequency == Year && len(months) > 1 && !isLast {
+		// BYSETPOS of a yearly rule picks from the whole year, so it cannot pick the last day of every month
+		return rule, false
+	}
+	if rule.Frequency == Year {
+		rule.ByMonth = months
+	}
+	if isLast {
+		rule.ByMonthDay = []int{-1}
+		return rule, true
+	}
+	// The last of the days from the 28th to the day of start is that day moved to the last day of shorter months
+	for monthDay := 28; monthDay <= day; monthDay++ {
+		rule.ByMonthDay = append(rule.ByMonthDay, monthDay)
+	}
+	rule.BySetPos = []int{-1}
+	return rule, true
+}
+
+func joinNumbers(numbers []int) string {
+	texts := make([]string, len(numbers))
+	for i, number := range numbers {
+		texts[i] = strconv.Itoa(number)
+	}
+	return strings.Join(texts, ",")
+}
+
+// Between returns the occurrences of the rule starting on start that fall after from and before to, leaving out
+// the days of the exceptions. COUNT counts the occurrences before the exceptions are left out, as RFC 5545 does.
+func (rule RecurrenceRule) Between(start time.Time, from time.Time, to time.Time, exceptions []time.Time) []time.Time {
+	var occurrences []time.Time
+	var count uint
+	interval := rule.Interval
+	if interval <= 0 {
+		interval = 1
+	}
+	period := rule.periodStart(start)
+	// Without COUNT the occurrences before from do not matter, so whole intervals before it are skipped
+	if rule.Count == 0 && from.After(start) {
+		period = rule.nextPeriod(period, interval*rule.intervalsBetween(period, rule.periodStart(from), interval))
+	}
+	for ; period.Before(to); period = rule.nextPeriod(period, interval) {
+		for _, occurrence := range rule.periodOccurrences(period, start) {
+			if occurrence.Before(start) {
+				continue
+			}
+			if !occurrence.Before(to) || !rule.Until.IsZero() && occurrence.After(rule.Until) {
+				return occurrences
+			}
+			if count++; rule.Count != 0 && count > rule.Count {
+				return occurrences
+			}
+			if occurrence.After(from) && !isException(occurrence, exceptions) {
+				occurrences = append(occurrences, occurrence)
+			}
+		}
+		if !rule.Until.IsZero() && period.After(rule.Until) {
+			break
+		}
+	}
+	return occurrences
+}
+
+// TimesPerYear returns how many times a year the rule repeats on average, regardless of COUNT and UNTIL
+func (rule RecurrenceRule) TimesPerYear(start time.Time) float64 {
+	const years = 4
+	rule.Count, rule.Until = 0, time.Time{}
+	return float64(len(rule.Between(start, start.Add(-time.Nanosecond), start.AddDate(years, 0, 0), nil))) / years
+}
+
+func (rule RecurrenceRule) periodStart(date time.Time) time.Time {
+	year, month, day := date.Date()
+	switch rule.Frequency {
+	case Week:
+		return time.Date(year, month, day-(int(date.Weekday())+6)%7, 0, 0, 0, 0, date.Location())
+	case Month:
+		return time.Date(year, month, 1, 0, 0, 0, 0, date.Location())
+	case Year:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, date.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+	}
+}
+
+func (rule RecurrenceRule) nextPeriod(period time.Time, interval int) time.Time {
+	switch rule.Frequency {
+	case Week:
+		return period.AddDate(0, 0, 7*interval)
+	case Month:
+		return period.AddDate(0, interval, 0)
+	case Year:
+		return period.AddDate(interval, 0, 0)
+	default:
+		return period.AddDate(0, 0, interval)
+	}
+}
+
+// intervalsBetween returns how many whole intervals of periods fit between the starts of two periods,
+// one less to stay clear of daylight saving shifts
+func (rule RecurrenceRule) intervalsBetween(first time.Time, last time.Time, interval int) int {
+	var periods int
+	switch rule.Frequency {
+	case Month:
+		periods = (last.Year()-first.Year())*12 + int(last.Month()-first.Month())
+	case Year:
+		periods = last.Year() - first.Year()
+	case Week:
+		periods = int(last.Sub(first).Hours()/24) / 7
+	default:
+		periods = int(last.Sub(first).Hours() / 24)
+	}
+	if intervals := periods/interval - 1; intervals > 0 {
+		return intervals
+	}
+	return 0
+}
+
+// periodOccurrences returns the sorted occurrences within the period beginning on period, at the time of day
+// of start. Without BYMONTHDAY and BYDAY a monthly or yearly rule repeats on the day of start, moved to the
+// last day of shorter months.
+func (rule RecurrenceRule) periodOccurrences(period time.Time, start time.Time) []time.Time {
+	var days []time.Time
+	switch rule.Frequency {
+	case Day:
+		days = []time.Time{period}
+	case Week:
+		for i := 0; i < 7; i++ {
+			day := period.AddDate(0, 0, i)
+			if len(rule.ByDay) > 0 || day.Weekday() == start.Weekday() {
+				days = append(days, day)
+			}
+		}
+	case Month:
+		days = rule.monthDays(period, start)
+	case Year:
+		months := rule.ByMonth
+		if len(months) == 0 && len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 {
+			months = []time.Month{start.Month()}
+		} else if len(months) == 0 {
+			for month := time.January; month <= time.December; month++ {
+				months = append(months, month)
+			}
+		}
+		for _, month := range months {
+			days = append(days, rule.monthDays(time.Date(period.Year(), month, 1, 0, 0, 0, 0, period.Location()), start)...)
+		}
+	}
+
+	var occurrences []time.Time
+	for _, day := range days {
+		if rule.matches(day) {
+			hour, minute, second := start.Clock()
+			occurrences = append(occurrences, time.Date(day.Year(), day.Month(), day.Day(), hour, minute, second, start.Nanosecond(), day.Location()))
+		}
+	}
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].Before(occurrences[j]) })
+	if len(rule.BySetPos) == 0 {
+		return occurrences
+	}
+	var selected []time.Time
+	for _, position := range rule.BySetPos {
+		if position > 0 && position <= len(occurrences) {
+			selected = append(selected, occurrences[position-1])
+		} else if position < 0 && -position <= len(occurrences) {
+			selected = append(selected, occurrences[len(occurrences)+position])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+	return selected
+}
+
+// monthDays returns the candidate days of the month beginning on month
+func (rule RecurrenceRule) monthDays(month time.Time, start time.Time) []time.Time {
+	length := month.AddDate(0, 1, -1).Day()
+	var days []time.Time
+	if len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 {
+		day := start.Day()
+		if day > length {
+			day = length
+		}
+		return []time.Time{month.AddDate(0, 0, day-1)}
+	}
+	for day := 1; day <= length; day++ {
+		days = append(days, month.AddDate(0, 0, day-1))
+	}
+	return days
+}
+
+// matches tells whether the day satisfies the BY parts of the rule
+func (rule RecurrenceRule) matches(day time.Time) bool {
+	if len(rule.ByMonth) > 0 && !containsMonth(rule.ByMonth, day.Month()) {
+		return false
+	}
+	length := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	if len(rule.ByMonthDay) > 0 {
+		found := false
+		for _, monthDay := range rule.ByMonthDay {
+			if monthDay == day.Day() || monthDay < 0 && length+monthDay+1 == day.Day() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(rule.ByDay) > 0 {
+		found := false
+		for _, byDay := range rule.ByDay {
+			if byDay.Weekday != day.Weekday() {
+				continue
+			}
+			// Ordinals count weekdays within the month
+			if byDay.Ordinal == 0 || byDay.Ordinal > 0 && (day.Day()-1)/7+1 == byDay.Ordinal ||
+				byDay.Ordinal < 0 && (length-day.Day())/7+1 == -byDay.Ordinal {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func isException(occurrence time.Time, exceptions []time.Time) bool {
+	year, month, day := occurrence.Date()
+	for _, exception := range exceptions {
+		if exceptionYear, exceptionMonth, exceptionDay := exception.Date(); exceptionYear == year && exceptionMonth == month && exceptionDay == day {
+			return true
+		}
+	}
+	return false
+}
+
+// recurrenceOf returns the rule of a repeating income or expense: the RRULE if there is one, otherwise every
+// frequency payment periods. The count and the end date of the record apply unless the RRULE sets its own.
+func recurrenceOf(rrule string, frequency int, period TimePeriod, count uint, endDate time.Time) (RecurrenceRule, error) {
+	rule := RecurrenceRule{Frequency: period, Interval: frequency}
+	if rrule != "" {
+		var err error
+		if rule, err = ParseRecurrenceRule(rrule); err != nil {
+			return rule, err
+		}
+	} else if frequency <= 0 || period < Day || period > Year {
+		return rule, errBadRecurrenceRule
+	} else if period == Quarter {
+		rule = RecurrenceRule{Frequency: Month, Interval: 3 * frequency}
+	}
+	if rule.Count == 0 {
+		rule.Count = count
+	}
+	if rule.Until.IsZero() {
+		rule.Until = endDate
+	}
+	return rule, nil
+}
+
+// ValidateRecurrence checks the rule and the exception dates of a repeating income or expense
+func ValidateRecurrence(isRepeatable bool, rrule string, frequency int, period TimePeriod, exDates string) bool {
+	if !isRepeatable {
+		return true
+	}
+	if _, err := recurrenceOf(rrule, frequency, period, 0, time.Time{}); err != nil {
+		return false
+	}
+	_, err := ParseExceptionDates(exDates)
+	return err == nil
+}
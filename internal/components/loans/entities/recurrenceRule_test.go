@@ -0,0 +1,240 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func recurrenceDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		want  RecurrenceRule
+	}{
+		{"FREQ=DAILY", RecurrenceRule{Frequency: Day, Interval: 1}},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR", RecurrenceRule{Frequency: Week, Interval: 2,
+			ByDay: []RecurrenceDay{{Weekday: time.Friday}}}},
+		{"freq=monthly;byday=mo,tu,we,th,fr;bysetpos=-1", RecurrenceRule{Frequency: Month, Interval: 1,
+			ByDay: []RecurrenceDay{{Weekday: time.Monday}, {Weekday: time.Tuesday}, {Weekday: time.Wednesday},
+				{Weekday: time.Thursday}, {Weekday: time.Friday}}, BySetPos: []int{-1}}},
+		{"FREQ=MONTHLY;BYMONTHDAY=15,-1;COUNT=10", RecurrenceRule{Frequency: Month, Interval: 1, Count: 10, ByMonthDay: []int{15, -1}}},
+		{"FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU;UNTIL=20251231", RecurrenceRule{Frequency: Year, Interval: 1,
+			Until: recurrenceDate(2025, time.December, 31), ByMonth: []time.Month{time.March},
+			ByDay: []RecurrenceDay{{Weekday: time.Sunday, Ordinal: -1}}}},
+		{"FREQ=MONTHLY;BYDAY=+2MO;UNTIL=20201231T235959Z;WKST=MO", RecurrenceRule{Frequency: Month, Interval: 1,
+			Until: time.Date(2020, time.December, 31, 23, 59, 59, 0, time.UTC), ByDay: []RecurrenceDay{{Weekday: time.Monday, Ordinal: 2}}}},
+	} {
+		got, err := ParseRecurrenceRule(test.value)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseRecurrenceRule(%q) = %+v, %v, want %+v", test.value, got, err, test.want)
+		}
+	}
+	for _, value := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=54MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=YEARLY;BYMONTH=-1",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;COUNT",
+	} {
+		if rule, err := ParseRecurrenceRule(value); err == nil {
+			t.Errorf("ParseRecurrenceRule(%q) = %+v, want an error", value, rule)
+		}
+	}
+}
+
+func TestRecurrenceRuleString(t *testing.T) {
+	for _, value := range []string{
+		"FREQ=DAILY",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=FR",
+		"FREQ=MONTHLY;COUNT=12;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+		"FREQ=MONTHLY;UNTIL=20251231;BYMONTHDAY=15,-1",
+		"FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3,10",
+		"FREQ=MONTHLY;BYDAY=2MO",
+	} {
+		rule, err := ParseRecurrenceRule(value)
+		if err != nil {
+			t.Fatalf("ParseRecurrenceRule(%q): %v", value, err)
+		}
+		if got := rule.String(); got != value {
+			t.Errorf("String() of %q = %q", value, got)
+		}
+	}
+}
+
+func TestCalendarRule(t *testing.T) {
+	for _, test := range []struct {
+		rule  string
+		start time.Time
+		want  string
+	}{
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=FR", recurrenceDate(2020, time.January, 31), "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR"},
+		{"FREQ=MONTHLY;BYMONTHDAY=31", recurrenceDate(2020, time.January, 31), "FREQ=MONTHLY;BYMONTHDAY=31"},
+		{"FREQ=MONTHLY", recurrenceDate(2020, time.January, 28), "FREQ=MONTHLY"},
+		{"FREQ=MONTHLY", recurrenceDate(2020, time.January, 31), "FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{"FREQ=MONTHLY;INTERVAL=3", recurrenceDate(2020, time.January, 30), "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=28,29,30;BYSETPOS=-1"},
+		{"FREQ=MONTHLY;BYMONTH=1,3", recurrenceDate(2020, time.January, 31), "FREQ=MONTHLY;BYMONTH=1,3"},
+		{"FREQ=YEARLY", recurrenceDate(2020, time.January, 31), "FREQ=YEARLY"},
+		{"FREQ=YEARLY", recurrenceDate(2020, time.February, 29), "FREQ=YEARLY;BYMONTHDAY=-1;BYMONTH=2"},
+		{"FREQ=YEARLY;BYMONTH=2,4", recurrenceDate(2020, time.January, 30), "FREQ=YEARLY;BYMONTHDAY=-1;BYMONTH=2,4"},
+		{"FREQ=YEARLY;BYDAY=-1SU", recurrenceDate(2020, time.January, 1),
+			"FREQ=YEARLY;BYDAY=-1SU;BYMONTH=1,2,3,4,5,6,7,8,9,10,11,12"},
+		{"FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3,10", recurrenceDate(2020, time.January, 1), "FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3,10"},
+		{"FREQ=WEEKLY;BYDAY=1MO", recurrenceDate(2020, time.January, 1), ""},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", recurrenceDate(2020, time.January, 1), ""},
+		{"FREQ=YEARLY;BYMONTH=2,3", recurrenceDate(2020, time.January, 30), ""},
+		{"FREQ=MONTHLY;BYSETPOS=1", recurrenceDate(2020, time.January, 30), ""},
+	} {
+		rule, err := ParseRecurrenceRule(test.rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrenceRule(%q): %v", test.rule, err)
+		}
+		calendarRule, ok := rule.CalendarRule(test.start)
+		if got := calendarRule.String(); ok != (test.want != "") || ok && got != test.want {
+			t.Errorf("CalendarRule of %q from %v = %q, %v, want %q", test.rule, test.start, got, ok, test.want)
+		}
+		if !ok {
+			continue
+		}
+		// the spelled out rule means the same to this type as well
+		to := test.start.AddDate(8, 0, 0)
+		want, got := rule.Between(test.start, time.Time{}, to, nil), calendarRule.Between(test.start, time.Time{}, to, nil)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("occurrences of %q = %v, want %v", calendarRule, got, want)
+		}
+	}
+}
+
+func TestRecurrenceRuleBetween(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		rule       string
+		start      time.Time
+		from       time.Time
+		to         time.Time
+		exceptions []time.Time
+		want       []time.Time
+	}{
+		{"the 31st falls on the last day of shorter months", "FREQ=MONTHLY",
+			recurrenceDate(2020, time.January, 31), time.Time{}, recurrenceDate(2020, time.May, 1), nil,
+			[]time.Time{recurrenceDate(2020, time.January, 31), recurrenceDate(2020, time.February, 29),
+				recurrenceDate(2020, time.March, 31), recurrenceDate(2020, time.April, 30)}},
+		{"last business day", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			recurrenceDate(2020, time.January, 1), time.Time{}, recurrenceDate(2020, time.June, 1), nil,
+			[]time.Time{recurrenceDate(2020, time.January, 31), recurrenceDate(2020, time.February, 28),
+				recurrenceDate(2020, time.March, 31), recurrenceDate(2020, time.April, 30), recurrenceDate(2020, time.May, 29)}},
+		{"every second Friday", "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR",
+			recurrenceDate(2020, time.January, 3), time.Time{}, recurrenceDate(2020, time.February, 1), nil,
+			[]time.Time{recurrenceDate(2020, time.January, 3), recurrenceDate(2020, time.January, 17), recurrenceDate(2020, time.January, 31)}},
+		{"the 15th and the last day", "FREQ=MONTHLY;BYMONTHDAY=15,-1",
+			recurrenceDate(2020, time.February, 1), time.Time{}, recurrenceDate(2020, time.April, 1), nil,
+			[]time.Time{recurrenceDate(2020, time.February, 15), recurrenceDate(2020, time.February, 29),
+				recurrenceDate(2020, time.March, 15), recurrenceDate(2020, time.March, 31)}},
+		{"last Sunday of March", "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU",
+			recurrenceDate(2020, time.January, 1), time.Time{}, recurrenceDate(2022, time.January, 1), nil,
+			[]time.Time{recurrenceDate(2020, time.March, 29), recurrenceDate(2021, time.March, 28)}},
+		{"COUNT counts the exceptions", "FREQ=MONTHLY;COUNT=3",
+			recurrenceDate(2020, time.January, 10), time.Time{}, recurrenceDate(2021, time.January, 1),
+			[]time.Time{time.Date(2020, time.February, 10, 18, 0, 0, 0, time.UTC)},
+			[]time.Time{recurrenceDate(2020, time.January, 10), recurrenceDate(2020, time.March, 10)}},
+		{"UNTIL is inclusive", "FREQ=WEEKLY;UNTIL=20200115",
+			recurrenceDate(2020, time.January, 1), time.Time{}, recurrenceDate(2021, time.January, 1), nil,
+			[]time.Time{recurrenceDate(2020, time.January, 1), recurrenceDate(2020, time.January, 8), recurrenceDate(2020, time.January, 15)}},
+		{"window long after the start", "FREQ=DAILY;INTERVAL=10",
+			recurrenceDate(2020, time.January, 1), recurrenceDate(2021, time.January, 1), recurrenceDate(2021, time.January, 20), nil,
+			[]time.Time{recurrenceDate(2021, time.January, 5), recurrenceDate(2021, time.January, 15)}},
+		{"COUNT before the window", "FREQ=DAILY;COUNT=5",
+			recurrenceDate(2020, time.January, 1), recurrenceDate(2020, time.January, 3), recurrenceDate(2021, time.January, 1), nil,
+			[]time.Time{recurrenceDate(2020, time.January, 4), recurrenceDate(2020, time.January, 5)}},
+	} {
+		rule, err := ParseRecurrenceRule(test.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		from := test.from
+		if from.IsZero() {
+			from = test.start.Add(-time.Nanosecond)
+		}
+		if got := rule.Between(test.start, from, test.to, test.exceptions); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRecurrenceRuleKeepsTimeOfDay(t *testing.T) {
+	rule, _ := ParseRecurrenceRule("FREQ=WEEKLY;BYDAY=MO,TH")
+	start := time.Date(2020, time.January, 6, 9, 30, 0, 0, time.UTC)
+	want := []time.Time{start, time.Date(2020, time.January, 9, 9, 30, 0, 0, time.UTC), start.AddDate(0, 0, 7)}
+	if got := rule.Between(start, start.Add(-time.Nanosecond), start.AddDate(0, 0, 8), nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("occurrences = %v, want %v", got, want)
+	}
+}
+
+func TestRecurrenceRuleTimesPerYear(t *testing.T) {
+	start := recurrenceDate(2020, time.January, 1)
+	for value, want := range map[string]float64{
+		"FREQ=MONTHLY;COUNT=2":            12,
+		"FREQ=MONTHLY;INTERVAL=3":         4,
+		"FREQ=YEARLY":                     1,
+		"FREQ=MONTHLY;BYMONTHDAY=1,15":    24,
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=WE": 26,
+	} {
+		rule, _ := ParseRecurrenceRule(value)
+		if got := rule.TimesPerYear(start); got < want-0.5 || got > want+0.5 {
+			t.Errorf("%s repeats %v times a year, want %v", value, got, want)
+		}
+	}
+}
+
+func TestParseExceptionDates(t *testing.T) {
+	got, err := ParseExceptionDates("20200110, 2020-02-10,,20200310T090000Z")
+	want := []time.Time{recurrenceDate(2020, time.January, 10), recurrenceDate(2020, time.February, 10),
+		time.Date(2020, time.March, 10, 9, 0, 0, 0, time.UTC)}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseExceptionDates = %v, %v, want %v", got, err, want)
+	}
+	if _, err := ParseExceptionDates("20200110,10.02.2020"); err == nil {
+		t.Error("ParseExceptionDates of a date in another format succeeded")
+	}
+}
+
+func TestRecurrenceOf(t *testing.T) {
+	end := recurrenceDate(2020, time.December, 31)
+	for _, test := range []struct {
+		rrule     string
+		frequency int
+		period    TimePeriod
+		want      RecurrenceRule
+	}{
+		{"", 2, Week, RecurrenceRule{Frequency: Week, Interval: 2, Count: 5, Until: end}},
+		{"", 1, Quarter, RecurrenceRule{Frequency: Month, Interval: 3, Count: 5, Until: end}},
+		{"FREQ=MONTHLY;COUNT=3", 1, Week, RecurrenceRule{Frequency: Month, Interval: 1, Count: 3, Until: end}},
+		{"FREQ=MONTHLY;UNTIL=20200601", 0, Day, RecurrenceRule{Frequency: Month, Interval: 1, Count: 5,
+			Until: recurrenceDate(2020, time.June, 1)}},
+	} {
+		got, err := recurrenceOf(test.rrule, test.frequency, test.period, 5, end)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("recurrenceOf(%q, %d, %v) = %+v, %v, want %+v", test.rrule, test.frequency, test.period, got, err, test.want)
+		}
+	}
+	if ValidateRecurrence(true, "", 0, Month, "") || ValidateRecurrence(true, "FREQ=DAILY", 0, Day, "tomorrow") {
+		t.Error("an invalid recurrence was accepted")
+	}
+	if !ValidateRecurrence(false, "nonsense", -1, Day, "nonsense") || !ValidateRecurrence(true, "", 1, Month, "20200101") {
+		t.Error("a valid recurrence was rejected")
+	}
+}
@@ -0,0 +1,30 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+// RefinancingOffer is a loan a user could take to repay the rest of an existing plan. It is never stored.
+type RefinancingOffer struct {
+	BankID       uint               `json:"bankId"`
+	PaymentType  PaymentType        `json:"paymentType"`
+	InterestRate float64            `json:"interestRate"`
+	Months       uint               `json:"numberOfMonths"`
+	DayCount     DayCountConvention `json:"dayCount"`
+	StartDate    time.Time          `json:"startDate"` //дата рефинансирования, по умолчанию сегодня
+	Fees         []Fee              `json:"fees"`
+}
+
+type RefinancingComparison struct {
+	Balance           financeEntity.Money `json:"balance"`           //остаток долга на дату рефинансирования
+	CurrentCost       financeEntity.Money `json:"currentCost"`       //сколько осталось заплатить по текущему плану
+	OfferCost         financeEntity.Money `json:"offerCost"`         //сколько придется заплатить по новому кредиту вместе с комиссиями
+	Savings           financeEntity.Money `json:"savings"`           //CurrentCost - OfferCost
+	CurrentPayment    financeEntity.Money `json:"currentPayment"`    //ближайший платеж по текущему плану
+	OfferPayment      financeEntity.Money `json:"offerPayment"`      //первый платеж по новому кредиту
+	PaymentDifference financeEntity.Money `json:"paymentDifference"` //CurrentPayment - OfferPayment
+	BreakEvenMonth    uint                `json:"breakEvenMonth"`    //с какого месяца новый кредит выгоднее, 0 - никогда
+	CurrentPlan       PaymentPlan         `json:"currentPlan"`
+	OfferPlan         PaymentPlan         `json:"offerPlan"`
+}
@@ -22,3 +22,22 @@ func (period TimePeriod) String() string {
 	}
 	return names[period]
 }
+
+// TimesPerYear returns how many times a year something repeating every frequency periods happens
+func (period TimePeriod) TimesPerYear(frequency int) float64 {
+	if frequency <= 0 {
+		return 0
+	}
+	switch period {
+	case Day:
+		return 365 / float64(frequency)
+	case Week:
+		return 52 / float64(frequency)
+	case Quarter:
+		return 4 / float64(frequency)
+	case Year:
+		return 1 / float64(frequency)
+	default:
+		return 12 / float64(frequency)
+	}
+}
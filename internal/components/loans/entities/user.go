@@ -7,18 +7,20 @@ import (
 )
 
 type User struct {
-	ID           uint          `gorm:"primary_key" json:"id"`
-	CreatedAt    time.Time     `json:"-"`
-	UpdatedAt    time.Time     `json:"-"`
-	DeletedAt    time.Time     `json:"-"`
-	Username     string        `json:"username" gorm:"type:varchar(100);unique_index"`
-	Email        string        `json:"email" gorm:"type:varchar(100);unique_index"`
-	Password     string        `json:"password,omitempty"`
-	Role         roles.Role    `json:"role"`
-	PaymentPlans []PaymentPlan `json:"paymentPlans",default:"[]"`
-	Incomes      []Income      `json:"incomes",default:"[]"`
-	Expenses     []Expense     `json:"expenses",default:"[]"`
-	LastSeen     time.Time     `json:"lastSeen"`
+	ID             uint          `gorm:"primary_key" json:"id"`
+	CreatedAt      time.Time     `json:"-"`
+	UpdatedAt      time.Time     `json:"-"`
+	DeletedAt      time.Time     `json:"-"`
+	Username       string        `json:"username" gorm:"type:varchar(100);unique_index"`
+	Email          string        `json:"email" gorm:"type:varchar(100);unique_index"`
+	Password       string        `json:"password,omitempty"`
+	Role           roles.Role    `json:"role"`
+	PaymentPlans   []PaymentPlan `json:"paymentPlans",default:"[]"`
+	Incomes        []Income      `json:"incomes",default:"[]"`
+	Expenses       []Expense     `json:"expenses",default:"[]"`
+	LastSeen       time.Time     `json:"lastSeen"`
+	BaseCurrencyID uint          `json:"baseCurrencyId"` //в этой валюте показываются итоги по всем валютам
+	CalendarToken  string        `json:"-" gorm:"index"` //секрет ссылки на календарь платежей
 }
 
 func (u User) GetHashedPassword() string {
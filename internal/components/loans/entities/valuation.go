@@ -0,0 +1,18 @@
+package entities
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"time"
+)
+
+// Valuation shows a plan in the base currency of the user at the exchange rate of a date
+type Valuation struct {
+	CurrencyID     uint                `json:"currencyId"`
+	AsOf           time.Time           `json:"asOf"`
+	Rate           float64             `json:"rate"`           //курс валюты плана к базовой на AsOf
+	StartRate      float64             `json:"startRate"`      //курс на дату выдачи кредита
+	Balance        financeEntity.Money `json:"balance"`        //остаток основного долга на AsOf в валюте плана
+	BaseBalance    financeEntity.Money `json:"baseBalance"`    //остаток в базовой валюте по курсу на AsOf
+	BaseAmount     financeEntity.Money `json:"baseAmount"`     //сумма кредита в базовой валюте по курсу на дату выдачи
+	CurrencyEffect financeEntity.Money `json:"currencyEffect"` //на сколько изменение курса с даты выдачи увеличило остаток
+}
@@ -1,50 +1,448 @@
 package services
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	financeServices "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
 	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
 	"github.com/jinzhu/gorm"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func NewAgendaService(db gorm.DB) AgendaService {
-	return AgendaService{db: db}
+func NewAgendaService(db gorm.DB, exchangeService financeServices.ExchangeService) AgendaService {
+	return AgendaService{db: db, exchangeService: exchangeService}
 }
 
 type AgendaService struct {
-	db gorm.DB
+	db              gorm.DB
+	exchangeService financeServices.ExchangeService
 }
 
-func (as AgendaService) GetElementsByTimeAndUserID(from time.Time, to time.Time, userId uint) []entities.AgendaElement {
-	var elements []entities.AgendaElement
+func (as AgendaService) GetElementsByTimeAndUserID(from time.Time, to time.Time, asOf time.Time, userId uint) []entities.AgendaElement {
+	return as.GetElements(entities.AgendaQuery{From: from, To: to, AsOf: asOf}, userId)
+}
+
+// GetElements returns the agenda elements of the user between query.From and query.To. Only the element types,
+// plans and categories the query selects are loaded; amounts, order and pages are left to PageAgenda.
+// It takes at most five queries whatever the number of plans: incomes, expenses and categories dated within
+// the period, payments joined with their plans and the plans of those payments.
+func (as AgendaService) GetElements(query entities.AgendaQuery, userId uint) []entities.AgendaElement {
 	var incomes []entities.Income
 	var expenses []entities.Expense
 	var paymentPlans []entities.PaymentPlan
 	var payments []entities.Payment
 
-	as.db.Where(&entities.Income{UserID: userId}).Find(&incomes)
-	as.db.Where(&entities.Expense{UserID: userId}).Find(&expenses)
-	as.db.Where(&entities.PaymentPlan{UserID: userId}).Find(&paymentPlans)
-
-	for _, paymentPlan := range paymentPlans {
-		if paymentPlan.UserID == userId {
-			as.db.Where(&entities.Payment{PaymentPlanID: paymentPlan.ID}).Find(&payments)
+	if len(query.PlanIDs) == 0 {
+		categories := as.withSubcategories(query.CategoryIDs, userId)
+		if query.Includes("Income") {
+			as.recordsWithin(as.db.Where("user_id = ?", userId), query, categories).Find(&incomes)
+		}
+		if query.Includes("Expense") {
+			as.recordsWithin(as.db.Where("user_id = ?", userId), query, categories).Find(&expenses)
+		}
+	}
+	if len(query.CategoryIDs) == 0 && query.Includes("Payment") {
+		planPayments := as.db.Table("payments").Select("payments.*").
+			Joins("JOIN payment_plans ON payment_plans.id = payments.payment_plan_id").
+			Where("payment_plans.user_id = ? AND payments.payment_amount <> 0 AND payments.payment_date < ?", userId, query.To)
+		if query.Overdue {
+			planPayments = planPayments.Where("payments.payment_date > ? OR payments.status <> ? AND payments.payment_date < ?",
+				query.From, entities.Paid, query.AsOf)
+		} else {
+			planPayments = planPayments.Where("payments.payment_date > ?", query.From)
+		}
+		if len(query.PlanIDs) > 0 {
+			planPayments = planPayments.Where("payment_plans.id IN (?)", query.PlanIDs)
+		}
+		planPayments.Order("payments.payment_date, payments.id").Find(&payments)
+		if len(payments) > 0 {
+			planIds := map[uint]bool{}
 			for _, payment := range payments {
-				elements = append(elements, payment.TransformWithPeriod(from, to)...)
+				planIds[payment.PaymentPlanID] = true
+			}
+			ids := make([]uint, 0, len(planIds))
+			for id := range planIds {
+				ids = append(ids, id)
 			}
+			as.db.Where("id IN (?)", ids).Find(&paymentPlans)
 		}
 	}
 
+	return agendaElements(query, paymentPlans, payments, incomes, expenses)
+}
+
+// recordsWithin selects the incomes or expenses that may occur between query.From and query.To: single ones
+// dated within the period and repeating ones started before its end and not ended before its start
+func (as AgendaService) recordsWithin(db *gorm.DB, query entities.AgendaQuery, categoryIds []uint) *gorm.DB {
+	return as.filterByCategory(db, categoryIds).
+		Where("start_date < ?", query.To).
+		Where("is_repeatable AND (end_date IS NULL OR end_date <= ? OR end_date > ?) OR NOT is_repeatable AND start_date > ?",
+			time.Time{}, query.From, query.From)
+}
+
+// agendaElements expands the records into the agenda elements of the query period, each payment once.
+// Payments overdue by query.AsOf are added whatever their date if the query asks for them.
+func agendaElements(query entities.AgendaQuery, paymentPlans []entities.PaymentPlan, payments []entities.Payment,
+	incomes []entities.Income, expenses []entities.Expense) []entities.AgendaElement {
+	elements := make([]entities.AgendaElement, 0, len(payments)+len(incomes)+len(expenses))
+	plansById := make(map[uint]entities.PaymentPlan, len(paymentPlans))
+	for _, paymentPlan := range paymentPlans {
+		plansById[paymentPlan.ID] = paymentPlan
+	}
+	seen := make(map[uint]bool, len(payments))
+	for _, payment := range payments {
+		paymentPlan, ok := plansById[payment.PaymentPlanID]
+		if !ok || seen[payment.ID] {
+			continue
+		}
+		seen[payment.ID] = true
+		payment.PaymentPlan = paymentPlan
+		from := query.From
+		if query.Overdue && payment.IsOverdue(query.AsOf) {
+			from = time.Time{}
+		}
+		elements = append(elements, payment.TransformWithPeriod(from, query.To, query.AsOf)...)
+	}
 	for _, income := range incomes {
-		elements = append(elements, income.TransformWithPeriod(from, to)...)
+		elements = append(elements, income.TransformWithPeriod(query.From, query.To)...)
 	}
-
 	for _, expense := range expenses {
-		elements = append(elements, expense.TransformWithPeriod(from, to)...)
+		elements = append(elements, expense.TransformWithPeriod(query.From, query.To)...)
 	}
+	return elements
+}
 
-	for _, payment := range payments {
-		elements = append(elements, payment.TransformWithPeriod(from, to)...)
+// withSubcategories adds the subcategories at any depth to the categories available to the user
+func (as AgendaService) withSubcategories(categoryIds []uint, userId uint) []uint {
+	if len(categoryIds) == 0 {
+		return nil
 	}
+	var categories []entities.Category
+	as.db.Where("user_id = 0 OR user_id = ?", userId).Find(&categories)
+	selected := map[uint]bool{}
+	for _, categoryId := range categoryIds {
+		selected[categoryId] = true
+	}
+	for added := true; added; {
+		added = false
+		for _, category := range categories {
+			if selected[category.ParentID] && !selected[category.ID] {
+				selected[category.ID], added = true, true
+			}
+		}
+	}
+	result := make([]uint, 0, len(selected))
+	for categoryId := range selected {
+		result = append(result, categoryId)
+	}
+	return result
+}
 
-	return elements
+func (as AgendaService) filterByCategory(db *gorm.DB, categoryIds []uint) *gorm.DB {
+	if len(categoryIds) == 0 {
+		return db
+	}
+	return db.Where("category_id IN (?)", categoryIds)
+}
+
+// ToBaseCurrency fills in the amounts of the elements in the base currency of the user at the exchange
+// rates known on date and sums them up
+func (as AgendaService) ToBaseCurrency(elements []entities.AgendaElement, userId uint, date time.Time) ([]entities.AgendaElement, entities.AgendaTotals, error) {
+	var user entities.User
+	as.db.First(&user, userId)
+	converter, err := as.exchangeService.Converter(user.BaseCurrencyID, date)
+	if err != nil {
+		return elements, entities.AgendaTotals{CurrencyID: user.BaseCurrencyID}, err
+	}
+	elements, totals := convertElements(elements, converter)
+	return elements, totals, nil
+}
+
+// convertElements fills in the base amounts of the elements and sums them up. Elements in a currency the
+// converter has no rate for are flagged as unconverted and left out of the totals, as plans without a rate
+// are left without a valuation. Without a base currency amounts in different currencies cannot be added up,
+// so then none of them is converted.
+func convertElements(elements []entities.AgendaElement, converter financeEntity.CurrencyConverter) ([]entities.AgendaElement, entities.AgendaTotals) {
+	mixed := false
+	if converter.Currency.ID == 0 {
+		var currencyId uint
+		for _, element := range elements {
+			if element.CurrencyID != 0 && currencyId != 0 && element.CurrencyID != currencyId {
+				mixed = true
+				break
+			}
+			if element.CurrencyID != 0 {
+				currencyId = element.CurrencyID
+			}
+		}
+	}
+	for i, element := range elements {
+		amount, ok := converter.Convert(element.PaymentAmount, element.CurrencyID)
+		if mixed || !ok {
+			elements[i].BaseAmount, elements[i].Unconverted = 0, true
+			continue
+		}
+		elements[i].BaseAmount, elements[i].Unconverted = amount, false
+	}
+	return elements, sumElements(elements, entities.AgendaTotals{CurrencyID: converter.Currency.ID})
+}
+
+// sumElements adds the base amounts of the elements to the totals, counting the unconverted ones apart
+func sumElements(elements []entities.AgendaElement, totals entities.AgendaTotals) entities.AgendaTotals {
+	for _, element := range elements {
+		if element.Unconverted {
+			totals.Unconverted++
+			continue
+		}
+		switch element.ElementType {
+		case "Income":
+			totals.Incomes += element.BaseAmount
+		case "Expense":
+			totals.Expenses += element.BaseAmount
+		default:
+			totals.Payments += element.BaseAmount
+		}
+	}
+	totals.Balance = totals.Incomes - totals.Expenses - totals.Payments
+	return totals
+}
+
+// AgendaPage is a page of agenda elements with the totals and groups of all the elements matching the query
+type AgendaPage struct {
+	Elements   []entities.AgendaElement
+	Total      int
+	Totals     entities.AgendaTotals
+	Groups     []entities.AgendaGroup
+	NextCursor string
+}
+
+// PageAgenda filters the elements by the amount bounds of the query, sorts them, sums them up by group and
+// returns the page following the cursor. Elements are ordered by date or amount, then by type, id and date,
+// so that a cursor names a position in the order even if elements are added or removed between the requests.
+func PageAgenda(elements []entities.AgendaElement, query entities.AgendaQuery, totals entities.AgendaTotals) (AgendaPage, error) {
+	var filtered []entities.AgendaElement
+	for _, element := range elements {
+		// unconverted elements have no amount to bound, so bounds leave them out
+		if (query.MinAmount != nil || query.MaxAmount != nil) && element.Unconverted ||
+			query.MinAmount != nil && element.BaseAmount < *query.MinAmount ||
+			query.MaxAmount != nil && element.BaseAmount > *query.MaxAmount {
+			continue
+		}
+		filtered = append(filtered, element)
+	}
+	totals.Incomes, totals.Expenses, totals.Payments, totals.Unconverted = 0, 0, 0, 0
+	page := AgendaPage{Elements: []entities.AgendaElement{}, Total: len(filtered), Totals: sumElements(filtered, totals)}
+
+	less := func(a, b entities.AgendaElement) bool {
+		if query.SortByAmount && a.BaseAmount != b.BaseAmount {
+			return a.BaseAmount < b.BaseAmount != query.Descending
+		}
+		if !a.PaymentDate.Equal(b.PaymentDate) {
+			return a.PaymentDate.Before(b.PaymentDate) != query.Descending
+		}
+		if a.ElementType != b.ElementType {
+			return a.ElementType < b.ElementType
+		}
+		return a.ID < b.ID
+	}
+	sort.SliceStable(filtered, func(i, j int) bool { return less(filtered[i], filtered[j]) })
+
+	if query.GroupBy != nil {
+		page.Groups = groupElements(filtered, *query.GroupBy)
+	}
+
+	start := 0
+	if query.Cursor != "" {
+		after, err := decodeAgendaCursor(query.Cursor)
+		if err != nil {
+			return page, err
+		}
+		start = sort.Search(len(filtered), func(i int) bool { return less(after, filtered[i]) })
+	}
+	end := len(filtered)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+		page.NextCursor = encodeAgendaCursor(filtered[end-1])
+	}
+	if start < end {
+		page.Elements = filtered[start:end]
+	}
+	return page, nil
+}
+
+// groupElements sums up the elements by the day, the week starting on Monday or the month of their dates.
+// Unconverted elements are counted but not summed up.
+func groupElements(elements []entities.AgendaElement, period entities.TimePeriod) []entities.AgendaGroup {
+	groups := map[time.Time]*entities.AgendaGroup{}
+	for _, element := range elements {
+		year, month, day := element.PaymentDate.Date()
+		start := time.Date(year, month, day, 0, 0, 0, 0, element.PaymentDate.Location())
+		switch period {
+		case entities.Week:
+			start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+		case entities.Month:
+			start = time.Date(year, month, 1, 0, 0, 0, 0, element.PaymentDate.Location())
+		}
+		group, ok := groups[start]
+		if !ok {
+			group = &entities.AgendaGroup{Start: start}
+			groups[start] = group
+		}
+		group.Count++
+		if element.Unconverted {
+			continue
+		}
+		switch element.ElementType {
+		case "Income":
+			group.Incomes += element.BaseAmount
+			group.Balance += element.BaseAmount
+		case "Expense":
+			group.Expenses += element.BaseAmount
+			group.Balance -= element.BaseAmount
+		default:
+			group.Payments += element.BaseAmount
+			group.Balance -= element.BaseAmount
+		}
+	}
+	result := make([]entities.AgendaGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start.Before(result[j].Start) })
+	return result
+}
+
+// encodeAgendaCursor names the position of the element in the order of PageAgenda
+func encodeAgendaCursor(element entities.AgendaElement) string {
+	key := fmt.Sprintf("%s|%d|%s|%d", element.ElementType, element.ID, element.PaymentDate.Format(time.RFC3339Nano), int64(element.BaseAmount))
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeAgendaCursor(cursor string) (entities.AgendaElement, error) {
+	var element entities.AgendaElement
+	badCursor := errors.New(codes.BadAgendaQuery)
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return element, badCursor
+	}
+	parts := strings.Split(string(key), "|")
+	if len(parts) != 4 {
+		return element, badCursor
+	}
+	id, idError := strconv.ParseUint(parts[1], 10, 32)
+	date, dateError := time.Parse(time.RFC3339Nano, parts[2])
+	amount, amountError := strconv.ParseInt(parts[3], 10, 64)
+	if idError != nil || dateError != nil || amountError != nil {
+		return element, badCursor
+	}
+	element.ElementType, element.ID, element.PaymentDate, element.BaseAmount = parts[0], uint(id), date, financeEntity.Money(amount)
+	return element, nil
+}
+
+// Forecast projects the balance of the user from the starting balance over the agenda between from and to,
+// with a point per day or per month. Payments overdue by from are due at once, so they count on the first day.
+func (as AgendaService) Forecast(userId uint, balance financeEntity.Money, from time.Time, to time.Time, interval entities.TimePeriod) (entities.Forecast, error) {
+	var elements []entities.AgendaElement
+	for _, element := range as.GetElements(entities.AgendaQuery{From: from, To: to, AsOf: from, Overdue: true}, userId) {
+		if element.PaymentDate.Before(to) && (!element.PaymentDate.Before(from) || element.IsOverdue) {
+			elements = append(elements, element)
+		}
+	}
+	elements, totals, err := as.ToBaseCurrency(elements, userId, from)
+	if err != nil {
+		return entities.Forecast{}, err
+	}
+	forecast := ProjectBalance(elements, balance, from, to, interval)
+	forecast.CurrencyID, forecast.Unconverted = totals.CurrencyID, totals.Unconverted
+	return forecast, nil
+}
+
+// ProjectBalance runs the balance through the elements in date order, incomes first within the same moment,
+// and closes a point at the end of every day or month between from and to. Unconverted elements are skipped.
+func ProjectBalance(elements []entities.AgendaElement, balance financeEntity.Money, from time.Time, to time.Time, interval entities.TimePeriod) entities.Forecast {
+	sort.SliceStable(elements, func(i, j int) bool {
+		if !elements[i].PaymentDate.Equal(elements[j].PaymentDate) {
+			return elements[i].PaymentDate.Before(elements[j].PaymentDate)
+		}
+		return elements[i].ElementType == "Income" && elements[j].ElementType != "Income"
+	})
+	forecast := entities.Forecast{
+		From:           from,
+		To:             to,
+		Interval:       interval,
+		StartBalance:   balance,
+		MinBalance:     balance,
+		MinBalanceDate: from,
+		NegativeDates:  []time.Time{},
+		Points:         []entities.ForecastPoint{},
+	}
+	next := 0
+	for start := periodStart(from, interval); start.Before(to); start = nextPeriod(start, interval) {
+		point := entities.ForecastPoint{Date: start, MinBalance: balance}
+		end := nextPeriod(start, interval)
+		for ; next < len(elements) && elements[next].PaymentDate.Before(end); next++ {
+			element := elements[next]
+			if element.Unconverted {
+				continue
+			}
+			switch element.ElementType {
+			case "Income":
+				point.Incomes += element.BaseAmount
+				balance += element.BaseAmount
+			case "Expense":
+				point.Expenses += element.BaseAmount
+				balance -= element.BaseAmount
+			default:
+				point.Payments += element.BaseAmount
+				balance -= element.BaseAmount
+			}
+			date := element.PaymentDate
+			if date.Before(from) {
+				date = from
+			}
+			if balance < point.MinBalance {
+				point.MinBalance = balance
+			}
+			if balance < forecast.MinBalance {
+				forecast.MinBalance, forecast.MinBalanceDate = balance, date
+			}
+			if balance < 0 && element.ElementType != "Income" {
+				forecast.NegativeDates = appendDate(forecast.NegativeDates, date)
+			}
+		}
+		point.Balance = balance
+		point.IsNegative = point.MinBalance < 0
+		forecast.Points = append(forecast.Points, point)
+	}
+	forecast.EndBalance = balance
+	return forecast
+}
+
+func periodStart(date time.Time, interval entities.TimePeriod) time.Time {
+	if interval == entities.Month {
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
+
+func nextPeriod(start time.Time, interval entities.TimePeriod) time.Time {
+	if interval == entities.Month {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// appendDate adds the day of the date unless it is already the last one
+func appendDate(dates []time.Time, date time.Time) []time.Time {
+	day := periodStart(date, entities.Day)
+	if len(dates) > 0 && dates[len(dates)-1].Equal(day) {
+		return dates
+	}
+	return append(dates, day)
 }
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"fmt"
+	"github.com/a1ta1r/Credit-Portfolio/internal/app"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	financeServices "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"os"
+	"testing"
+	"time"
+)
+
+var benchmarkStart = time.Date(2000, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+// agendaHistory builds plans of monthly payments over the years and as many monthly incomes and weekly expenses
+func agendaHistory(plans int, years int) ([]entities.PaymentPlan, []entities.Payment, []entities.Income, []entities.Expense) {
+	var paymentPlans []entities.PaymentPlan
+	var payments []entities.Payment
+	var incomes []entities.Income
+	var expenses []entities.Expense
+	for i := 1; i <= plans; i++ {
+		paymentPlans = append(paymentPlans, entities.PaymentPlan{ID: uint(i), UserID: 1, Title: fmt.Sprint("Plan ", i), StartDate: benchmarkStart})
+		for month := 1; month <= 12*years; month++ {
+			payments = append(payments, entities.Payment{
+				ID:            uint(len(payments) + 1),
+				PaymentPlanID: uint(i),
+				PaymentAmount: 1000,
+				PaymentDate:   benchmarkStart.AddDate(0, month, 0),
+				Status:        entities.Paid,
+			})
+		}
+		incomes = append(incomes, entities.Income{ID: uint(i), UserID: 1, Amount: 1000, StartDate: benchmarkStart,
+			IsRepeatable: true, Frequency: 1, PaymentPeriod: entities.Month})
+		expenses = append(expenses, entities.Expense{ID: uint(i), UserID: 1, Amount: 100, StartDate: benchmarkStart,
+			IsRepeatable: true, Frequency: 1, PaymentPeriod: entities.Week})
+	}
+	return paymentPlans, payments, incomes, expenses
+}
+
+// BenchmarkAgendaElements expands a year of the agenda of users with growing numbers of plans and years of history
+func BenchmarkAgendaElements(b *testing.B) {
+	for _, size := range []struct{ plans, years int }{{5, 5}, {24, 10}, {48, 30}} {
+		paymentPlans, payments, incomes, expenses := agendaHistory(size.plans, size.years)
+		from := benchmarkStart.AddDate(size.years-1, 0, -1)
+		to := from.AddDate(1, 0, 0)
+		b.Run(fmt.Sprintf("plans=%d/years=%d", size.plans, size.years), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				elements := agendaElements(entities.AgendaQuery{From: from, To: to, AsOf: to}, paymentPlans, payments, incomes, expenses)
+				if payments := countElements(elements, "Payment"); payments != 12*size.plans {
+					b.Fatalf("got %d payments, want %d", payments, 12*size.plans)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetElements runs the queries of the agenda against the database POSTGRES points to. The data is
+// created in a transaction that is rolled back, so the database must only have the schema.
+func BenchmarkGetElements(b *testing.B) {
+	if os.Getenv("POSTGRES") == "" {
+		b.Skip("POSTGRES is not set")
+	}
+	db, err := app.GetConnection()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, size := range []struct{ plans, years int }{{5, 5}, {24, 10}} {
+		b.Run(fmt.Sprintf("plans=%d/years=%d", size.plans, size.years), func(b *testing.B) {
+			tx := db.Begin()
+			defer tx.Rollback()
+			user := entities.User{Username: fmt.Sprint("agenda-benchmark-", time.Now().UnixNano())}
+			user.Email = user.Username
+			tx.Create(&user)
+			paymentPlans, payments, incomes, expenses := agendaHistory(size.plans, size.years)
+			planIds := map[uint]uint{}
+			for _, paymentPlan := range paymentPlans {
+				id := paymentPlan.ID
+				paymentPlan.ID, paymentPlan.UserID = 0, user.ID
+				tx.Create(&paymentPlan)
+				planIds[id] = paymentPlan.ID
+			}
+			for _, payment := range payments {
+				payment.ID, payment.PaymentPlanID = 0, planIds[payment.PaymentPlanID]
+				tx.Create(&payment)
+			}
+			for _, income := range incomes {
+				income.ID, income.UserID = 0, user.ID
+				tx.Create(&income)
+			}
+			for _, expense := range expenses {
+				expense.ID, expense.UserID = 0, user.ID
+				tx.Create(&expense)
+			}
+			agendaService := NewAgendaService(*tx, financeServices.NewExchangeService(*tx))
+			from := benchmarkStart.AddDate(size.years-1, 0, -1)
+			to := from.AddDate(1, 0, 0)
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				elements := agendaService.GetElementsByTimeAndUserID(from, to, to, user.ID)
+				if payments := countElements(elements, "Payment"); payments != 12*size.plans {
+					b.Fatalf("got %d payments, want %d", payments, 12*size.plans)
+				}
+			}
+		})
+	}
+}
+
+func countElements(elements []entities.AgendaElement, elementType string) int {
+	count := 0
+	for _, element := range elements {
+		if element.ElementType == elementType {
+			count++
+		}
+	}
+	return count
+}
+
+func TestConvertElements(t *testing.T) {
+	rub := financeEntity.Currency{ID: 1, MinorUnits: 100}
+	converter := financeEntity.CurrencyConverter{Currency: rub, Date: benchmarkStart, Rates: []financeEntity.ExchangeRate{
+		{CurrencyID: 2, QuoteCurrencyID: 1, RateDate: benchmarkStart, Rate: 90},
+	}}
+	elements := []entities.AgendaElement{
+		{ElementType: "Income", PaymentAmount: 1000000, CurrencyID: 1},
+		{ElementType: "Expense", PaymentAmount: 10000, CurrencyID: 2},
+		{ElementType: "Payment", PaymentAmount: 50000, CurrencyID: 3},
+		{ElementType: "Expense", PaymentAmount: 20000},
+	}
+	elements, totals := convertElements(elements, converter)
+	if elements[1].BaseAmount != 900000 || elements[1].Unconverted {
+		t.Errorf("expense in a currency with a rate = %v", elements[1])
+	}
+	if !elements[2].Unconverted || elements[2].BaseAmount != 0 {
+		t.Errorf("payment in a currency without a rate = %v, want it unconverted", elements[2])
+	}
+	want := entities.AgendaTotals{CurrencyID: 1, Incomes: 1000000, Expenses: 920000, Payments: 0, Balance: 80000, Unconverted: 1}
+	if totals != want {
+		t.Errorf("totals = %+v, want %+v", totals, want)
+	}
+
+	forecast := ProjectBalance(elements, 0, benchmarkStart.AddDate(0, 0, -1), benchmarkStart.AddDate(0, 0, 1), entities.Day)
+	if forecast.EndBalance != 80000 {
+		t.Errorf("forecast ends with %v, want the unconverted payment skipped", forecast.EndBalance)
+	}
+}
+
+func TestConvertElementsWithoutBaseCurrency(t *testing.T) {
+	converter := financeEntity.CurrencyConverter{Date: benchmarkStart}
+	single := []entities.AgendaElement{
+		{ElementType: "Income", PaymentAmount: 1000000, CurrencyID: 1},
+		{ElementType: "Expense", PaymentAmount: 20000},
+	}
+	if _, totals := convertElements(single, converter); totals.Balance != 980000 || totals.Unconverted != 0 {
+		t.Errorf("totals of a single currency = %+v", totals)
+	}
+	mixed := []entities.AgendaElement{
+		{ElementType: "Income", PaymentAmount: 1000000, CurrencyID: 1},
+		{ElementType: "Expense", PaymentAmount: 10000, CurrencyID: 2},
+	}
+	mixed, totals := convertElements(mixed, converter)
+	if totals != (entities.AgendaTotals{Unconverted: 2}) || !mixed[0].Unconverted || !mixed[1].Unconverted {
+		t.Errorf("mixed currencies without a base one were summed up: %+v", totals)
+	}
+}
+
+func TestPageAgendaAmountBoundsSkipUnconverted(t *testing.T) {
+	minAmount := financeEntity.Money(0)
+	elements := []entities.AgendaElement{
+		{ElementType: "Income", ID: 1, BaseAmount: 10000},
+		{ElementType: "Income", ID: 2, Unconverted: true},
+	}
+	page, err := PageAgenda(elements, entities.AgendaQuery{MinAmount: &minAmount}, entities.AgendaTotals{})
+	if err != nil || page.Total != 1 || page.Totals.Unconverted != 0 {
+		t.Errorf("PageAgenda with an amount bound = %+v, %v", page, err)
+	}
+	page, err = PageAgenda(elements, entities.AgendaQuery{}, entities.AgendaTotals{})
+	if err != nil || page.Total != 2 || page.Totals.Unconverted != 1 || page.Totals.Incomes != 10000 {
+		t.Errorf("PageAgenda without bounds = %+v, %v", page, err)
+	}
+}
+
+func TestAgendaElementsOverdue(t *testing.T) {
+	from := benchmarkStart.AddDate(0, 2, 0)
+	to := from.AddDate(0, 1, 0)
+	paymentPlans := []entities.PaymentPlan{{ID: 1, UserID: 1, Title: "Plan"}}
+	payments := []entities.Payment{
+		{ID: 1, PaymentPlanID: 1, PaymentAmount: 1000, PaymentDate: from.AddDate(0, -1, 0), Status: entities.Missed},
+		{ID: 2, PaymentPlanID: 1, PaymentAmount: 1000, PaymentDate: from.AddDate(0, -1, 1), Status: entities.Paid},
+		{ID: 3, PaymentPlanID: 1, PaymentAmount: 1000, PaymentDate: from.AddDate(0, 0, 10), Status: entities.Scheduled},
+		{ID: 4, PaymentPlanID: 1, PaymentAmount: 1000, PaymentDate: to.AddDate(0, 0, 1), Status: entities.Scheduled},
+	}
+	for _, test := range []struct {
+		query   entities.AgendaQuery
+		ids     []uint
+		overdue []bool
+	}{
+		{entities.AgendaQuery{From: from, To: to, AsOf: from}, []uint{3}, []bool{false}},
+		{entities.AgendaQuery{From: from, To: to, AsOf: to}, []uint{3}, []bool{true}},
+		{entities.AgendaQuery{From: from, To: to, AsOf: from, Overdue: true}, []uint{1, 3}, []bool{true, false}},
+		{entities.AgendaQuery{From: from, To: to}, []uint{3}, []bool{false}},
+	} {
+		elements := agendaElements(test.query, paymentPlans, payments, nil, nil)
+		if len(elements) != len(test.ids) {
+			t.Errorf("query %+v returned %d elements, want %v", test.query, len(elements), test.ids)
+			continue
+		}
+		for i, element := range elements {
+			if element.ID != test.ids[i] || element.IsOverdue != test.overdue[i] {
+				t.Errorf("query %+v returned payment %d overdue %v, want %d overdue %v",
+					test.query, element.ID, element.IsOverdue, test.ids[i], test.overdue[i])
+			}
+		}
+	}
+}
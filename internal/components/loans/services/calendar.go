@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"io"
+	"strings"
+	"time"
+)
+
+const calendarDomain = "credit-portfolio"
+
+// calendarYears is how many years ahead the feed lists the dates of rules that RFC 5545 cannot express
+const calendarYears = 2
+
+// CalendarToken returns the secret of the calendar feed of the user, creating it on first use
+func (as AgendaService) CalendarToken(userId uint) (string, error) {
+	var user entities.User
+	as.db.First(&user, userId)
+	if user.CalendarToken != "" {
+		return user.CalendarToken, nil
+	}
+	return as.RotateCalendarToken(userId)
+}
+
+// RotateCalendarToken replaces the secret of the calendar feed of the user, so the old feed URL stops working
+func (as AgendaService) RotateCalendarToken(userId uint) (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(secret)
+	if err := as.db.Model(&entities.User{ID: userId}).Update("calendar_token", token).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetUserIDByCalendarToken returns the user the calendar feed secret belongs to, zero if none
+func (as AgendaService) GetUserIDByCalendarToken(token string) uint {
+	var user entities.User
+	if token == "" {
+		return 0
+	}
+	as.db.Where("calendar_token = ?", token).First(&user)
+	return user.ID
+}
+
+// GetCalendarEvents returns the incomes and expenses of the user as events repeating by their rules and
+// the payments of the plans as dated events. Rules that RFC 5545 cannot express are listed as dates until
+// calendarYears after date.
+func (as AgendaService) GetCalendarEvents(userId uint, date time.Time) []entities.CalendarEvent {
+	var incomes []entities.Income
+	var expenses []entities.Expense
+	var paymentPlans []entities.PaymentPlan
+	var currencies []financeEntity.Currency
+	as.db.Where(&entities.Income{UserID: userId}).Find(&incomes)
+	as.db.Where(&entities.Expense{UserID: userId}).Find(&expenses)
+	as.db.Where(&entities.PaymentPlan{UserID: userId}).Preload("Payments", "payment_amount > 0").Find(&paymentPlans)
+	as.db.Find(&currencies)
+	currencyNames := map[uint]string{}
+	for _, currency := range currencies {
+		currencyNames[currency.ID] = currency.Name
+	}
+
+	events := []entities.CalendarEvent{}
+	for _, income := range incomes {
+		rule, ruleError := income.Recurrence()
+		exceptions, _ := entities.ParseExceptionDates(income.ExDates)
+		description := fmt.Sprintf("Income %s %s", income.Amount, currencyNames[income.CurrencyID])
+		events = appendRecordEvent(events, fmt.Sprintf("income-%d", income.ID), income.Reason, description,
+			income.StartDate, income.IsRepeatable && ruleError == nil, rule, exceptions, date.AddDate(calendarYears, 0, 0))
+	}
+	for _, expense := range expenses {
+		rule, ruleError := expense.Recurrence()
+		exceptions, _ := entities.ParseExceptionDates(expense.ExDates)
+		description := fmt.Sprintf("Expense %s %s", expense.Amount, currencyNames[expense.CurrencyID])
+		events = appendRecordEvent(events, fmt.Sprintf("expense-%d", expense.ID), expense.Reason, description,
+			expense.StartDate, expense.IsRepeatable && ruleError == nil, rule, exceptions, date.AddDate(calendarYears, 0, 0))
+	}
+	for _, paymentPlan := range paymentPlans {
+		for _, payment := range paymentPlan.Payments {
+			description := fmt.Sprintf("Loan payment %s %s", payment.PaymentAmount, currencyNames[paymentPlan.CurrencyID])
+			if payment.Status != entities.Scheduled {
+				description += ", " + payment.Status.String()
+			}
+			events = append(events, entities.CalendarEvent{
+				UID:         fmt.Sprintf("payment-%d", payment.ID),
+				Summary:     paymentPlan.Title,
+				Description: description,
+				Date:        payment.PaymentDate,
+			})
+		}
+	}
+	return events
+}
+
+// appendRecordEvent adds the event of an income or an expense. A repeating one starts on its first occurrence,
+// since iCalendar counts the start of an event as an occurrence even if the rule does not match it. A rule
+// calendar clients would read differently is replaced by the dates of its occurrences before until.
+func appendRecordEvent(events []entities.CalendarEvent, uid string, summary string, description string, start time.Time,
+	isRepeatable bool, rule entities.RecurrenceRule, exceptions []time.Time, until time.Time) []entities.CalendarEvent {
+	event := entities.CalendarEvent{UID: uid, Summary: summary, Description: description, Date: start}
+	if isRepeatable {
+		horizon := start.AddDate(100, 0, 0)
+		if !rule.Until.IsZero() && rule.Until.Before(horizon) {
+			horizon = rule.Until.AddDate(0, 0, 1)
+		}
+		first := rule
+		first.Count = 1
+		occurrences := first.Between(start, start.Add(-time.Nanosecond), horizon, nil)
+		if len(occurrences) == 0 {
+			return events
+		}
+		event.Date = occurrences[0]
+		if calendarRule, ok := rule.CalendarRule(start); ok {
+			event.Rule, event.ExDates = &calendarRule, exceptions
+			return append(events, event)
+		}
+		dates := rule.Between(start, start.Add(-time.Nanosecond), until, exceptions)
+		if len(dates) == 0 {
+			return events
+		}
+		event.Date, event.RDates = dates[0], dates[1:]
+	}
+	return append(events, event)
+}
+
+// ElementEvents turns agenda elements into dated events
+func ElementEvents(elements []entities.AgendaElement) []entities.CalendarEvent {
+	events := []entities.CalendarEvent{}
+	for _, element := range elements {
+		events = append(events, entities.CalendarEvent{
+			UID:         fmt.Sprintf("%s-%d-%s", strings.ToLower(element.ElementType), element.ID, element.PaymentDate.Format("20060102")),
+			Summary:     element.Title,
+			Description: fmt.Sprintf("%s %s", element.ElementType, element.PaymentAmount),
+			Date:        element.PaymentDate,
+		})
+	}
+	return events
+}
+
+// WriteCalendar writes the events as an iCalendar (RFC 5545) document of all-day events
+func WriteCalendar(writer io.Writer, name string, events []entities.CalendarEvent) error {
+	buffered := bufio.NewWriter(writer)
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Credit Portfolio//Agenda//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + escapeCalendarText(name),
+	}
+	for _, event := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+event.UID+"@"+calendarDomain,
+			"DTSTAMP:"+stamp,
+			"DTSTART;VALUE=DATE:"+event.Date.Format("20060102"),
+			"DTEND;VALUE=DATE:"+event.Date.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:"+escapeCalendarText(event.Summary),
+		)
+		if event.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escapeCalendarText(event.Description))
+		}
+		if event.Rule != nil {
+			lines = append(lines, "RRULE:"+event.Rule.String())
+		}
+		if len(event.RDates) > 0 {
+			var dates []string
+			for _, date := range event.RDates {
+				dates = append(dates, date.Format("20060102"))
+			}
+			lines = append(lines, "RDATE;VALUE=DATE:"+strings.Join(dates, ","))
+		}
+		for _, exception := range event.ExDates {
+			lines = append(lines, "EXDATE;VALUE=DATE:"+exception.Format("20060102"))
+		}
+		lines = append(lines, "TRANSP:TRANSPARENT", "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+	for _, line := range lines {
+		if _, err := buffered.WriteString(foldCalendarLine(line)); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// escapeCalendarText escapes a TEXT value as RFC 5545 requires
+func escapeCalendarText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// foldCalendarLine ends the line with CRLF, folding it into lines of at most 75 octets without splitting characters
+func foldCalendarLine(line string) string {
+	var folded strings.Builder
+	length := 0
+	for _, character := range line {
+		size := len(string(character))
+		if length+size > 75 {
+			folded.WriteString("\r\n ")
+			length = 1
+		}
+		folded.WriteRune(character)
+		length += size
+	}
+	folded.WriteString("\r\n")
+	return folded.String()
+}
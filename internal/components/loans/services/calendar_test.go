@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bytes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeCalendarText(t *testing.T) {
+	for text, want := range map[string]string{
+		"Rent":                  "Rent",
+		"Rent; flat, Moscow":    `Rent\; flat\, Moscow`,
+		`C:\path`:               `C:\\path`,
+		"first\nsecond":         `first\nsecond`,
+		"first\r\nsecond":       `first\nsecond`,
+		`\;`:                    `\\\;`,
+		"Ипотека, Сбербанк":     `Ипотека\, Сбербанк`,
+		"colon: stays as it is": "colon: stays as it is",
+	} {
+		if got := escapeCalendarText(text); got != want {
+			t.Errorf("escapeCalendarText(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestFoldCalendarLine(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"SUMMARY:Rent",
+		"DESCRIPTION:" + strings.Repeat("x", 63),
+		"DESCRIPTION:" + strings.Repeat("x", 64),
+		"DESCRIPTION:" + strings.Repeat("x", 300),
+		"SUMMARY:" + strings.Repeat("Ипотека ", 20),
+		"SUMMARY:" + strings.Repeat("€", 50),
+	} {
+		folded := foldCalendarLine(line)
+		if !strings.HasSuffix(folded, "\r\n") {
+			t.Errorf("folded %q does not end with CRLF", line)
+		}
+		parts := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+		for i, part := range parts {
+			if len(part) > 75 {
+				t.Errorf("line %d of %q takes %d octets", i, line, len(part))
+			}
+			if !utf8.ValidString(part) {
+				t.Errorf("line %d of %q splits a character", i, line)
+			}
+			if i > 0 && !strings.HasPrefix(part, " ") {
+				t.Errorf("continuation %d of %q does not start with a space", i, line)
+			}
+		}
+		if len(line) <= 75 && len(parts) != 1 {
+			t.Errorf("%q of %d octets was folded", line, len(line))
+		}
+		if unfolded := strings.Replace(strings.TrimSuffix(folded, "\r\n"), "\r\n ", "", -1); unfolded != line {
+			t.Errorf("unfolded %q, want %q", unfolded, line)
+		}
+	}
+}
+
+func TestWriteCalendar(t *testing.T) {
+	rule, _ := entities.ParseRecurrenceRule("FREQ=MONTHLY;BYMONTHDAY=-1")
+	exception := time.Date(2020, time.March, 31, 0, 0, 0, 0, time.UTC)
+	var events []entities.CalendarEvent
+	events = appendRecordEvent(events, "income-1", "Salary, main job", "", time.Date(2020, time.January, 10, 0, 0, 0, 0, time.UTC),
+		true, rule, []time.Time{exception}, time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC))
+	events = append(events, ElementEvents([]entities.AgendaElement{{ID: 2, ElementType: "Payment", Title: "Mortgage",
+		PaymentDate: time.Date(2020, time.February, 15, 0, 0, 0, 0, time.UTC)}})...)
+	events = append(events, entities.CalendarEvent{UID: "expense-3", Summary: "Gym", Date: time.Date(2020, time.January, 6, 0, 0, 0, 0, time.UTC),
+		RDates: []time.Time{time.Date(2020, time.February, 3, 0, 0, 0, 0, time.UTC), time.Date(2020, time.March, 2, 0, 0, 0, 0, time.UTC)}})
+
+	var output bytes.Buffer
+	if err := WriteCalendar(&output, "Agenda; main", events); err != nil {
+		t.Fatal(err)
+	}
+	calendar := output.String()
+	for _, line := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:Agenda\\; main\r\n",
+		"UID:income-1@" + calendarDomain + "\r\n",
+		// a repeating event starts on its first occurrence rather than on the date of the record
+		"DTSTART;VALUE=DATE:20200131\r\n",
+		"DTEND;VALUE=DATE:20200201\r\n",
+		"SUMMARY:Salary\\, main job\r\n",
+		"RRULE:FREQ=MONTHLY;BYMONTHDAY=-1\r\n",
+		"EXDATE;VALUE=DATE:20200331\r\n",
+		"UID:payment-2-20200215@" + calendarDomain + "\r\n",
+		"DTSTART;VALUE=DATE:20200215\r\n",
+		"RDATE;VALUE=DATE:20200203,20200302\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(calendar, line) {
+			t.Errorf("calendar has no line %q:\n%s", line, calendar)
+		}
+	}
+	if count := strings.Count(calendar, "BEGIN:VEVENT"); count != 3 {
+		t.Errorf("calendar has %d events, want 3", count)
+	}
+	if strings.Contains(strings.Replace(calendar, "\r\n", "", -1), "\n") {
+		t.Error("calendar has lines ending without CR")
+	}
+}
+
+func TestAppendRecordEventWithoutOccurrences(t *testing.T) {
+	rule, _ := entities.ParseRecurrenceRule("FREQ=MONTHLY;BYMONTHDAY=31;UNTIL=20200301")
+	events := appendRecordEvent(nil, "expense-1", "Rent", "", time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC), true, rule, nil,
+		time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if len(events) != 0 {
+		t.Errorf("events = %+v, want none for a rule that never occurs", events)
+	}
+}
+
+func TestAppendRecordEventCalendarRule(t *testing.T) {
+	until := time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)
+	exception := time.Date(2020, time.March, 31, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		rule   string
+		start  time.Time
+		want   string
+		date   time.Time
+		rdates []time.Time
+	}{
+		// the 31st falls on the last day of shorter months, which RFC 5545 would skip
+		{"FREQ=MONTHLY", time.Date(2020, time.January, 31, 0, 0, 0, 0, time.UTC), "FREQ=MONTHLY;BYMONTHDAY=-1",
+			time.Date(2020, time.January, 31, 0, 0, 0, 0, time.UTC), nil},
+		{"FREQ=WEEKLY;BYDAY=1MO,-1FR", time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), "",
+			time.Date(2020, time.January, 6, 0, 0, 0, 0, time.UTC), []time.Time{
+				time.Date(2020, time.January, 31, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, time.February, 3, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, time.February, 28, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, time.March, 2, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, time.April, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, time.April, 24, 0, 0, 0, 0, time.UTC),
+			}},
+	} {
+		rule, _ := entities.ParseRecurrenceRule(test.rule)
+		events := appendRecordEvent(nil, "income-1", "Salary", "", test.start, true, rule, []time.Time{exception, time.Date(2020, time.March, 27, 0, 0, 0, 0, time.UTC)}, until)
+		if len(events) != 1 {
+			t.Fatalf("events of %q = %+v, want one", test.rule, events)
+		}
+		event := events[0]
+		if !event.Date.Equal(test.date) {
+			t.Errorf("event of %q starts on %v, want %v", test.rule, event.Date, test.date)
+		}
+		if test.want != "" && (event.Rule == nil || event.Rule.String() != test.want || len(event.ExDates) != 2) {
+			t.Errorf("event of %q = %+v, want the rule %q with its exceptions", test.rule, event, test.want)
+		}
+		if test.want == "" && (event.Rule != nil || len(event.ExDates) != 0 || !reflect.DeepEqual(event.RDates, test.rdates)) {
+			t.Errorf("event of %q = %+v, want the dates %v without a rule", test.rule, event, test.rdates)
+		}
+	}
+}
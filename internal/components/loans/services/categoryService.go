@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	financeServices "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/services"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+func NewCategoryService(db gorm.DB, exchangeService financeServices.ExchangeService) CategoryService {
+	return CategoryService{db: db, exchangeService: exchangeService}
+}
+
+// CategoryService manages the categories of incomes and expenses and the monthly budgets set for them
+type CategoryService struct {
+	db              gorm.DB
+	exchangeService financeServices.ExchangeService
+}
+
+// EnsureDefaultCategories creates the system categories missing from the database
+func (cs CategoryService) EnsureDefaultCategories() error {
+	for _, category := range entities.DefaultCategories {
+		err := cs.db.Where("user_id = 0 AND name = ? AND category_type = ?", category.Name, category.CategoryType).
+			FirstOrCreate(&category).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCategories returns the system categories together with the own categories of the user
+func (cs CategoryService) GetCategories(userId uint) []entities.Category {
+	categories := []entities.Category{}
+	cs.db.Where("user_id = 0 OR user_id = ?", userId).Order("category_type, id").Find(&categories)
+	return categories
+}
+
+// IsAllowed tells whether incomes or expenses of the user of the given type may be put into the category
+func (cs CategoryService) IsAllowed(userId uint, categoryId uint, categoryType entities.CategoryType) bool {
+	if categoryId == 0 {
+		return true
+	}
+	var category entities.Category
+	cs.db.Where("user_id = 0 OR user_id = ?", userId).First(&category, categoryId)
+	return category.ID != 0 && category.CategoryType == categoryType
+}
+
+// DeleteCategory removes an own category of the user. Its subcategories move to its parent, its incomes and
+// expenses are left without a category and its budgets are deleted.
+func (cs CategoryService) DeleteCategory(category entities.Category) error {
+	tx := cs.db.Begin()
+	updates := []*gorm.DB{
+		tx.Model(&entities.Category{}).Where("parent_id = ?", category.ID).Update("parent_id", category.ParentID),
+		tx.Model(&entities.Income{}).Where("category_id = ?", category.ID).Update("category_id", 0),
+		tx.Model(&entities.Expense{}).Where("category_id = ?", category.ID).Update("category_id", 0),
+		tx.Where("category_id = ?", category.ID).Delete(&entities.Budget{}),
+		tx.Delete(&category),
+	}
+	for _, update := range updates {
+		if update.Error != nil {
+			tx.Rollback()
+			return update.Error
+		}
+	}
+	return tx.Commit().Error
+}
+
+// GetBudgetReport compares incomes and expenses of the user with the budgets for every month from the month
+// of from until to. Amounts are converted to the base currency of the user at the exchange rates known on asOf.
+func (cs CategoryService) GetBudgetReport(userId uint, from time.Time, to time.Time, asOf time.Time) (entities.BudgetReport, error) {
+	var user entities.User
+	var incomes []entities.Income
+	var expenses []entities.Expense
+	var budgets []entities.Budget
+	cs.db.First(&user, userId)
+	cs.db.Where("user_id = ?", userId).Find(&incomes)
+	cs.db.Where("user_id = ?", userId).Find(&expenses)
+	cs.db.Where("user_id = ?", userId).Find(&budgets)
+	converter, err := cs.exchangeService.Converter(user.BaseCurrencyID, asOf)
+	if err != nil {
+		return entities.BudgetReport{CurrencyID: user.BaseCurrencyID, Months: []entities.BudgetMonth{}}, err
+	}
+	return budgetReport(from, to, incomes, expenses, budgets, cs.GetCategories(userId), converter)
+}
+
+// budgetReport builds the budget report of the months from the month of from until to in the converter currency.
+// Incomes and expenses in a currency the converter has no rate for are counted as unconverted and left out of
+// the actual amounts. Without a base currency only incomes and expenses in a single currency can be added up.
+func budgetReport(from time.Time, to time.Time, incomes []entities.Income, expenses []entities.Expense, budgets []entities.Budget,
+	categories []entities.Category, converter financeEntity.CurrencyConverter) (entities.BudgetReport, error) {
+	categoriesById := map[uint]entities.Category{}
+	for _, category := range categories {
+		categoriesById[category.ID] = category
+	}
+
+	report := entities.BudgetReport{CurrencyID: converter.Currency.ID, Months: []entities.BudgetMonth{}}
+	start := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+	var months []time.Time
+	for month := start; month.Before(to); month = month.AddDate(0, 1, 0) {
+		months = append(months, month)
+	}
+	if len(months) == 0 {
+		return report, nil
+	}
+	end := months[len(months)-1].AddDate(0, 1, 0)
+
+	// TransformWithPeriod leaves out the moment from itself, so the period starts just before the first month
+	var elements []entities.AgendaElement
+	categoryOf := map[string]map[uint]uint{"Income": {}, "Expense": {}}
+	for _, income := range incomes {
+		categoryOf["Income"][income.ID] = income.CategoryID
+		elements = append(elements, income.TransformWithPeriod(start.Add(-time.Nanosecond), end)...)
+	}
+	for _, expense := range expenses {
+		categoryOf["Expense"][expense.ID] = expense.CategoryID
+		elements = append(elements, expense.TransformWithPeriod(start.Add(-time.Nanosecond), end)...)
+	}
+	inPeriod := elements[:0]
+	for _, element := range elements {
+		if element.PaymentDate.Before(start) || !element.PaymentDate.Before(end) {
+			continue
+		}
+		if converter.Currency.ID == 0 && element.CurrencyID != 0 {
+			if report.CurrencyID != 0 && report.CurrencyID != element.CurrencyID {
+				return report, errors.New(codes.NoBaseCurrency)
+			}
+			report.CurrencyID = element.CurrencyID
+		}
+		inPeriod = append(inPeriod, element)
+	}
+	elements, _ = convertElements(inPeriod, converter)
+
+	actuals := make([]map[budgetKey]financeEntity.Money, len(months))
+	for i := range actuals {
+		actuals[i] = map[budgetKey]financeEntity.Money{}
+	}
+	unconverted := make([]int, len(months))
+	for _, element := range elements {
+		monthIndex := (element.PaymentDate.Year()-start.Year())*12 + int(element.PaymentDate.Month()-start.Month())
+		if element.Unconverted {
+			unconverted[monthIndex]++
+			continue
+		}
+		key := budgetKey{categoryId: categoryOf[element.ElementType][element.ID], categoryType: entities.IncomeCategory}
+		if element.ElementType == "Expense" {
+			key.categoryType = entities.ExpenseCategory
+		}
+		for _, categoryId := range categoryPath(categoriesById, key.categoryId, key.categoryType) {
+			actuals[monthIndex][budgetKey{categoryId, key.categoryType}] += element.BaseAmount
+		}
+	}
+
+	for i, month := range months {
+		budgetMonth := entities.BudgetMonth{Month: month, Categories: []entities.CategoryBudget{}, Unconverted: unconverted[i]}
+		for _, categoryType := range []entities.CategoryType{entities.IncomeCategory, entities.ExpenseCategory} {
+			if actual := actuals[i][budgetKey{0, categoryType}]; actual != 0 {
+				budgetMonth.Categories = append(budgetMonth.Categories, entities.CategoryBudget{
+					Name:         "Uncategorized",
+					CategoryType: categoryType,
+					Actual:       actual,
+				})
+			}
+		}
+		for _, category := range categories {
+			actual := actuals[i][budgetKey{category.ID, category.CategoryType}]
+			budget, hasBudget := budgetFor(budgets, category.ID, month)
+			if actual == 0 && !hasBudget {
+				continue
+			}
+			categoryBudget := entities.CategoryBudget{
+				CategoryID:   category.ID,
+				Name:         category.Name,
+				ParentID:     category.ParentID,
+				CategoryType: category.CategoryType,
+				Actual:       actual,
+			}
+			if hasBudget {
+				categoryBudget.Budget = budget
+				categoryBudget.Remaining = budget - actual
+				categoryBudget.IsOverBudget = actual > budget
+			}
+			budgetMonth.Categories = append(budgetMonth.Categories, categoryBudget)
+		}
+		report.Months = append(report.Months, budgetMonth)
+	}
+	return report, nil
+}
+
+type budgetKey struct {
+	categoryId   uint
+	categoryType entities.CategoryType
+}
+
+// categoryPath returns the category with all its parents, so that amounts count in every level of the hierarchy.
+// Categories unknown to the user or of another type are treated as no category at all.
+func categoryPath(categories map[uint]entities.Category, categoryId uint, categoryType entities.CategoryType) []uint {
+	var path []uint
+	for categoryId != 0 && len(path) <= len(categories) {
+		category, ok := categories[categoryId]
+		if !ok || category.CategoryType != categoryType {
+			break
+		}
+		path = append(path, categoryId)
+		categoryId = category.ParentID
+	}
+	if len(path) == 0 {
+		return []uint{0}
+	}
+	return path
+}
+
+// budgetFor returns the budget of the category for the month, preferring one set for that very month
+func budgetFor(budgets []entities.Budget, categoryId uint, month time.Time) (financeEntity.Money, bool) {
+	var amount financeEntity.Money
+	found := false
+	for _, budget := range budgets {
+		if budget.CategoryID != categoryId {
+			continue
+		}
+		if budget.Month.IsZero() && !found {
+			amount, found = budget.Amount, true
+		} else if budget.Month.Year() == month.Year() && budget.Month.Month() == month.Month() {
+			return budget.Amount, true
+		}
+	}
+	return amount, found
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"testing"
+	"time"
+)
+
+func TestBudgetReportCurrencies(t *testing.T) {
+	from := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 2, 0)
+	categories := []entities.Category{
+		{ID: 1, Name: "Food", CategoryType: entities.ExpenseCategory},
+		{ID: 2, Name: "Groceries", CategoryType: entities.ExpenseCategory, ParentID: 1},
+	}
+	budgets := []entities.Budget{{CategoryID: 1, Amount: financeEntity.NewMoney(15000)}}
+	expenses := []entities.Expense{
+		{ID: 1, Amount: financeEntity.NewMoney(10000), CurrencyID: 1, CategoryID: 2, StartDate: from.AddDate(0, 0, 4),
+			IsRepeatable: true, Frequency: 1, PaymentPeriod: entities.Month},
+		{ID: 2, Amount: financeEntity.NewMoney(50), CurrencyID: 2, CategoryID: 1, StartDate: from.AddDate(0, 1, 9)},
+	}
+	rub := financeEntity.Currency{ID: 1, MinorUnits: 100}
+
+	// the dollar expense of February has no rate, so it is counted apart and the rest of the report stands
+	report, err := budgetReport(from, to, nil, expenses, budgets, categories, financeEntity.CurrencyConverter{Currency: rub, Date: to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Months) != 2 || report.CurrencyID != 1 {
+		t.Fatalf("report = %+v, want two months in rubles", report)
+	}
+	for i, want := range []int{0, 1} {
+		month := report.Months[i]
+		if month.Unconverted != want {
+			t.Errorf("month %d: %d unconverted, want %d", i, month.Unconverted, want)
+		}
+		if len(month.Categories) != 2 || month.Categories[0].Actual != financeEntity.NewMoney(10000) ||
+			month.Categories[0].Remaining != financeEntity.NewMoney(5000) {
+			t.Errorf("month %d: categories = %+v, want 10000 of the food budget of 15000 spent", i, month.Categories)
+		}
+	}
+
+	converter := financeEntity.CurrencyConverter{Currency: rub, Date: to, Rates: []financeEntity.ExchangeRate{
+		{CurrencyID: 2, QuoteCurrencyID: 1, RateDate: from, Rate: 100},
+	}}
+	report, err = budgetReport(from, to, nil, expenses, budgets, categories, converter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if food := report.Months[1].Categories[0]; report.Months[1].Unconverted != 0 || food.Actual != financeEntity.NewMoney(15000) {
+		t.Errorf("February: %+v, want the dollar expense converted into the food category", report.Months[1])
+	}
+
+	// without a base currency rubles and dollars cannot be added up
+	if _, err := budgetReport(from, to, nil, expenses, budgets, categories, financeEntity.CurrencyConverter{Date: to}); err == nil || err.Error() != codes.NoBaseCurrency {
+		t.Errorf("report of mixed currencies without a base one returned %v", err)
+	}
+	report, err = budgetReport(from, to, nil, expenses[:1], budgets, categories, financeEntity.CurrencyConverter{Date: to})
+	if err != nil || report.CurrencyID != 1 || report.Months[0].Categories[0].Actual != financeEntity.NewMoney(10000) {
+		t.Errorf("report of a single currency without a base one = %+v, %v", report, err)
+	}
+}
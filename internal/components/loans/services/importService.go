@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/a1ta1r/Credit-Portfolio/internal/codes"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/bankstatement"
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+func NewImportService(db gorm.DB, agendaService AgendaService, categoryService CategoryService) ImportService {
+	return ImportService{db: db, agendaService: agendaService, categoryService: categoryService}
+}
+
+// ImportService turns the operations of bank statements into incomes and expenses
+type ImportService struct {
+	db              gorm.DB
+	agendaService   AgendaService
+	categoryService CategoryService
+}
+
+// Preview shows the operations of a statement as the incomes and expenses they are imported as, marking those
+// the user already has. Operations without a currency of their own are in the given currency or, without
+// one, in the base currency of the user.
+func (is ImportService) Preview(userId uint, transactions []bankstatement.Transaction, currencyId uint) ([]entities.ImportedTransaction, error) {
+	var currencies []financeEntity.Currency
+	is.db.Find(&currencies)
+	if currencyId == 0 {
+		var user entities.User
+		is.db.First(&user, userId)
+		currencyId = user.BaseCurrencyID
+	}
+	items := []entities.ImportedTransaction{}
+	for _, transaction := range transactions {
+		if transaction.Amount == 0 {
+			continue
+		}
+		item := entities.ImportedTransaction{
+			ElementType: "Income",
+			Reason:      transaction.Description,
+			Amount:      transaction.Amount,
+			CurrencyID:  currencyId,
+			Date:        transaction.Date,
+			ExternalID:  transaction.ExternalID,
+		}
+		if transaction.Amount < 0 {
+			item.ElementType, item.Amount = "Expense", -transaction.Amount
+		}
+		if transaction.Currency != "" {
+			item.CurrencyID = 0
+			for _, currency := range currencies {
+				if strings.EqualFold(currency.Name, transaction.Currency) || currency.Symbol == transaction.Currency {
+					item.CurrencyID = currency.ID
+				}
+			}
+			if item.CurrencyID == 0 {
+				return nil, fmt.Errorf("%s: unknown currency %q", codes.BadStatementFile, transaction.Currency)
+			}
+		}
+		if item.CurrencyID == 0 {
+			return nil, fmt.Errorf("%s: no currency", codes.BadStatementFile)
+		}
+		items = append(items, item)
+	}
+	return is.withDuplicates(userId, items), nil
+}
+
+// Import creates the incomes and expenses of the operations in one database transaction. Duplicates are found
+// again, whatever the operations say, and skipped.
+func (is ImportService) Import(userId uint, items []entities.ImportedTransaction) (entities.ImportResult, error) {
+	result := entities.ImportResult{Incomes: []entities.Income{}, Expenses: []entities.Expense{}}
+	var currencyIds []uint
+	for _, item := range items {
+		currencyIds = append(currencyIds, item.CurrencyID)
+	}
+	var currencies []financeEntity.Currency
+	is.db.Where("id IN (?)", currencyIds).Find(&currencies)
+	known := map[uint]bool{}
+	for _, currency := range currencies {
+		known[currency.ID] = true
+	}
+	for _, item := range items {
+		categoryType := entities.IncomeCategory
+		if item.ElementType == "Expense" {
+			categoryType = entities.ExpenseCategory
+		}
+		if item.ElementType != "Income" && item.ElementType != "Expense" || item.Amount <= 0 || item.Date.IsZero() ||
+			!known[item.CurrencyID] || !is.categoryService.IsAllowed(userId, item.CategoryID, categoryType) {
+			return result, errors.New(codes.BadImport)
+		}
+	}
+
+	tx := is.db.Begin()
+	for _, item := range is.withDuplicates(userId, items) {
+		if item.Duplicate {
+			result.Skipped++
+			continue
+		}
+		var err error
+		if item.ElementType == "Income" {
+			income := entities.Income{UserID: userId, Reason: item.Reason, Amount: item.Amount, CurrencyID: item.CurrencyID,
+				CategoryID: item.CategoryID, StartDate: item.Date, ExternalID: item.ExternalID}
+			err = tx.Create(&income).Error
+			result.Incomes = append(result.Incomes, income)
+		} else {
+			expense := entities.Expense{UserID: userId, Reason: item.Reason, Amount: item.Amount, CurrencyID: item.CurrencyID,
+				CategoryID: item.CategoryID, StartDate: item.Date, ExternalID: item.ExternalID}
+			err = tx.Create(&expense).Error
+			result.Expenses = append(result.Expenses, expense)
+		}
+		if err != nil {
+			tx.Rollback()
+			return entities.ImportResult{}, err
+		}
+	}
+	return result, tx.Commit().Error
+}
+
+// withDuplicates marks the operations matching the existing incomes and expenses of the user
+func (is ImportService) withDuplicates(userId uint, items []entities.ImportedTransaction) []entities.ImportedTransaction {
+	if len(items) == 0 {
+		return items
+	}
+	query := entities.AgendaQuery{From: items[0].Date, To: items[0].Date, ElementTypes: []string{"Income", "Expense"}}
+	var externalIds []string
+	for _, item := range items {
+		if item.Date.Before(query.From) {
+			query.From = item.Date
+		}
+		if item.Date.After(query.To) {
+			query.To = item.Date
+		}
+		if item.ExternalID != "" {
+			externalIds = append(externalIds, item.ExternalID)
+		}
+	}
+	query.From, query.To = query.From.AddDate(0, 0, -1), query.To.AddDate(0, 0, 1)
+	occurrences := is.agendaService.GetElements(query, userId)
+
+	existing := map[string]uint{}
+	if len(externalIds) > 0 {
+		var incomes []entities.Income
+		var expenses []entities.Expense
+		is.db.Where("user_id = ? AND external_id IN (?)", userId, externalIds).Find(&incomes)
+		is.db.Where("user_id = ? AND external_id IN (?)", userId, externalIds).Find(&expenses)
+		for _, income := range incomes {
+			existing["Income/"+income.ExternalID] = income.ID
+		}
+		for _, expense := range expenses {
+			existing["Expense/"+expense.ExternalID] = expense.ID
+		}
+	}
+	return markDuplicates(items, occurrences, existing)
+}
+
+// markDuplicates flags an operation as a duplicate if an income or expense of its type carries its external id,
+// if an earlier operation of the statement has the same id, or if it has the date, amount and currency of an
+// occurrence of an income or expense of its type. Each occurrence matches one operation only, so that two equal
+// purchases on a day stay two. Existing records are the ids of incomes and expenses by type and external id.
+func markDuplicates(items []entities.ImportedTransaction, occurrences []entities.AgendaElement,
+	existing map[string]uint) []entities.ImportedTransaction {
+	seen := map[string]bool{}
+	matched := make([]bool, len(occurrences))
+	marked := make([]entities.ImportedTransaction, len(items))
+	for i, item := range items {
+		item.Duplicate, item.DuplicateOf = false, 0
+		key := item.ElementType + "/" + item.ExternalID
+		if id, ok := existing[key]; ok && item.ExternalID != "" {
+			item.Duplicate, item.DuplicateOf = true, id
+		} else if seen[key] && item.ExternalID != "" {
+			item.Duplicate = true
+		} else {
+			year, month, day := item.Date.Date()
+			for j, occurrence := range occurrences {
+				occurrenceYear, occurrenceMonth, occurrenceDay := occurrence.PaymentDate.Date()
+				if !matched[j] && occurrence.ElementType == item.ElementType && occurrence.PaymentAmount == item.Amount &&
+					occurrence.CurrencyID == item.CurrencyID &&
+					occurrenceYear == year && occurrenceMonth == month && occurrenceDay == day {
+					matched[j] = true
+					item.Duplicate, item.DuplicateOf = true, occurrence.ID
+					break
+				}
+			}
+		}
+		seen[key] = true
+		marked[i] = item
+	}
+	return marked
+}
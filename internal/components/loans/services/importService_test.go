@@ -0,0 +1,54 @@
+package services
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"testing"
+	"time"
+)
+
+func TestMarkDuplicates(t *testing.T) {
+	day := time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC)
+	occurrences := []entities.AgendaElement{
+		{ElementType: "Expense", ID: 7, PaymentAmount: financeEntity.NewMoney(500), PaymentDate: day, CurrencyID: 1},
+		{ElementType: "Income", ID: 8, PaymentAmount: financeEntity.NewMoney(1000), PaymentDate: day, CurrencyID: 1},
+	}
+	existing := map[string]uint{"Expense/A1": 3, "Income/B1": 4}
+	items := []entities.ImportedTransaction{
+		// imported before under the same identifier
+		{ElementType: "Expense", ExternalID: "A1", Amount: financeEntity.NewMoney(1), CurrencyID: 1, Date: day},
+		// the identifier of an income does not match an expense
+		{ElementType: "Expense", ExternalID: "B1", Amount: financeEntity.NewMoney(2), CurrencyID: 1, Date: day},
+		// repeated within the statement
+		{ElementType: "Expense", ExternalID: "B1", Amount: financeEntity.NewMoney(2), CurrencyID: 1, Date: day},
+		// an occurrence of the same type, amount, currency and day, whatever the time
+		{ElementType: "Expense", ExternalID: "C1", Amount: financeEntity.NewMoney(500), CurrencyID: 1, Date: day.Add(18 * time.Hour)},
+		// the occurrence has already been matched
+		{ElementType: "Expense", ExternalID: "C2", Amount: financeEntity.NewMoney(500), CurrencyID: 1, Date: day},
+		// another currency
+		{ElementType: "Income", Amount: financeEntity.NewMoney(1000), CurrencyID: 2, Date: day},
+		// another day
+		{ElementType: "Income", Amount: financeEntity.NewMoney(1000), CurrencyID: 1, Date: day.AddDate(0, 0, 1)},
+		// without an identifier only the occurrence is matched
+		{ElementType: "Income", Amount: financeEntity.NewMoney(1000), CurrencyID: 1, Date: day, Duplicate: true, DuplicateOf: 99},
+		{ElementType: "Income", Amount: financeEntity.NewMoney(1000), CurrencyID: 1, Date: day, Duplicate: true, DuplicateOf: 99},
+	}
+	want := []struct {
+		duplicate   bool
+		duplicateOf uint
+	}{{true, 3}, {false, 0}, {true, 0}, {true, 7}, {false, 0}, {false, 0}, {false, 0}, {true, 8}, {false, 0}}
+
+	marked := markDuplicates(items, occurrences, existing)
+	if len(marked) != len(items) {
+		t.Fatalf("%d transactions marked of %d", len(marked), len(items))
+	}
+	for i, item := range marked {
+		if item.Duplicate != want[i].duplicate || item.DuplicateOf != want[i].duplicateOf {
+			t.Errorf("transaction %d: duplicate = %v of %d, want %v of %d",
+				i, item.Duplicate, item.DuplicateOf, want[i].duplicate, want[i].duplicateOf)
+		}
+	}
+	if items[7].DuplicateOf != 99 {
+		t.Error("markDuplicates changed the transactions it was given")
+	}
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"github.com/jinzhu/gorm"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+func NewPenaltyService(db gorm.DB) PenaltyService {
+	return PenaltyService{db: db, lock: &sync.Mutex{}}
+}
+
+// PenaltyService accrues late fees and penalty interest on overdue payments according to the penalty
+// terms of their plans. Every penalty is stored as a separate line item.
+type PenaltyService struct {
+	db   gorm.DB
+	lock *sync.Mutex
+}
+
+// Run accrues penalties right away and then every interval until the process stops
+func (ps PenaltyService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if count, err := ps.AccruePenalties(time.Now()); err != nil {
+			log.Println("penalty accrual failed:", err)
+		} else if count > 0 {
+			log.Println("penalties accrued:", count)
+		}
+		<-ticker.C
+	}
+}
+
+// AccruePenalties charges the penalties of payments overdue by date and returns how many were created.
+// Late fees are charged once per payment; penalty interest is accrued on the overdue amount from the day
+// the previous run stopped at until date or until the payment was paid, so running it again adds nothing.
+// Every plan is charged in a transaction of its own, so a plan that fails does not hold back the others;
+// the first error is returned once all plans are done.
+func (ps PenaltyService) AccruePenalties(date time.Time) (int, error) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	today := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	var payments []entities.Payment
+	err := ps.db.Preload("PaymentPlan.Currency").
+		Where("payment_amount > 0 AND payment_date < ? AND (status <> ? OR paid_date > payment_date)", today, entities.Paid).
+		Where("payment_plan_id IN (?)", ps.db.Table("payment_plans").Select("id").Where("late_fee > 0 OR penalty_rate > 0").QueryExpr()).
+		Order("payment_plan_id, payment_date").
+		Find(&payments).Error
+	var penalties []entities.Penalty
+	if err == nil {
+		err = ps.db.Where("payment_id IN (?)", paymentIds(payments)).Find(&penalties).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var created int
+	var firstErr error
+	for start := 0; start < len(payments); {
+		end := start
+		for end < len(payments) && payments[end].PaymentPlanID == payments[start].PaymentPlanID {
+			end++
+		}
+		count, err := ps.accruePlanPenalties(payments[start:end], penalties, today)
+		if err != nil {
+			log.Println("penalty accrual failed for plan", payments[start].PaymentPlanID, ":", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		created += count
+		start = end
+	}
+	return created, firstErr
+}
+
+// accruePlanPenalties charges the penalties of the overdue payments of a plan in a single transaction
+func (ps PenaltyService) accruePlanPenalties(payments []entities.Payment, charged []entities.Penalty, today time.Time) (int, error) {
+	tx := ps.db.Begin()
+	var created int
+	for _, payment := range payments {
+		for _, penalty := range newPenalties(payment, charged, today) {
+			if err := tx.Create(&penalty).Error; err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			created++
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+// RevisePenalties takes back what was charged on the payment past the day it was paid, once it is recorded
+// as paid: penalty interest accrued beyond that day is removed or cut down to the days before it, and the
+// late fee is removed if the payment was paid on time after all.
+func (ps PenaltyService) RevisePenalties(db *gorm.DB, paymentPlan entities.PaymentPlan, payment entities.Payment) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	var penalties []entities.Penalty
+	if err := db.Where("payment_id = ?", payment.ID).Find(&penalties).Error; err != nil {
+		return err
+	}
+	updated, removed := revisedPenalties(payment, penalties, paymentPlan.Currency)
+	for _, penalty := range removed {
+		if err := db.Delete(&penalty).Error; err != nil {
+			return err
+		}
+	}
+	for _, penalty := range updated {
+		if err := db.Save(&penalty).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revisedPenalties returns the penalties of a paid payment to cut down to its paid date and the ones to remove
+func revisedPenalties(payment entities.Payment, penalties []entities.Penalty, currency financeEntity.Currency) ([]entities.Penalty, []entities.Penalty) {
+	var updated, removed []entities.Penalty
+	if payment.Status != entities.Paid || payment.PaidDate.IsZero() {
+		return updated, removed
+	}
+	paidDay := time.Date(payment.PaidDate.Year(), payment.PaidDate.Month(), payment.PaidDate.Day(), 0, 0, 0, 0, payment.PaidDate.Location())
+	for _, penalty := range penalties {
+		if penalty.PenaltyType == entities.LateFee {
+			if !paidDay.After(payment.PaymentDate) {
+				removed = append(removed, penalty)
+			}
+			continue
+		}
+		if !penalty.AccruedTo.After(paidDay) {
+			continue
+		}
+		days := math.Floor(penalty.AccruedTo.Sub(penalty.AccruedFrom).Hours() / 24)
+		kept := math.Floor(paidDay.Sub(penalty.AccruedFrom).Hours() / 24)
+		if kept < 1 || days < 1 {
+			removed = append(removed, penalty)
+			continue
+		}
+		penalty.Amount = penalty.Amount.Mul(kept / days).Round(currency)
+		penalty.AccruedTo = penalty.AccruedFrom.AddDate(0, 0, int(kept))
+		updated = append(updated, penalty)
+	}
+	return updated, removed
+}
+
+// newPenalties returns the penalties of an overdue payment not charged yet by today
+func newPenalties(payment entities.Payment, charged []entities.Penalty, today time.Time) []entities.Penalty {
+	paymentPlan := payment.PaymentPlan
+	var hasLateFee bool
+	var accruedTo = payment.PaymentDate
+	for _, penalty := range charged {
+		if penalty.PaymentID != payment.ID {
+			continue
+		}
+		if penalty.PenaltyType == entities.LateFee {
+			hasLateFee = true
+		} else if penalty.AccruedTo.After(accruedTo) {
+			accruedTo = penalty.AccruedTo
+		}
+	}
+
+	var penalties []entities.Penalty
+	if paymentPlan.LateFee > 0 && !hasLateFee {
+		penalties = append(penalties, entities.Penalty{
+			PaymentPlanID: paymentPlan.ID,
+			PaymentID:     payment.ID,
+			PenaltyType:   entities.LateFee,
+			AccruedFrom:   payment.PaymentDate,
+			AccruedTo:     payment.PaymentDate,
+			Amount:        paymentPlan.LateFee,
+		})
+	}
+	until := today
+	if payment.Status == entities.Paid && payment.PaidDate.Before(until) {
+		until = payment.PaidDate
+	}
+	days := math.Floor(until.Sub(accruedTo).Hours() / 24)
+	if paymentPlan.PenaltyRate > 0 && days >= 1 {
+		amount := payment.OverdueAmount().Mul(paymentPlan.PenaltyRate / 100 * days / 365).Round(paymentPlan.Currency)
+		if amount > 0 {
+			penalties = append(penalties, entities.Penalty{
+				PaymentPlanID: paymentPlan.ID,
+				PaymentID:     payment.ID,
+				PenaltyType:   entities.PenaltyInterest,
+				AccruedFrom:   accruedTo,
+				AccruedTo:     accruedTo.AddDate(0, 0, int(days)),
+				Amount:        amount,
+			})
+		}
+	}
+	return penalties
+}
+
+func paymentIds(payments []entities.Payment) []uint {
+	ids := []uint{0}
+	for _, payment := range payments {
+		ids = append(ids, payment.ID)
+	}
+	return ids
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	financeEntity "github.com/a1ta1r/Credit-Portfolio/internal/components/finance/entities"
+	"github.com/a1ta1r/Credit-Portfolio/internal/components/loans/entities"
+	"testing"
+	"time"
+)
+
+var penaltyDue = time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+func overduePayment() entities.Payment {
+	return entities.Payment{
+		ID:            7,
+		PaymentPlanID: 3,
+		PaymentDate:   penaltyDue,
+		PaymentAmount: 3650000,
+		Status:        entities.Missed,
+		PaymentPlan: entities.PaymentPlan{ID: 3, LateFee: 5000000, PenaltyRate: 10,
+			Currency: financeEntity.Currency{MinorUnits: 100}},
+	}
+}
+
+func TestNewPenalties(t *testing.T) {
+	payment := overduePayment()
+	penalties := newPenalties(payment, nil, penaltyDue.AddDate(0, 0, 10))
+	if len(penalties) != 2 {
+		t.Fatalf("got %d penalties, want a late fee and penalty interest", len(penalties))
+	}
+	if penalties[0].PenaltyType != entities.LateFee || penalties[0].Amount != 5000000 {
+		t.Errorf("late fee = %+v", penalties[0])
+	}
+	// 365 at 10% a year for 10 days
+	if penalties[1].Amount != 10000 || !penalties[1].AccruedTo.Equal(penaltyDue.AddDate(0, 0, 10)) {
+		t.Errorf("penalty interest = %+v", penalties[1])
+	}
+
+	again := newPenalties(payment, penalties, penaltyDue.AddDate(0, 0, 10))
+	if len(again) != 0 {
+		t.Errorf("a second run on the same day charged %+v", again)
+	}
+	later := newPenalties(payment, penalties, penaltyDue.AddDate(0, 0, 15))
+	if len(later) != 1 || later[0].Amount != 5000 || !later[0].AccruedFrom.Equal(penaltyDue.AddDate(0, 0, 10)) {
+		t.Errorf("a later run charged %+v, want 5 days of interest", later)
+	}
+
+	payment.Status, payment.PaidDate = entities.Paid, penaltyDue.AddDate(0, 0, 4)
+	paid := newPenalties(payment, nil, penaltyDue.AddDate(0, 0, 10))
+	if len(paid) != 2 || paid[1].Amount != 4000 {
+		t.Errorf("penalties of a payment paid late = %+v, want interest until it was paid", paid)
+	}
+}
+
+func TestRevisedPenalties(t *testing.T) {
+	currency := financeEntity.Currency{MinorUnits: 100}
+	lateFee := entities.Penalty{ID: 1, PenaltyType: entities.LateFee, AccruedFrom: penaltyDue, AccruedTo: penaltyDue, Amount: 5000000}
+	first := entities.Penalty{ID: 2, PenaltyType: entities.PenaltyInterest,
+		AccruedFrom: penaltyDue, AccruedTo: penaltyDue.AddDate(0, 0, 10), Amount: 10000}
+	second := entities.Penalty{ID: 3, PenaltyType: entities.PenaltyInterest,
+		AccruedFrom: penaltyDue.AddDate(0, 0, 10), AccruedTo: penaltyDue.AddDate(0, 0, 15), Amount: 5000}
+	penalties := []entities.Penalty{lateFee, first, second}
+
+	payment := overduePayment()
+	if updated, removed := revisedPenalties(payment, penalties, currency); len(updated)+len(removed) != 0 {
+		t.Errorf("penalties of an unpaid payment were revised: %+v, %+v", updated, removed)
+	}
+
+	payment.Status, payment.PaidDate = entities.Paid, penaltyDue.AddDate(0, 0, 4)
+	updated, removed := revisedPenalties(payment, penalties, currency)
+	if len(removed) != 1 || removed[0].ID != 3 {
+		t.Errorf("removed %+v, want the interest accrued after the payment", removed)
+	}
+	if len(updated) != 1 || updated[0].ID != 2 || updated[0].Amount != 4000 || !updated[0].AccruedTo.Equal(payment.PaidDate) {
+		t.Errorf("updated %+v, want the interest cut down to 4 days", updated)
+	}
+
+	payment.PaidDate = penaltyDue.AddDate(0, 0, 12)
+	updated, removed = revisedPenalties(payment, penalties, currency)
+	if len(removed) != 0 || len(updated) != 1 || updated[0].ID != 3 || updated[0].Amount != 2000 {
+		t.Errorf("revised %+v and removed %+v, want only the second accrual cut down to 2 days", updated, removed)
+	}
+
+	payment.PaidDate = penaltyDue
+	updated, removed = revisedPenalties(payment, penalties, currency)
+	if len(updated) != 0 || len(removed) != 3 {
+		t.Errorf("revised %+v and removed %+v, want everything removed for a payment paid on time", updated, removed)
+	}
+}
+
+func TestNewPenaltiesAfterRecalculation(t *testing.T) {
+	payment := overduePayment()
+	charged := newPenalties(payment, nil, penaltyDue.AddDate(0, 0, 10))
+
+	// a back-dated prepayment cuts the installment down, the payment keeps its id and stays missed
+	payment.PaymentAmount /= 2
+	penalties := newPenalties(payment, charged, penaltyDue.AddDate(0, 0, 20))
+	if len(penalties) != 1 || penalties[0].PenaltyType != entities.PenaltyInterest {
+		t.Fatalf("penalties after the recalculation = %+v, want only further interest", penalties)
+	}
+	if penalties[0].Amount != 5000 || !penalties[0].AccruedFrom.Equal(penaltyDue.AddDate(0, 0, 10)) {
+		t.Errorf("penalty interest = %+v, want 10 more days on the reduced amount", penalties[0])
+	}
+}
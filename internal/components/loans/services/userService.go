@@ -38,6 +38,14 @@ func (us UserService) UpdateUser(user entities.User) entities.User {
 	}
 }
 
+func (us UserService) CurrencyExists(currencyID uint) bool {
+	if exists, err := us.storageContainer.UserStorage.CurrencyExists(currencyID); err != nil {
+		panic(err)
+	} else {
+		return exists
+	}
+}
+
 func (us UserService) GetUserByID(id uint) entities.User {
 	if user, err := us.storageContainer.UserStorage.GetByID(id); err != nil && err != gorm.ErrRecordNotFound {
 		panic(err)
@@ -32,6 +32,13 @@ func (us UserStorage) GetByUsername(username string) (entities.User, error) {
 	return user, err
 }
 
+// CurrencyExists tells whether the currency a user may pick as the base one is known
+func (us UserStorage) CurrencyExists(currencyID uint) (bool, error) {
+	var count int
+	err := us.DB.Table("currencies").Where("id = ?", currencyID).Count(&count).Error
+	return count != 0, err
+}
+
 func (us UserStorage) Update(user entities.User) error {
 	return us.DB.Save(&user).Error
 }